@@ -0,0 +1,472 @@
+// Package client pilote un serveur Puissance 4 depuis un autre programme Go,
+// à travers l'API JSON (/api/*).
+//
+// La partie est rattachée à la session du client : New crée un client dont le
+// cookie de session est conservé d'un appel à l'autre.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// CONSTANTS
+// ============================================================================
+
+// Modes de jeu acceptés par NewGame
+const (
+	MODE_TWO_PLAYER = "twoPlayer"
+	MODE_AI         = "ai"
+)
+
+// Codes d'erreur renvoyés par le serveur (champ errorCode)
+const (
+	ERROR_COLUMN_FULL          = "COLUMN_FULL"
+	ERROR_COLUMN_OUT_OF_RANGE  = "COLUMN_OUT_OF_RANGE"
+	ERROR_GAME_OVER            = "GAME_OVER"
+	ERROR_NOT_YOUR_TURN        = "NOT_YOUR_TURN"
+	ERROR_INVALID_IMPORT       = "INVALID_IMPORT"
+	ERROR_ROOM_NOT_FOUND       = "ROOM_NOT_FOUND"
+	ERROR_ROOM_FULL            = "ROOM_FULL"
+	ERROR_NOT_IN_ROOM          = "NOT_IN_ROOM"
+	ERROR_POP_NOT_ALLOWED      = "POP_NOT_ALLOWED"
+	ERROR_NOT_YOUR_TOKEN       = "NOT_YOUR_TOKEN"
+	ERROR_PLACE_NOT_ALLOWED    = "PLACE_NOT_ALLOWED"
+	ERROR_CELL_OUT_OF_RANGE    = "CELL_OUT_OF_RANGE"
+	ERROR_CELL_OCCUPIED        = "CELL_OCCUPIED"
+	ERROR_TIMEOUT              = "TIMEOUT"
+	ERROR_SWAP_NOT_ALLOWED     = "SWAP_NOT_ALLOWED"
+	ERROR_RATE_LIMITED         = "RATE_LIMITED"
+	ERROR_INVALID_POSITION     = "INVALID_POSITION"
+	ERROR_NOT_A_PLAYER         = "NOT_A_PLAYER"
+	ERROR_INVALID_REQUEST      = "INVALID_REQUEST"
+	ERROR_REQUEST_TOO_LARGE    = "REQUEST_TOO_LARGE"
+	ERROR_UNDO_NOT_ALLOWED     = "UNDO_NOT_ALLOWED"
+	ERROR_AI_THINKING          = "AI_THINKING"
+	ERROR_TAKEBACK_NOT_ALLOWED = "TAKEBACK_NOT_ALLOWED"
+	ERROR_TAKEBACK_PENDING     = "TAKEBACK_PENDING"
+	ERROR_NO_TAKEBACK          = "NO_TAKEBACK"
+	ERROR_DRAW_NOT_ALLOWED     = "DRAW_NOT_ALLOWED"
+	ERROR_DRAW_OFFER_PENDING   = "DRAW_OFFER_PENDING"
+	ERROR_NO_DRAW_OFFER        = "NO_DRAW_OFFER"
+	ERROR_TOURNAMENT_CLOSED    = "TOURNAMENT_CLOSED"
+	ERROR_MATCH_NOT_FOUND      = "MATCH_NOT_FOUND"
+	ERROR_MATCH_NOT_PLAYABLE   = "MATCH_NOT_PLAYABLE"
+	ERROR_UNAUTHORIZED         = "UNAUTHORIZED"
+)
+
+// ============================================================================
+// ERRORS
+// ============================================================================
+
+// Erreurs correspondant aux codes documentés, à tester avec errors.Is
+var (
+	ErrColumnFull         = errors.New("colonne pleine")
+	ErrColumnOutOfRange   = errors.New("colonne invalide")
+	ErrGameOver           = errors.New("partie terminée")
+	ErrNotYourTurn        = errors.New("pas votre tour")
+	ErrInvalidImport      = errors.New("export invalide")
+	ErrRoomNotFound       = errors.New("salon introuvable")
+	ErrRoomFull           = errors.New("salon complet")
+	ErrNotInRoom          = errors.New("salon non rejoint")
+	ErrPopNotAllowed      = errors.New("retrait interdit")
+	ErrNotYourToken       = errors.New("jeton adverse")
+	ErrPlaceNotAllowed    = errors.New("pose interdite")
+	ErrCellOutOfRange     = errors.New("case invalide")
+	ErrCellOccupied       = errors.New("case occupée")
+	ErrTimeout            = errors.New("temps écoulé")
+	ErrSwapNotAllowed     = errors.New("échange interdit")
+	ErrRateLimited        = errors.New("trop de requêtes")
+	ErrInvalidPosition    = errors.New("position invalide")
+	ErrNotAPlayer         = errors.New("spectateur du salon")
+	ErrInvalidRequest     = errors.New("requête illisible")
+	ErrRequestTooLarge    = errors.New("requête trop volumineuse")
+	ErrUndoNotAllowed     = errors.New("annulation refusée")
+	ErrAIThinking         = errors.New("l'IA réfléchit")
+	ErrTakebackNotAllowed = errors.New("reprise refusée")
+	ErrTakebackPending    = errors.New("reprise déjà demandée")
+	ErrNoTakeback         = errors.New("aucune reprise demandée")
+	ErrDrawNotAllowed     = errors.New("nul par accord impossible contre l'IA")
+	ErrDrawOfferPending   = errors.New("nul déjà proposé")
+	ErrNoDrawOffer        = errors.New("aucun nul proposé")
+	ErrTournamentClosed   = errors.New("action impossible à ce stade du tournoi")
+	ErrMatchNotFound      = errors.New("match introuvable")
+	ErrMatchNotPlayable   = errors.New("match non jouable")
+	ErrUnauthorized       = errors.New("jeton d'accès absent ou invalide")
+)
+
+var codeErrors = map[string]error{
+	ERROR_COLUMN_FULL:          ErrColumnFull,
+	ERROR_COLUMN_OUT_OF_RANGE:  ErrColumnOutOfRange,
+	ERROR_GAME_OVER:            ErrGameOver,
+	ERROR_NOT_YOUR_TURN:        ErrNotYourTurn,
+	ERROR_INVALID_IMPORT:       ErrInvalidImport,
+	ERROR_ROOM_NOT_FOUND:       ErrRoomNotFound,
+	ERROR_ROOM_FULL:            ErrRoomFull,
+	ERROR_NOT_IN_ROOM:          ErrNotInRoom,
+	ERROR_POP_NOT_ALLOWED:      ErrPopNotAllowed,
+	ERROR_NOT_YOUR_TOKEN:       ErrNotYourToken,
+	ERROR_PLACE_NOT_ALLOWED:    ErrPlaceNotAllowed,
+	ERROR_CELL_OUT_OF_RANGE:    ErrCellOutOfRange,
+	ERROR_CELL_OCCUPIED:        ErrCellOccupied,
+	ERROR_TIMEOUT:              ErrTimeout,
+	ERROR_SWAP_NOT_ALLOWED:     ErrSwapNotAllowed,
+	ERROR_RATE_LIMITED:         ErrRateLimited,
+	ERROR_INVALID_POSITION:     ErrInvalidPosition,
+	ERROR_NOT_A_PLAYER:         ErrNotAPlayer,
+	ERROR_INVALID_REQUEST:      ErrInvalidRequest,
+	ERROR_REQUEST_TOO_LARGE:    ErrRequestTooLarge,
+	ERROR_UNDO_NOT_ALLOWED:     ErrUndoNotAllowed,
+	ERROR_AI_THINKING:          ErrAIThinking,
+	ERROR_TAKEBACK_NOT_ALLOWED: ErrTakebackNotAllowed,
+	ERROR_TAKEBACK_PENDING:     ErrTakebackPending,
+	ERROR_NO_TAKEBACK:          ErrNoTakeback,
+	ERROR_DRAW_NOT_ALLOWED:     ErrDrawNotAllowed,
+	ERROR_DRAW_OFFER_PENDING:   ErrDrawOfferPending,
+	ERROR_NO_DRAW_OFFER:        ErrNoDrawOffer,
+	ERROR_TOURNAMENT_CLOSED:    ErrTournamentClosed,
+	ERROR_MATCH_NOT_FOUND:      ErrMatchNotFound,
+	ERROR_MATCH_NOT_PLAYABLE:   ErrMatchNotPlayable,
+	ERROR_UNAUTHORIZED:         ErrUnauthorized,
+}
+
+// APIError décrit une requête refusée par le serveur
+// GameState est l'état de la partie au moment du refus, s'il a été renvoyé
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	GameState  *GameState
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("puissance4: %s (%d %s)", e.Message, e.StatusCode, e.Code)
+	}
+	return fmt.Sprintf("puissance4: %s (%d)", e.Message, e.StatusCode)
+}
+
+// Unwrap permet errors.Is(err, ErrColumnFull) et consorts
+func (e *APIError) Unwrap() error {
+	return codeErrors[e.Code]
+}
+
+// ============================================================================
+// DATA STRUCTURES
+// ============================================================================
+
+// GameState est l'état d'une partie tel que renvoyé par le serveur
+type GameState struct {
+	Board          [][]int
+	Rows           int
+	Cols           int
+	WinLength      int
+	AllowOverline  bool    // Un alignement plus long que WinLength gagne aussi
+	DoubleWin      string  // Issue d'un retrait qui aligne les deux joueurs ("mover" ou "draw")
+	GravityOff     bool    // Les jetons peuvent être posés sur n'importe quelle case vide (voir Place)
+	MaxMoves       int     // Nul au-delà de ce nombre de coups, 0 sans limite
+	RepetitionDraw bool    // Nul à la troisième occurrence d'une même position
+	StartBoard     [][]int // Position de départ posée par /api/position, nil pour un plateau vide
+	StartPlayer    int
+	BranchedFrom   json.RawMessage // Export de la partie d'origine d'une branche (voir Branch), à renvoyer tel quel à /api/game/import
+	UseOpeningBook bool
+	AllowUndo      bool
+	MaxUndos       int // Nombre d'annulations permises, 0 sans limite
+	UndosUsed      int
+	Seed           int64
+	Players        [2]string
+	Player1Color   string // Couleur des jetons du joueur 1 ("red", "yellow", "blue", "green", "purple" ou "orange")
+	Player2Color   string
+	Rated          bool
+	RatingApplied  bool
+	Swapped        bool
+	MoveTimeLimit  time.Duration
+	TurnDeadline   time.Time
+	CurrentPlayer  int
+	Mode           string
+	Difficulty     string
+	Personality    string // Objectif de l'IA ("standard", "stall" ou "mirror")
+	Lang           string
+	Variant        string
+	HumanPlayer    int
+	FirstPlayer    int // Joueur qui a joué le premier coup
+	GameOver       bool
+	Winner         int // 0=aucun, 1=J1, 2=J2, 3=nul
+	DrawOffer      int // Joueur qui propose le nul, 0 sans proposition en attente
+	StatusMessage  string
+	Moves          []Move
+	MoveCount      int // Nombre de coups joués, retraits compris
+	MoveLog        []string
+	Events         []Event
+	WinningCells   [][2]int
+	WinDirection   string  // Direction de l'alignement gagnant : "horizontal", "vertical", "diagonal-up" ou "diagonal-down"
+	LastMove       *[2]int // [ligne, colonne] du dernier coup, nil avant le premier coup
+	Stats          Stats
+	StartedAt      time.Time
+	EndedAt        time.Time
+	Version        uint64      // Incrémentée à chaque modification de la partie, y compris d'une partie à la suivante
+	LastAIDecision *AIDecision // Dernier coup de l'IA, nil si elle n'a pas encore joué ou si son coup a été annulé
+	ValidColumns   []bool
+	Heights        []int   // Nombre de jetons de chaque colonne
+	Duration       float64 // Durée de la partie en secondes
+	TimeRemaining  float64 // Temps restant au joueur attendu, en secondes (0 sans pendule)
+	MovesRemaining int     // Coups restants avant le nul imposé par MaxMoves (0 sans limite)
+	UndosRemaining int     // Annulations encore permises, -1 sans limite
+	TurnNumber     int     // Numéro du tour en cours, comme dans la feuille de match
+	// Occurrences de la position courante (plateau et joueur au trait) depuis le début, elle comprise
+	RepetitionCount int
+}
+
+// Move est un coup de l'historique
+type Move struct {
+	Col     int
+	Row     int
+	Player  int
+	Pop     bool
+	Placed  bool // Jeton posé sur la case (Row, Col) sans tomber
+	At      time.Time
+	Version uint64 // Version de la partie qui a publié le coup
+}
+
+// GameDelta est la réponse de Delta : les coups publiés depuis la version donnée,
+// ou l'état complet (Full) quand ils ne suffisent pas à décrire les changements
+type GameDelta struct {
+	Since         int         `json:"since"`
+	Version       uint64      `json:"version"`
+	Full          bool        `json:"full"`
+	GameState     *GameState  `json:"gameState"`
+	Moves         []DeltaMove `json:"moves"`
+	CurrentPlayer int         `json:"currentPlayer"`
+	GameOver      bool        `json:"gameOver"`
+	Winner        int         `json:"winner"`
+	WinningCells  [][2]int    `json:"winningCells"`
+	WinDirection  string      `json:"winDirection"`
+	StatusMessage string      `json:"statusMessage"`
+}
+
+// DeltaMove est un coup à rejouer sur le plateau local
+type DeltaMove struct {
+	Row    int  `json:"row"`
+	Col    int  `json:"col"`
+	Player int  `json:"player"`
+	Pop    bool `json:"pop"` // Retrait du jeton du bas de la colonne : la colonne descend d'une case
+}
+
+// Event est une entrée du journal de la partie
+type Event struct {
+	Time   time.Time
+	Type   string
+	Detail string
+}
+
+// Stats est le bilan de la session contre l'IA
+type Stats struct {
+	Wins   int
+	Losses int
+	Draws  int
+	Games  int
+}
+
+// GameResponse est la réponse des actions de l'API (nouvelle partie, coup...)
+type GameResponse struct {
+	Success    bool        `json:"success"`
+	Message    string      `json:"message"`
+	ErrorCode  string      `json:"errorCode,omitempty"`
+	GameState  *GameState  `json:"gameState,omitempty"`
+	Winner     int         `json:"winner,omitempty"`
+	AIMove     *AIDecision `json:"aiMove,omitempty"`     // Renseigné par AIMove
+	AlmostFull []int       `json:"almostFull,omitempty"` // Colonnes où il ne reste qu'une case, renseigné par Move et AIMove
+	DrawAnswer string      `json:"drawAnswer,omitempty"` // "accepted" ou "declined", renseigné par RespondDraw
+}
+
+// AIDecision explique le coup joué par l'IA
+type AIDecision struct {
+	Col         int          `json:"col"`
+	Player      int          `json:"player"`
+	MoveNumber  int          `json:"moveNumber"` // Rang du coup dans l'historique, à partir de 1
+	Confidence  int          `json:"confidence"` // Écart de score avec la meilleure autre colonne
+	Reason      string       `json:"reason"`
+	Score       int          `json:"score"`
+	Alternative *ColumnScore `json:"alternative,omitempty"` // Meilleure autre colonne, nil s'il n'y en avait pas
+}
+
+// ColumnScore est le score d'une colonne pour l'IA
+type ColumnScore struct {
+	Col   int `json:"col"`
+	Score int `json:"score"`
+}
+
+// AIExplanation est la réponse de AIExplanation : le dernier coup de l'IA et sa raison en clair
+type AIExplanation struct {
+	AIDecision
+	Explanation string `json:"explanation"`
+}
+
+// Client appelle l'API d'un serveur Puissance 4
+// HTTP doit conserver les cookies (voir New) pour que les appels portent sur la même partie
+// Token est le jeton d'accès d'un serveur protégé (option -auth-token), envoyé en Bearer
+// OneIndexed numérote les colonnes à partir de 1, dans les appels comme dans les réponses (en-tête X-Column-Base)
+type Client struct {
+	BaseURL    string
+	HTTP       *http.Client
+	Token      string
+	OneIndexed bool
+}
+
+// ============================================================================
+// CLIENT
+// ============================================================================
+
+// Crée un client pour le serveur donné (ex. "http://localhost:8080"),
+// avec sa propre session
+func New(baseURL string) *Client {
+	jar, _ := cookiejar.New(nil)
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		HTTP:    &http.Client{Jar: jar},
+	}
+}
+
+// Démarre une nouvelle partie dans le mode donné (MODE_TWO_PLAYER ou MODE_AI)
+func (c *Client) NewGame(ctx context.Context, mode string) (*GameResponse, error) {
+	return c.action(ctx, "/api/new-game", map[string]string{"mode": mode})
+}
+
+// Joue dans la colonne donnée (numérotée à partir de 0)
+func (c *Client) Move(ctx context.Context, col int) (*GameResponse, error) {
+	return c.action(ctx, "/api/move", map[string]int{"col": col})
+}
+
+// Pose un jeton sur la case donnée (ligne 0 en haut), dans une partie sans gravité
+func (c *Client) Place(ctx context.Context, row, col int) (*GameResponse, error) {
+	return c.action(ctx, "/api/place", map[string]int{"row": row, "col": col})
+}
+
+// Propose le nul au nom du joueur dont c'est le tour, dans une partie à deux joueurs
+func (c *Client) OfferDraw(ctx context.Context) (*GameResponse, error) {
+	return c.action(ctx, "/api/draw/offer", nil)
+}
+
+// Accepte ou refuse le nul proposé ; accepter termine la partie sur un nul
+func (c *Client) RespondDraw(ctx context.Context, accept bool) (*GameResponse, error) {
+	return c.action(ctx, "/api/draw/respond", map[string]bool{"accept": accept})
+}
+
+// Fait jouer l'IA pour le joueur dont c'est le tour
+func (c *Client) AIMove(ctx context.Context) (*GameResponse, error) {
+	return c.action(ctx, "/api/ai-move", nil)
+}
+
+// Explique le dernier coup de l'IA ; une *APIError de statut 404 si elle n'a pas encore joué
+func (c *Client) AIExplanation(ctx context.Context) (*AIExplanation, error) {
+	var explanation AIExplanation
+	if err := c.do(ctx, http.MethodGet, "/api/ai/explanation", nil, &explanation); err != nil {
+		return nil, err
+	}
+	return &explanation, nil
+}
+
+// Remplace la partie par une partie contre l'IA qui reprend les move premiers coups de la partie en cours,
+// l'IA jouant le camp au trait ; la partie d'origine reste dans le champ BranchedFrom de la nouvelle
+func (c *Client) Branch(ctx context.Context, move int) (*GameResponse, error) {
+	return c.action(ctx, "/api/branch", map[string]int{"move": move})
+}
+
+// Retourne l'état de la partie en cours
+func (c *Client) State(ctx context.Context) (*GameState, error) {
+	var state GameState
+	if err := c.do(ctx, http.MethodGet, "/api/game", nil, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Retourne les changements de la partie depuis la version since (le champ Version d'un état ou d'un delta précédent)
+func (c *Client) Delta(ctx context.Context, since uint64) (*GameDelta, error) {
+	var delta GameDelta
+	if err := c.do(ctx, http.MethodGet, "/api/game/delta?since="+strconv.FormatUint(since, 10), nil, &delta); err != nil {
+		return nil, err
+	}
+	return &delta, nil
+}
+
+// Envoie une action POST et décode sa GameResponse
+func (c *Client) action(ctx context.Context, path string, body interface{}) (*GameResponse, error) {
+	var response GameResponse
+	if err := c.do(ctx, http.MethodPost, path, body, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Exécute la requête et décode la réponse JSON dans out
+// Une réponse d'erreur est traduite en *APIError
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if c.OneIndexed {
+		req.Header.Set("X-Column-Base", "1")
+	}
+
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		return decodeError(resp, data)
+	}
+	return json.Unmarshal(data, out)
+}
+
+// Construit l'APIError d'une réponse en échec, JSON (GameResponse) ou texte brut
+func decodeError(resp *http.Response, data []byte) error {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	var response GameResponse
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") && json.Unmarshal(data, &response) == nil {
+		apiErr.Code = response.ErrorCode
+		apiErr.Message = response.Message
+		apiErr.GameState = response.GameState
+	} else {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	return apiErr
+}
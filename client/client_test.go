@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Chaque code d'erreur documenté se traduit en l'erreur correspondante, à tester avec errors.Is
+func TestErrorCodes(t *testing.T) {
+	for code, want := range codeErrors {
+		t.Run(code, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(`{"success": false, "message": "refusé", "errorCode": "` + code + `"}`))
+			}))
+			defer srv.Close()
+
+			_, err := New(srv.URL).Move(context.Background(), 3)
+			if !errors.Is(err, want) {
+				t.Fatalf("erreur %v, attendu %v", err, want)
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict || apiErr.Message != "refusé" {
+				t.Errorf("APIError inattendue : %+v", apiErr)
+			}
+		})
+	}
+}
+
+// Une erreur en texte brut (http.Error) donne une APIError sans code
+func TestPlainTextError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL).State(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "" || apiErr.Message != "Méthode non autorisée" {
+		t.Fatalf("erreur inattendue : %v", err)
+	}
+	if errors.Unwrap(err) != nil {
+		t.Errorf("une erreur sans code ne devrait correspondre à aucune erreur connue")
+	}
+}
+
+// Un contexte annulé interrompt l'appel
+func TestCanceledContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := New(srv.URL).AIMove(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("erreur %v, attendu context.Canceled", err)
+	}
+}
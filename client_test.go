@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"puissance4/client"
+)
+
+// ============================================================================
+// CLIENT GO CONTRE LE SERVEUR
+// ============================================================================
+
+// Le client pilote une partie contre l'IA de bout en bout sur les vraies routes du serveur
+func TestClientGame(t *testing.T) {
+	srv := newTestServer(t)
+	c := client.New(srv.URL)
+	ctx := context.Background()
+
+	steps := []struct {
+		name  string
+		call  func() (*client.GameResponse, error)
+		moves int
+	}{
+		{"NewGame", func() (*client.GameResponse, error) { return c.NewGame(ctx, client.MODE_AI) }, 0},
+		{"Move", func() (*client.GameResponse, error) { return c.Move(ctx, 3) }, 1},
+		{"AIMove", func() (*client.GameResponse, error) { return c.AIMove(ctx) }, 2},
+	}
+	for _, step := range steps {
+		response, err := step.call()
+		if err != nil {
+			t.Fatalf("%s : %v", step.name, err)
+		}
+		if !response.Success || response.GameState == nil || len(response.GameState.Moves) != step.moves {
+			t.Fatalf("%s : réponse %+v", step.name, response)
+		}
+	}
+
+	state, err := c.State(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Mode != client.MODE_AI || len(state.Moves) != 2 || state.Board[BOARD_ROWS-1][3] != PLAYER_1 {
+		t.Errorf("état inattendu : mode %q, %d coups, plateau %v", state.Mode, len(state.Moves), state.Board)
+	}
+	if state.Moves[1].Player != PLAYER_2 {
+		t.Errorf("le second coup est du joueur %d, attendu l'IA (%d)", state.Moves[1].Player, PLAYER_2)
+	}
+}
+
+// Les refus du serveur se testent avec errors.Is sur les erreurs du client
+func TestClientErrors(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		mode  string
+		moves []int // Coups joués avant le coup refusé
+		col   int
+		want  error
+	}{
+		{"COLUMN_FULL", client.MODE_TWO_PLAYER, []int{0, 0, 0, 0, 0, 0}, 0, client.ErrColumnFull},
+		{"GAME_OVER", client.MODE_TWO_PLAYER, []int{0, 1, 0, 1, 0, 1, 0}, 2, client.ErrGameOver},
+		{"NOT_YOUR_TURN", client.MODE_AI, []int{3}, 3, client.ErrNotYourTurn},
+		{"COLUMN_OUT_OF_RANGE", client.MODE_TWO_PLAYER, nil, 99, client.ErrColumnOutOfRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := client.New(srv.URL)
+			if _, err := c.NewGame(ctx, tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			for _, col := range tt.moves {
+				if _, err := c.Move(ctx, col); err != nil {
+					t.Fatalf("coup %d : %v", col, err)
+				}
+			}
+
+			_, err := c.Move(ctx, tt.col)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("erreur %v, attendu %v", err, tt.want)
+			}
+			var apiErr *client.APIError
+			if !errors.As(err, &apiErr) || apiErr.Code != tt.name || apiErr.GameState == nil {
+				t.Errorf("APIError inattendue : %+v", apiErr)
+			}
+		})
+	}
+}
@@ -1,19 +1,48 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	crand "crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"html/template"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
 	"log"
+	"log/slog"
+	"math"
 	"math/rand"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 // ============================================================================
 // CONSTANTS AND CONFIGURATION
 // ============================================================================
 
+// Dimensions et longueur d'alignement par défaut (Puissance 4 classique)
 const (
 	BOARD_ROWS    = 6
 	BOARD_COLS    = 7
@@ -21,48 +50,1101 @@ const (
 	PLAYER_1      = 1
 	PLAYER_2      = 2
 	PLAYER_DRAW   = 3
+	PLAYER_BOTH   = -1 // Les deux joueurs ont un alignement (impossible en partie standard)
 	CELL_EMPTY    = 0
 )
 
+// Bornes acceptées pour les variantes de plateau
+const (
+	MIN_BOARD_SIZE = 4
+	MAX_BOARD_SIZE = 12
+	MIN_WIN_LENGTH = 3
+)
+
 const (
 	GAME_MODE_TWO_PLAYER = "twoPlayer"
 	GAME_MODE_AI         = "ai"
+	GAME_MODE_AI_VS_AI   = "aiVsAi" // Simulation sans humain (/api/simulate)
+)
+
+const (
+	DIFFICULTY_EASY   = "easy"
+	DIFFICULTY_MEDIUM = "medium"
+	DIFFICULTY_HARD   = "hard"
+)
+
+// Personnalités de l'IA : l'objectif qu'elle poursuit, quelle que soit sa difficulté
+const (
+	PERSONALITY_STANDARD = "standard" // Cherche à gagner
+	PERSONALITY_STALL    = "stall"    // Fait durer la partie : évite de gagner comme de perdre, et de créer des menaces
+	PERSONALITY_MIRROR   = "mirror"   // Joue la colonne symétrique du dernier coup adverse, pour enseigner la symétrie
+)
+
+// Probabilité que l'IA facile laisse passer un coup gagnant ou une menace à bloquer,
+// pour que les débutants puissent gagner
+const EASY_BLUNDER_RATE = 0.4
+
+// Langues des messages d'état ; le français reste la langue par défaut
+const (
+	LANG_FR      = "fr"
+	LANG_EN      = "en"
+	DEFAULT_LANG = LANG_FR
+)
+
+// Couleurs de jetons proposées aux joueurs (Player1Color, Player2Color), nommées dans colorNames
+const (
+	COLOR_RED    = "red"
+	COLOR_YELLOW = "yellow"
+	COLOR_BLUE   = "blue"
+	COLOR_GREEN  = "green"
+	COLOR_PURPLE = "purple"
+	COLOR_ORANGE = "orange"
+)
+
+// Clés des messages d'état traduits (voir statusMessages)
+const (
+	MSG_GAME_OVER      = "gameOver"
+	MSG_INVALID_COLUMN = "invalidColumn"
+	MSG_COLUMN_FULL    = "columnFull"
+	MSG_NOTHING_UNDONE = "nothingUndone"
+	MSG_MOVE_UNDONE    = "moveUndone"
+	MSG_WIN_PLAYER_1   = "winPlayer1"
+	MSG_WIN_PLAYER_2   = "winPlayer2"
+	MSG_DRAW           = "draw"
+	MSG_DRAW_FULL      = "drawFull"
+	MSG_DRAW_BLOCKED   = "drawBlocked"
+	MSG_DRAW_MAX_MOVES = "drawMaxMoves"
+	MSG_DRAW_DOUBLE    = "drawDouble"
+	MSG_DRAW_REPEATED  = "drawRepeated"
+	MSG_DRAW_AGREED    = "drawAgreed"
+	MSG_RESIGN_1       = "resign1"
+	MSG_RESIGN_2       = "resign2"
+	MSG_TURN_1         = "turn1"
+	MSG_TURN_2         = "turn2"
+	MSG_TIMEOUT_1      = "timeout1"
+	MSG_TIMEOUT_2      = "timeout2"
+	MSG_SWAPPED        = "swapped"
+	MSG_UNDO_DISABLED  = "undoDisabled"
+	MSG_UNDO_EXHAUSTED = "undoExhausted"
+	MSG_AI_THINKING    = "aiThinking"
+)
+
+// Variantes de règles : en Pop Out, un joueur peut aussi retirer son propre jeton du bas d'une colonne
+const (
+	VARIANT_STANDARD = "standard"
+	VARIANT_POP_OUT  = "popout"
+)
+
+// Direction de l'alignement gagnant (GameState.WinDirection), pour choisir l'animation de la victoire
+// Le plateau a sa ligne 0 en haut : diagonal-up monte de gauche à droite, diagonal-down descend
+const (
+	WIN_HORIZONTAL    = "horizontal"
+	WIN_VERTICAL      = "vertical"
+	WIN_DIAGONAL_UP   = "diagonal-up"
+	WIN_DIAGONAL_DOWN = "diagonal-down"
+)
+
+// Règles quand un même coup aligne les deux joueurs (seul un retrait du Pop Out le permet) :
+// le joueur qui a joué gagne, ou la partie est nulle
+const (
+	DOUBLE_WIN_MOVER = "mover"
+	DOUBLE_WIN_DRAW  = "draw"
+)
+
+// Profondeurs de recherche du minimax (en demi-coups) selon la difficulté
+const (
+	MINIMAX_DEPTH_MEDIUM = 4
+	MINIMAX_WIN_SCORE    = 1000000
+	ANALYSIS_DEPTH       = MINIMAX_DEPTH_MEDIUM
+)
+
+// Poids de l'évaluation heuristique des positions (evaluateBoard), à ajuster pour régler le style de l'IA
+// Une fenêtre est un groupe de WinLength cases alignées ; "ouverte" signifie sans jeton adverse
+const (
+	EVAL_CENTER_WEIGHT     = 3 // Par jeton dans la colonne centrale
+	EVAL_OPEN_THREE_WEIGHT = 5 // Fenêtre à laquelle il ne manque qu'un jeton
+	EVAL_OPEN_TWO_WEIGHT   = 2 // Fenêtre à laquelle il manque deux jetons
+	EVAL_THREAT_PENALTY    = 4 // Fenêtre adverse à laquelle il ne manque qu'un jeton
+)
+
+// Fins de partie décidées hors du plateau, notées dans l'export (champ termination)
+const (
+	TERMINATION_RESIGN    = "resign"
+	TERMINATION_TIMEOUT   = "timeout"
+	TERMINATION_AGREEMENT = "agreement" // Nul accepté par les deux joueurs (voir agreeDraw)
+)
+
+// Résultats de la notation PGN (/api/game/pgn) : victoire des rouges, des jaunes, nul, partie en cours
+const (
+	PGN_RESULT_PLAYER_1 = "1-0"
+	PGN_RESULT_PLAYER_2 = "0-1"
+	PGN_RESULT_DRAW     = "1/2-1/2"
+	PGN_RESULT_ONGOING  = "*"
+)
+
+// Taille maximale d'une partie PGN envoyée à l'import, en octets
+const MAX_PGN_SIZE = 64 << 10
+
+// Taille maximale du corps JSON des requêtes de l'API, en octets (un export de 12x12 en Pop Out tient largement)
+const MAX_JSON_BODY_SIZE = 64 << 10
+
+// Type de contenu de /metrics : format texte d'exposition de Prometheus
+const METRICS_CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+
+// Image du plateau (/api/game/image.png) : taille d'une case et rayon d'un jeton, en pixels
+// Le plateau classique donne une image de 700x600
+const (
+	IMAGE_CELL_SIZE    = 100
+	IMAGE_TOKEN_RADIUS = 40
+	IMAGE_HALO_WIDTH   = 6 // Anneau autour des jetons de l'alignement gagnant
+)
+
+// Journal des événements d'une partie : seuls les MAX_GAME_EVENTS plus récents sont conservés
+const MAX_GAME_EVENTS = 100
+
+// Répétition des positions (GameState.RepetitionDraw) : nul à la REPETITION_DRAW_COUNT-ième occurrence
+// d'une même position ; les clés de hashBoard sont tirées d'une graine fixe, stables d'un redémarrage à l'autre
+const (
+	REPETITION_DRAW_COUNT = 3
+	ZOBRIST_SEED          = 0x50344a4f
+)
+
+// Types d'événements du journal d'une partie (/api/game/events)
+const (
+	EVENT_START   = "start"
+	EVENT_MOVE    = "move"
+	EVENT_AI      = "ai"
+	EVENT_UNDO    = "undo"
+	EVENT_RESIGN  = "resign"
+	EVENT_TIMEOUT = "timeout"
+	EVENT_SWAP    = "swap"
+	EVENT_DRAW    = "draw" // Proposition de nul, réponse, ou nul par accord
+	EVENT_BRANCH  = "branch"
+	EVENT_END     = "end"
+)
+
+// Nombre maximal de parties d'une simulation en série (/api/simulate/batch)
+const MAX_SIMULATION_BATCH = 10000
+
+// Temps de réflexion du niveau difficile : la recherche s'approfondit tant que le budget le permet
+const HARD_TIME_BUDGET = 200 * time.Millisecond
+
+// Délai maximal d'un calcul de l'IA pour une requête (coup de l'ordinateur, conseil) : au-delà,
+// ou si le client s'en va, la recherche s'arrête et l'IA joue le meilleur coup trouvé jusque-là
+const AI_REQUEST_TIMEOUT = 2 * time.Second
+
+// Raisons d'un coup de l'IA (AIDecision.Reason)
+const (
+	AI_REASON_ONLY_MOVE = "only move"       // Une seule colonne jouable
+	AI_REASON_WINS      = "wins"            // Le coup aligne immédiatement
+	AI_REASON_BLOCKS    = "blocks opponent" // Le coup occupe la case gagnante de l'adversaire
+	AI_REASON_BLUNDER   = "blunder"         // Un gain ou un blocage était possible et n'a pas été joué
+	AI_REASON_FORCED    = "forced win"      // La recherche voit un gain forcé
+	AI_REASON_LOSING    = "losing"          // La recherche ne voit que des défaites : le coup les retarde
+	AI_REASON_CLEAR     = "clear choice"    // Le coup devance nettement les autres (écart d'au moins AI_CLEAR_CHOICE_GAP)
+	AI_REASON_MARGINAL  = "marginal choice" // Choix positionnel serré
+	AI_REASON_BOOK      = "opening book"    // Coup tiré du répertoire d'ouvertures (voir openingBook)
+	AI_REASON_STALL     = "stall"           // Personnalité stall : le coup crée le moins de menaces sans gagner ni perdre
+	AI_REASON_MIRROR    = "mirror"          // Personnalité mirror : colonne symétrique du dernier coup adverse
+)
+
+// Écart de score à partir duquel un choix de l'IA est jugé net : l'équivalent de deux alignements ouverts à un jeton près
+const AI_CLEAR_CHOICE_GAP = 2 * EVAL_OPEN_THREE_WEIGHT
+
+// Niveau de l'IA utilisé pour conseiller le joueur (/api/hint)
+const HINT_DIFFICULTY = DIFFICULTY_MEDIUM
+
+// Résolution exacte d'une position (/api/solve) : profondeur en demi-coups par défaut et maximale,
+// et temps de recherche au-delà duquel l'issue est déclarée inconnue
+const (
+	SOLVE_DEFAULT_DEPTH = 10
+	SOLVE_MAX_DEPTH     = 16
+	SOLVE_TIME_BUDGET   = 2 * time.Second
+	SOLVE_WIN_SCORE     = 1000
+)
+
+// Issues possibles d'une résolution, du point de vue du joueur au trait
+const (
+	SOLVE_RESULT_WIN     = "win"
+	SOLVE_RESULT_LOSS    = "loss"
+	SOLVE_RESULT_DRAW    = "draw"
+	SOLVE_RESULT_UNKNOWN = "unknown"
+)
+
+// Formats du journal (-log-format) : texte lisible ou une ligne JSON par entrée
+const (
+	LOG_FORMAT_TEXT = "text"
+	LOG_FORMAT_JSON = "json"
+)
+
+// Longueur de l'identifiant de session recopiée dans le journal : assez pour suivre une session,
+// sans livrer le cookie complet à quiconque lit les journaux
+const LOG_SESSION_PREFIX_LENGTH = 8
+
+// Valeurs par défaut des options de ligne de commande (-addr, -templates, -static)
+const (
+	DEFAULT_ADDR          = ":8080"
+	DEFAULT_TEMPLATES_DIR = "templates"
+	DEFAULT_STATIC_DIR    = "static"
+)
+
+// Pause par défaut avant la réponse de l'IA dans l'interface HTML, pour l'effet visuel
+// Surchargeable par la variable d'environnement AI_THINK_DELAY_ENV (ex. "250ms", "0" pour désactiver)
+// ou par l'option -ai-delay, prioritaire
+const (
+	DEFAULT_AI_THINK_DELAY = 600 * time.Millisecond
+	AI_THINK_DELAY_ENV     = "PUISSANCE4_AI_THINK_DELAY"
+)
+
+// Temps accordé par défaut à chaque coup humain (pendule), 0 pour jouer sans limite
+// Surchargeable par la variable d'environnement MOVE_TIME_LIMIT_ENV (ex. "30s")
+// ou par l'option -move-time, prioritaire
+const (
+	DEFAULT_MOVE_TIME_LIMIT = 0
+	MOVE_TIME_LIMIT_ENV     = "PUISSANCE4_MOVE_TIME"
+)
+
+// Jeton d'accès exigé sur les requêtes qui modifient (POST /api/*, /game/*), vide pour un serveur ouvert
+// Fourni par la variable d'environnement AUTH_TOKEN_ENV, ou par l'option -auth-token, prioritaire
+const AUTH_TOKEN_ENV = "PUISSANCE4_AUTH_TOKEN"
+
+// Numérotation des colonnes à la frontière de l'API : à partir de 0 par défaut, comme en interne, ou à partir
+// de 1 avec l'en-tête COLUMN_BASE_HEADER ou le paramètre COLUMN_BASE_PARAM à "1" (voir requestColumnBase)
+// La réponse rappelle toujours la numérotation appliquée dans COLUMN_BASE_HEADER
+const (
+	COLUMN_BASE_HEADER = "X-Column-Base"
+	COLUMN_BASE_PARAM  = "columnBase"
+)
+
+// Délai laissé aux requêtes en cours pour se terminer à l'arrêt du serveur
+const SHUTDOWN_TIMEOUT = 10 * time.Second
+
+// Limitation des requêtes qui modifient une partie ou font réfléchir l'IA, par session (ou par adresse IP
+// sans session) : DEFAULT_RATE_LIMIT requêtes par seconde en moyenne, par rafales d'au plus DEFAULT_RATE_BURST
+// Les clients sans requête depuis RATE_LIMIT_IDLE_TIMEOUT sont oubliés
+const (
+	DEFAULT_RATE_LIMIT      = 10
+	DEFAULT_RATE_BURST      = 20
+	RATE_LIMIT_IDLE_TIMEOUT = 10 * time.Minute
+)
+
+// Durée pendant laquelle un navigateur peut réutiliser la réponse à une requête CORS préliminaire
+const CORS_MAX_AGE = 10 * time.Minute
+
+// Taille en dessous de laquelle une réponse est envoyée telle quelle : la compresser ne ferait rien gagner
+const GZIP_MIN_SIZE = 1024
+
+// Délai maximal d'écriture d'un message WebSocket avant de considérer le client perdu
+const WS_WRITE_TIMEOUT = 5 * time.Second
+
+// Flux SSE (/api/game/stream) : un commentaire est envoyé toutes les SSE_KEEPALIVE_INTERVAL pour garder
+// la connexion ouverte à travers les proxys, et le navigateur attend SSE_RETRY avant de se reconnecter
+const (
+	SSE_KEEPALIVE_INTERVAL = 30 * time.Second
+	SSE_RETRY              = 3 * time.Second
+)
+
+const (
+	SESSION_COOKIE_NAME      = "puissance4_session"
+	SESSION_IDLE_TIMEOUT     = 30 * time.Minute
+	SESSION_CLEANUP_INTERVAL = 5 * time.Minute
+)
+
+// Codes d'erreur renvoyés dans GameResponse.ErrorCode
+const (
+	ERROR_COLUMN_FULL          = "COLUMN_FULL"          // La colonne demandée est pleine (HTTP 400)
+	ERROR_COLUMN_OUT_OF_RANGE  = "COLUMN_OUT_OF_RANGE"  // La colonne n'existe pas sur ce plateau (HTTP 400)
+	ERROR_GAME_OVER            = "GAME_OVER"            // La partie est déjà terminée (HTTP 409)
+	ERROR_NOT_YOUR_TURN        = "NOT_YOUR_TURN"        // Ce n'est pas au tour de ce joueur (humain ou ordinateur) (HTTP 409)
+	ERROR_INVALID_IMPORT       = "INVALID_IMPORT"       // La partie importée est incohérente ou illégale (HTTP 400)
+	ERROR_ROOM_NOT_FOUND       = "ROOM_NOT_FOUND"       // Aucun salon ne porte ce code (HTTP 404)
+	ERROR_ROOM_FULL            = "ROOM_FULL"            // Les deux places du salon sont prises (HTTP 409)
+	ERROR_NOT_IN_ROOM          = "NOT_IN_ROOM"          // La session n'a pas rejoint ce salon (HTTP 403)
+	ERROR_POP_NOT_ALLOWED      = "POP_NOT_ALLOWED"      // Retrait demandé hors de la variante Pop Out (HTTP 400)
+	ERROR_NOT_YOUR_TOKEN       = "NOT_YOUR_TOKEN"       // Le jeton du bas de la colonne n'appartient pas au joueur (HTTP 400)
+	ERROR_PLACE_NOT_ALLOWED    = "PLACE_NOT_ALLOWED"    // Pose sur une case précise demandée dans une partie avec gravité (HTTP 400)
+	ERROR_CELL_OUT_OF_RANGE    = "CELL_OUT_OF_RANGE"    // Case hors du plateau (HTTP 400)
+	ERROR_CELL_OCCUPIED        = "CELL_OCCUPIED"        // La case visée porte déjà un jeton (HTTP 400)
+	ERROR_TIMEOUT              = "TIMEOUT"              // Le coup arrive après l'heure limite : la partie est perdue au temps (HTTP 409)
+	ERROR_SWAP_NOT_ALLOWED     = "SWAP_NOT_ALLOWED"     // L'échange n'est possible qu'en réponse au premier coup (HTTP 409)
+	ERROR_RATE_LIMITED         = "RATE_LIMITED"         // Trop de requêtes de ce client, réessayer après Retry-After (HTTP 429)
+	ERROR_INVALID_POSITION     = "INVALID_POSITION"     // La position posée est illégale, déjà gagnée ou pleine (HTTP 400)
+	ERROR_NOT_A_PLAYER         = "NOT_A_PLAYER"         // La session regarde le salon en spectatrice et ne peut pas jouer (HTTP 403)
+	ERROR_INVALID_REQUEST      = "INVALID_REQUEST"      // Le corps JSON est illisible, mal typé, incomplet ou porte un champ inconnu (HTTP 400)
+	ERROR_REQUEST_TOO_LARGE    = "REQUEST_TOO_LARGE"    // Le corps dépasse MAX_JSON_BODY_SIZE (HTTP 413)
+	ERROR_UNDO_NOT_ALLOWED     = "UNDO_NOT_ALLOWED"     // L'annulation est désactivée ou toutes les annulations ont servi (HTTP 409)
+	ERROR_AI_THINKING          = "AI_THINKING"          // L'IA prépare sa réponse : aucun coup n'est accepté d'ici là (HTTP 409)
+	ERROR_TAKEBACK_NOT_ALLOWED = "TAKEBACK_NOT_ALLOWED" // Le dernier coup n'est pas celui du joueur, ou la partie est terminée (HTTP 409)
+	ERROR_TAKEBACK_PENDING     = "TAKEBACK_PENDING"     // Une demande de reprise attend déjà la réponse de l'adversaire (HTTP 409)
+	ERROR_NO_TAKEBACK          = "NO_TAKEBACK"          // Aucune demande de reprise de l'adversaire n'attend de réponse (HTTP 409)
+	ERROR_DRAW_NOT_ALLOWED     = "DRAW_NOT_ALLOWED"     // Le nul par accord ne se propose qu'entre deux joueurs, pas contre l'IA (HTTP 409)
+	ERROR_DRAW_OFFER_PENDING   = "DRAW_OFFER_PENDING"   // Une proposition de nul attend déjà sa réponse (HTTP 409)
+	ERROR_NO_DRAW_OFFER        = "NO_DRAW_OFFER"        // Aucune proposition de nul de l'adversaire n'attend de réponse (HTTP 409)
+	ERROR_TOURNAMENT_CLOSED    = "TOURNAMENT_CLOSED"    // Le tableau est déjà tiré, ou pas encore : l'action ne vaut pas à ce stade (HTTP 409)
+	ERROR_MATCH_NOT_FOUND      = "MATCH_NOT_FOUND"      // Aucun match du tableau ne porte ce numéro (HTTP 404)
+	ERROR_MATCH_NOT_PLAYABLE   = "MATCH_NOT_PLAYABLE"   // Le match attend encore un de ses joueurs, ou il est déjà joué (HTTP 409)
+	ERROR_UNAUTHORIZED         = "UNAUTHORIZED"         // Jeton d'accès absent ou faux sur un serveur protégé (HTTP 401)
+)
+
+// Codes des salons en ligne : sans caractères ambigus (0/O, 1/I) pour être dictés facilement
+const (
+	ROOM_CODE_LENGTH   = 6
+	ROOM_CODE_ALPHABET = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// Nombre maximal de spectateurs d'un salon : au-delà, rejoindre le salon renvoie ROOM_FULL
+const MAX_ROOM_SPECTATORS = 50
+
+// Préfixe des abonnements WebSocket aux salons dans le Hub, qui les distingue des sessions
+const ROOM_HUB_PREFIX = "room:"
+
+// Réponses à une demande de reprise, diffusées dans takebackAnswer
+const (
+	TAKEBACK_ACCEPTED = "accepted"
+	TAKEBACK_DECLINED = "declined"
+)
+
+// Réponses à une proposition de nul, renvoyées (et diffusées dans un salon) dans drawAnswer
+const (
+	DRAW_ACCEPTED = "accepted"
+	DRAW_DECLINED = "declined"
+)
+
+// Fichier de sauvegarde des parties en cours
+const SAVE_FILE = "games.json"
+
+// Historique des parties terminées de toutes les sessions, une ligne JSON par partie (voir AppendHistory)
+// Le fichier ne fait que grandir : /api/history le relit ligne à ligne sans le charger en mémoire
+const HISTORY_FILE = "history.ndjson"
+
+// Classement Elo des joueurs nommés
+const (
+	RATINGS_FILE           = "ratings.json" // Fichier de sauvegarde du classement
+	ELO_K                  = 32             // Variation maximale d'un classement par partie
+	ELO_INITIAL            = 1500           // Classement d'un nouveau joueur
+	MAX_PLAYER_NAME_LENGTH = 32
+	AI_PLAYER_NAME_PREFIX  = "IA " // Nom de l'IA au classement : "IA " suivi de sa difficulté
+)
+
+// Tournoi à élimination directe (voir TournamentManager)
+const (
+	TOURNAMENT_FILE        = "tournament.json" // Fichier de sauvegarde du tableau
+	MAX_TOURNAMENT_PLAYERS = 64
+
+	TOURNAMENT_REGISTRATION = "registration" // Inscriptions ouvertes, tableau pas encore tiré
+	TOURNAMENT_RUNNING      = "running"      // Tableau tiré, matchs en cours
+	TOURNAMENT_FINISHED     = "finished"     // La finale est jouée
 )
 
 // ============================================================================
 // DATA STRUCTURES
 // ============================================================================
 
+// Board représente la grille de jeu, indexée par [ligne][colonne]
+// Les fonctions pures travaillent sur une copie obtenue avec Clone
+type Board [][]int
+
 // GameState représente l'état actuel du jeu
 type GameState struct {
-	Board         [BOARD_ROWS][BOARD_COLS]int // Grille de jeu 6x7
-	CurrentPlayer int                          // Joueur actuel (1 ou 2)
-	Mode          string                       // Mode de jeu (twoPlayer ou ai)
-	GameOver      bool                         // True si la partie est terminée
-	Winner        int                          // 0=none, 1=J1, 2=J2, 3=draw
-	StatusMessage string                       // Message d'état affiché à l'utilisateur
+	Board          Board         // Grille de jeu Rows x Cols
+	Rows           int           // Nombre de lignes du plateau
+	Cols           int           // Nombre de colonnes du plateau
+	WinLength      int           // Nombre de jetons à aligner pour gagner
+	AllowOverline  bool          // Un alignement plus long que WinLength gagne aussi (vrai par défaut) ; sinon seul un alignement exact gagne
+	DoubleWin      string        // Issue d'un coup qui aligne les deux joueurs (DOUBLE_WIN_*), le joueur qui a joué gagne par défaut
+	GravityOff     bool          // Les jetons peuvent aussi être posés sur n'importe quelle case vide (/api/place)
+	MaxMoves       int           // Nombre de coups (retraits compris) au-delà duquel la partie est nulle, 0 sans limite
+	RepetitionDraw bool          // La partie est nulle quand une position revient pour la REPETITION_DRAW_COUNT-ième fois (voir repetitionCount)
+	AllowUndo      bool          // Les annulations sont permises (vrai par défaut, voir UnmarshalJSON)
+	MaxUndos       int           // Nombre d'annulations permises, 0 sans limite
+	UndosUsed      int           // Annulations déjà faites ; contre l'IA, une annulation retire deux demi-coups mais compte une fois
+	UseOpeningBook bool          // L'IA (moyen et difficile) joue ses premiers coups depuis openingBook
+	StartBoard     Board         // Position de départ posée par /api/position, nil pour un plateau vide (voir setPosition)
+	StartPlayer    int           // Joueur au trait dans StartBoard
+	BranchedFrom   *GameExport   // Partie d'origine d'une branche (/api/branch), réimportable telle quelle ; nil sinon
+	Players        [2]string     // Noms des joueurs 1 et 2, vides pour des joueurs anonymes
+	Player1Color   string        // Couleur des jetons du joueur 1 (COLOR_*), rouge par défaut
+	Player2Color   string        // Couleur des jetons du joueur 2 (COLOR_*), jaune par défaut
+	Rated          bool          // La partie compte pour le classement Elo
+	RatingApplied  bool          // Le résultat a déjà été reporté au classement
+	Swapped        bool          // La règle du gâteau a été appliquée (voir swapSides)
+	MoveTimeLimit  time.Duration // Temps accordé à chaque coup humain, 0 sans pendule
+	TurnDeadline   time.Time     // Heure limite du coup humain attendu, zéro sans pendule ou au tour de l'IA
+	Seed           int64         // Graine des choix aléatoires de l'IA (voir moveRand)
+	FirstPlayer    int           // Joueur qui joue le premier coup (1 ou 2), le joueur 1 par défaut (voir setFirstPlayer)
+	CurrentPlayer  int           // Joueur actuel (1 ou 2)
+	Mode           string        // Mode de jeu (twoPlayer ou ai)
+	Difficulty     string        // Difficulté de l'IA (easy, medium ou hard)
+	Personality    string        // Objectif de l'IA (standard, stall ou mirror, voir PERSONALITY_*)
+	Lang           string        // Langue des messages d'état (fr ou en)
+	Variant        string        // Règles de la partie (standard ou popout)
+	HumanPlayer    int           // En mode IA, joueur incarné par l'humain (1 ou 2), l'IA jouant l'autre
+	GameOver       bool          // True si la partie est terminée
+	Winner         int           // 0=none, 1=J1, 2=J2, 3=draw
+	DrawOffer      int           // Joueur qui propose le nul en attendant la réponse de son adversaire, 0 sinon (voir offerDraw)
+	StatusMessage  string        // Message d'état affiché à l'utilisateur
+	Moves          []Move        // Historique des coups joués, du premier au dernier
+	MoveCount      int           // Nombre de coups joués (retraits compris) depuis le début ou la position imposée
+	MoveLog        []string      // Coups en notation lisible (ex. "R-c4"), un par entrée de Moves
+	Events         []Event       // Journal des événements de la partie, borné à MAX_GAME_EVENTS
+	WinningCells   [][2]int      // Cases [ligne, colonne] de l'alignement gagnant (des deux alignements pour un nul DOUBLE_WIN_DRAW), nil sinon
+	WinDirection   string        // Direction de l'alignement gagnant (WIN_*), vide sans alignement gagnant
+	LastMove       *[2]int       // Case [ligne, colonne] du dernier coup joué (bas de la colonne pour un retrait), nil avant le premier coup
+	Stats          Stats         // Bilan de la session contre l'IA, reporté d'une partie à la suivante
+	StartedAt      time.Time     // Début de la partie
+	EndedAt        time.Time     // Fin de la partie (terminée ou abandonnée pour une nouvelle), zéro si en cours
+	Version        uint64        // Nombre de modifications de la partie de la session, incrémenté par onGameUpdated et reporté d'une partie à la suivante (voir etag)
+	LastAIDecision *AIDecision   // Raisonnement du dernier coup posé par l'IA (/api/ai-move, /api/ai/explanation), nil si elle n'a pas encore joué ou si son coup a été annulé
+
+	counted    metricsMark // Ce que metrics a déjà compté de la partie (voir observeGame)
+	deltaFrom  uint64      // Plus ancienne version à partir de laquelle /api/game/delta peut décrire les changements
+	aiThinking bool        // L'IA marque sa pause avant de répondre (voir handleMove) : la partie n'accepte aucun coup
+
+	// Protège tous les champs ci-dessus : écriture pour jouer, lecture pour afficher ou encoder
+	// Ne jamais le prendre en détenant GameManager.mu, ni appeler onGameUpdated en le détenant
+	mu sync.RWMutex
+}
+
+// metricsMark retient ce qui a été compté d'une partie, pour ne compter que les nouveautés
+type metricsMark struct {
+	seen  bool // La partie a déjà été vue (et comptée comme commencée)
+	moves int  // MoveCount lors du dernier passage
+	over  bool // GameOver lors du dernier passage
+}
+
+// Move représente un coup joué
+type Move struct {
+	Col     int       // Colonne jouée
+	Row     int       // Ligne où le jeton s'est arrêté
+	Player  int       // Joueur ayant joué le coup
+	Pop     bool      // Retrait du jeton du bas de la colonne (variante Pop Out) au lieu d'un placement
+	Placed  bool      // Jeton posé directement sur la case (Row, Col), sans tomber (GravityOff)
+	At      time.Time // Heure à laquelle le coup a été joué
+	Version uint64    // Version de la partie qui a publié le coup, 0 tant que onGameUpdated ne l'a pas vu (voir delta)
+}
+
+// Event est une étape marquante de la partie, pour le débogage
+type Event struct {
+	Time   time.Time // Heure de l'événement
+	Type   string    // Type d'événement (EVENT_*)
+	Detail string    // Description libre (ex. "colonne 4 (blocks opponent)")
+}
+
+// Stats est le bilan d'une session contre l'IA, du point de vue de l'humain
+// Seules les parties menées à leur terme sont comptées : une partie abandonnée n'est pas une défaite
+type Stats struct {
+	Wins   int
+	Losses int
+	Draws  int
+	Games  int
+}
+
+// GameManager associe chaque session (cookie) à sa propre partie
+type GameManager struct {
+	mu       sync.Mutex            // Protège les maps ci-dessous (pas le contenu des parties)
+	saveMu   sync.Mutex            // Sérialise les écritures du fichier de sauvegarde
+	games    map[string]*GameState // Parties indexées par identifiant de session
+	replays  map[string]*Replay    // Relectures pas à pas en cours, par session
+	lastSeen map[string]time.Time  // Dernière activité de chaque session
+
+	historyMu sync.Mutex // Sérialise les ajouts à HISTORY_FILE, pour qu'une lecture ne voie que des lignes complètes
+}
+
+// HistoryRecord est une ligne de HISTORY_FILE : une partie terminée et sa session
+type HistoryRecord struct {
+	Session string          `json:"session"`
+	EndedAt time.Time       `json:"endedAt"` // Repris de la partie, pour filtrer sans la décoder
+	Game    json.RawMessage `json:"game"`    // GameState encodé tel que le renvoie /api/game
+}
+
+// GameOptions sont les réglages d'une nouvelle partie (voir startNewGame)
+// options() les relit sur une partie en cours, pour qu'une revanche reprenne ceux de la précédente
+type GameOptions struct {
+	Mode         string
+	Difficulty   string
+	Personality  string
+	Variant      string
+	Lang         string
+	Rows         int
+	Cols         int
+	WinLength    int
+	HumanPlayer  int
+	FirstPlayer  int
+	Player1Color string
+	Player2Color string
+	Seed         int64 // Graine de l'IA, tirée au hasard si 0
+}
+
+// Replay est la relecture pas à pas d'une partie importée
+type Replay struct {
+	game *GameState // Partie complète, validée à l'import et jamais modifiée ensuite
+	step int        // Nombre de coups appliqués dans l'étape affichée
+}
+
+// ReplayFrame est l'état d'une relecture après Step coups sur Total
+type ReplayFrame struct {
+	Step      int        `json:"step"`
+	Total     int        `json:"total"`
+	GameState *GameState `json:"gameState"`
+}
+
+// Room est une partie en ligne entre deux sessions, rejointe grâce à son code
+// Les salons vivent en mémoire uniquement : ils ne sont pas sauvegardés sur disque
+type Room struct {
+	Code       string
+	game       *GameState
+	players    map[string]int // Joueur (1 ou 2) attribué à chaque session présente
+	spectators map[string]int // Sessions spectatrices, avec leur nombre de connexions WebSocket ouvertes
+	lastSeen   time.Time      // Dernière activité d'un des joueurs ou spectateurs
+	takeback   int            // Joueur qui demande à reprendre son dernier coup, 0 sinon ; protégé par game.mu
+}
+
+// Tournament est le tableau à élimination directe renvoyé par /api/tournament
+type Tournament struct {
+	Status  string               `json:"status"`           // TOURNAMENT_REGISTRATION, TOURNAMENT_RUNNING ou TOURNAMENT_FINISHED
+	Players []string             `json:"players"`          // Inscrits, dans l'ordre d'inscription
+	Rounds  [][]*TournamentMatch `json:"rounds,omitempty"` // Tours du tableau, du premier à la finale
+	Winner  string               `json:"winner,omitempty"`
+}
+
+// TournamentMatch est un match du tableau ; un joueur vide attend le vainqueur du match précédent
+type TournamentMatch struct {
+	ID      int    `json:"id"`    // Numéro du match, de 1 au nombre de matchs, tour après tour
+	Round   int    `json:"round"` // Tour du match, 1 pour le premier
+	Player1 string `json:"player1,omitempty"`
+	Player2 string `json:"player2,omitempty"`
+	Winner  string `json:"winner,omitempty"`
+	Bye     bool   `json:"bye,omitempty"`     // Qualification d'office, faute d'adversaire au premier tour
+	Playing bool   `json:"playing,omitempty"` // Une partie du match est en cours dans une session
+}
+
+// TournamentManager tient le tableau du tournoi et les parties de session qui jouent ses matchs
+// Le résultat d'une partie liée à un match est reporté à sa fin (voir recordGame)
+type TournamentManager struct {
+	mu     sync.Mutex // Protège le tableau et les liens ; ne jamais prendre un verrou de partie en le détenant
+	saveMu sync.Mutex // Sérialise les écritures du fichier du tournoi
+	t      Tournament
+	links  map[string]tournamentLink // Match joué par la partie de chaque session
+}
+
+// tournamentLink relie la partie d'une session, reconnue à son heure de début, à un match du tableau
+type tournamentLink struct {
+	Match     int       `json:"match"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// tournamentSave est le contenu du fichier du tournoi : le tableau et les liens vers les sessions,
+// qui ne sont jamais renvoyés par l'API
+type tournamentSave struct {
+	Tournament Tournament                `json:"tournament"`
+	Links      map[string]tournamentLink `json:"links"`
+}
+
+// PlayerRating est le classement Elo d'un joueur nommé
+type PlayerRating struct {
+	Rating float64 `json:"rating"`
+	Games  int     `json:"games"`
+}
+
+// PlayerStore associe chaque nom de joueur à son classement Elo
+type PlayerStore struct {
+	mu      sync.Mutex // Protège la map ; ne jamais prendre un verrou de partie en le détenant
+	saveMu  sync.Mutex // Sérialise les écritures du fichier de classement
+	ratings map[string]PlayerRating
+}
+
+// LeaderboardEntry est une ligne du classement renvoyé par /api/leaderboard
+type LeaderboardEntry struct {
+	Name   string `json:"name"`
+	Rating int    `json:"rating"`
+	Games  int    `json:"games"`
+}
+
+// Server regroupe l'état du serveur de jeu ; ses méthodes sont les handlers HTTP
+// Construit par newServer, il permet d'instancier des serveurs indépendants (tests, template injecté)
+type Server struct {
+	games      *GameManager       // Parties des sessions
+	rooms      *RoomManager       // Salons des parties en ligne
+	players    *PlayerStore       // Classement Elo des joueurs nommés
+	tournament *TournamentManager // Tableau du tournoi en cours
+	hub        *Hub               // Abonnés WebSocket de chaque session
+	limiter    *RateLimiter       // Débit accordé à chaque client, nil sans limite
+	tmpl       *template.Template // Page principale (index.html)
+}
+
+// RateLimiter attribue à chaque client un seau de jetons : une requête consomme un jeton,
+// et les jetons se regagnent au débit rate, sans dépasser burst
+type RateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+}
+
+// tokenBucket est le seau d'un client
+type tokenBucket struct {
+	tokens float64
+	last   time.Time // Dernière mise à jour de tokens
+}
+
+// RoomManager associe chaque code de salon à son salon
+type RoomManager struct {
+	mu    sync.Mutex // Protège la map et les champs des salons (pas le contenu des parties)
+	rooms map[string]*Room
+}
+
+// Erreurs des opérations sur les salons, traduites en codes ERROR_* par writeRoomError
+var (
+	errRoomNotFound = errors.New("salon introuvable")
+	errRoomFull     = errors.New("le salon est complet")
+	errNotInRoom    = errors.New("vous n'avez pas rejoint ce salon")
+	errNotAPlayer   = errors.New("vous regardez ce salon sans y jouer")
+)
+
+// MoveError décrit un coup refusé : statut HTTP, code ERROR_* et message lisible
+type MoveError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *MoveError) Error() string {
+	return e.Message
+}
+
+// Metrics cumule les compteurs exposés par /metrics depuis le démarrage du serveur
+type Metrics struct {
+	mu           sync.Mutex
+	gamesStarted map[string]uint64 // Parties commencées, par mode
+	moves        uint64            // Coups joués dans les parties des sessions et des salons, retraits compris
+	aiMoves      uint64            // Coups calculés par l'IA, simulations comprises
+	gamesWon     [2]uint64         // Parties gagnées par le joueur 1 et le joueur 2
+	gamesDrawn   uint64
+	aiThinkCount []uint64 // Coups de l'IA par tranche de aiThinkBuckets (non cumulés), plus un pour +Inf
+	aiThinkSum   float64  // Temps de réflexion total de l'IA, en secondes
+}
+
+// Hub diffuse l'état des parties aux connexions WebSocket et aux flux SSE abonnés
+type Hub struct {
+	mu      sync.Mutex
+	clients map[string]map[*wsClient]bool        // Connexions indexées par identifiant de session
+	streams map[string]map[chan streamEvent]bool // Flux SSE indexés par identifiant de session
+	stop    chan struct{}                        // Fermé à l'arrêt du serveur pour terminer les flux SSE
+}
+
+// streamEvent est un état de partie déjà encodé, prêt à être écrit sur un flux SSE
+type streamEvent struct {
+	version uint64
+	data    []byte
+}
+
+// wsClient est une connexion WebSocket ; gorilla n'autorise qu'un écrivain à la fois
+type wsClient struct {
+	mu         sync.Mutex
+	conn       *websocket.Conn
+	columnBase int // Numérotation des colonnes demandée à l'ouverture (voir requestColumnBase)
+}
+
+// Config regroupe les réglages du serveur, fixés au démarrage
+type Config struct {
+	Addr          string        // Adresse d'écoute du serveur HTTP
+	TemplatesDir  string        // Dossier contenant index.html
+	StaticDir     string        // Dossier servi sous /static/
+	AIThinkDelay  time.Duration // Pause avant la réponse de l'IA (formulaires HTML uniquement), 0 pour aucune
+	MoveTimeLimit time.Duration // Temps accordé à chaque coup humain des nouvelles parties, 0 sans pendule
+	CORSOrigins   []string      // Origines autorisées à appeler /api/* depuis un navigateur, "*" pour toutes ; vide : même origine seulement
+	LogFormat     string        // Format du journal : LOG_FORMAT_TEXT ou LOG_FORMAT_JSON
+	RateLimit     float64       // Requêtes par seconde accordées à chaque client sur les routes limitées, 0 sans limite
+	RateBurst     int           // Rafale maximale de requêtes d'un client au-delà du débit moyen
+	AuthToken     string        // Jeton exigé sur les requêtes qui modifient, vide sans protection
+}
+
+// ColumnAnalysis décrit l'évaluation d'une colonne jouable pour un joueur
+type ColumnAnalysis struct {
+	Col        int  `json:"col"`
+	Score      int  `json:"score"`      // Score minimax du coup, du point de vue du joueur analysé
+	WouldWin   bool `json:"wouldWin"`   // Le coup gagne immédiatement
+	WouldBlock bool `json:"wouldBlock"` // Le coup occupe la case gagnante de l'adversaire
+}
+
+// MovePreview décrit les conséquences immédiates d'un coup jouable
+type MovePreview struct {
+	Col                 int  `json:"col"`
+	LandsRow            int  `json:"lands_row"`              // Ligne où le jeton tomberait
+	Wins                bool `json:"wins"`                   // Le coup gagne immédiatement
+	OpponentCanWinAfter bool `json:"opponent_can_win_after"` // L'adversaire aurait ensuite un coup gagnant
+	FillsBoard          bool `json:"fills_board"`            // Le coup remplit la dernière case du plateau
+}
+
+// Simulation est le résultat d'une partie entre deux IA
+type Simulation struct {
+	Difficulty1  string     `json:"difficulty1"`  // Niveau de l'IA qui joue les rouges
+	Difficulty2  string     `json:"difficulty2"`  // Niveau de l'IA qui joue les jaunes
+	Personality1 string     `json:"personality1"` // Personnalité de l'IA qui joue les rouges
+	Personality2 string     `json:"personality2"` // Personnalité de l'IA qui joue les jaunes
+	Moves        string     `json:"moves"`        // Coups joués, en notation d'export
+	Winner       int        `json:"winner"`
+	GameState    *GameState `json:"gameState"`
+}
+
+// BatchResult agrège une série de parties entre deux IA A et B
+type BatchResult struct {
+	Games    int     `json:"games"`
+	AWins    int     `json:"aWins"`
+	BWins    int     `json:"bWins"`
+	Draws    int     `json:"draws"`
+	AvgMoves float64 `json:"avgMoves"`
+}
+
+// HealthStatus est la réponse des sondes /healthz et /readyz
+type HealthStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// GameDelta décrit les changements de la partie depuis une version connue du client, pour /api/game/delta
+// Si les coups ajoutés ne suffisent pas à la décrire (nouvelle partie, annulation, version inconnue),
+// Full vaut true et GameState porte l'état complet
+type GameDelta struct {
+	Since         int         `json:"since"`
+	Version       uint64      `json:"version"`
+	Full          bool        `json:"full"`
+	GameState     *GameState  `json:"gameState,omitempty"`
+	Moves         []DeltaMove `json:"moves,omitempty"`
+	CurrentPlayer int         `json:"currentPlayer"`
+	GameOver      bool        `json:"gameOver"`
+	Winner        int         `json:"winner,omitempty"`
+	WinningCells  [][2]int    `json:"winningCells,omitempty"`
+	WinDirection  string      `json:"winDirection,omitempty"`
+	StatusMessage string      `json:"statusMessage"`
+}
+
+// DeltaMove est un coup publié après la version du client, à rejouer sur son plateau
+type DeltaMove struct {
+	Row    int  `json:"row"`
+	Col    int  `json:"col"`
+	Player int  `json:"player"`
+	Pop    bool `json:"pop,omitempty"` // Retrait du jeton du bas de la colonne (variante Pop Out) : la colonne descend d'une case
+}
+
+// MoveLogResponse est la feuille de match renvoyée par /api/game/log
+type MoveLogResponse struct {
+	Log        []string `json:"log"`        // Un coup par entrée (ex. "R-c4", "Y-^c2" pour un retrait)
+	Transcript string   `json:"transcript"` // Coups numérotés par paire (ex. "1. R-c4 Y-c3 2. R-c4")
+}
+
+// MoveLegality indique si un coup peut être joué, et sinon pourquoi
+type MoveLegality struct {
+	Legal  bool   `json:"legal"`
+	Reason string `json:"reason,omitempty"` // "out of range", "column full" ou "game over"
+}
+
+// Threats liste, pour chaque joueur, les colonnes où un jeton gagnerait immédiatement
+type Threats struct {
+	Player1 []int `json:"player1"`
+	Player2 []int `json:"player2"`
+}
+
+// BoardLine est un alignement complet présent sur le plateau (voir Board.completedLines)
+type BoardLine struct {
+	Player    int      `json:"player"`
+	Cells     [][2]int `json:"cells"`     // Cases [ligne, colonne] d'une extrémité à l'autre
+	Direction string   `json:"direction"` // WIN_*
+}
+
+// AIDecision explique un coup de l'IA, pour commenter la partie
+type AIDecision struct {
+	Col        int    `json:"col"`
+	Player     int    `json:"player"`     // Joueur pour lequel l'IA a joué
+	MoveNumber int    `json:"moveNumber"` // Rang du coup dans l'historique (Moves), à partir de 1
+	Confidence int    `json:"confidence"` // Écart de score entre ce coup et la meilleure autre colonne, du point de vue de l'IA ; négatif pour une bévue
+	Reason     string `json:"reason"`     // Voir les constantes AI_REASON_*
+
+	// Scores de la recherche, du point de vue de l'IA ; nuls pour un coup choisi sans évaluation
+	// (répertoire d'ouvertures, personnalité mirror)
+	Score       int          `json:"score"`
+	Alternative *ColumnScore `json:"alternative,omitempty"` // Meilleure autre colonne, absente s'il n'y en avait pas
+
+	Search *SearchStats `json:"search,omitempty"` // Statistiques de la recherche, avec ?debug=1 seulement
+}
+
+// ColumnScore est le score d'une colonne pour l'IA
+type ColumnScore struct {
+	Col   int `json:"col"`
+	Score int `json:"score"`
+}
+
+// AIExplanation est la réponse de /api/ai/explanation : le dernier coup de l'IA et sa raison en clair
+type AIExplanation struct {
+	AIDecision
+	Explanation string `json:"explanation"`
+}
+
+// SearchStats décrit la recherche derrière un coup de l'IA, pour le débogage (?debug=1)
+type SearchStats struct {
+	NodesSearched int   `json:"nodesSearched"` // Positions explorées par minimax, hors réponses de la table de transposition
+	DepthReached  int   `json:"depthReached"`  // Dernière profondeur explorée entièrement, 0 sans recherche
+	TimeMs        int64 `json:"timeMs"`        // Durée de la décision en millisecondes
+}
+
+// Hint est un conseil de coup pour le joueur dont c'est le tour
+type Hint struct {
+	Col    int          `json:"col"`
+	Reason string       `json:"reason"`           // "wins", "blocks opponent", "center" ou "neutral"
+	Search *SearchStats `json:"search,omitempty"` // Statistiques de la recherche, avec ?debug=1 seulement
+}
+
+// SolveResult est l'issue forcée de la position pour le joueur au trait (/api/solve)
+type SolveResult struct {
+	Result  string `json:"result"`   // "win", "loss", "draw" ou "unknown" si la profondeur ou le temps n'a pas suffi
+	InMoves int    `json:"in_moves"` // Coups du vainqueur jusqu'à l'alignement, 0 pour un nul ou une issue inconnue
+	BestCol int    `json:"best_col"` // Gain le plus rapide, défaite la plus lente, ou coup le plus sûr si l'issue est inconnue
+	Depth   int    `json:"depth"`    // Profondeur de la dernière recherche complète, en demi-coups
+}
+
+// GameExport est la forme partageable d'une partie, rejouable avec l'import
+type GameExport struct {
+	Moves       string    `json:"moves"` // Colonnes jouées dans l'ordre, un caractère base 36 par coup (ex. "3334", voir encodeMoves)
+	Mode        string    `json:"mode"`
+	Difficulty  string    `json:"difficulty,omitempty"`
+	Personality string    `json:"personality,omitempty"` // Absente des exports antérieurs aux personnalités : standard
+	Variant     string    `json:"variant,omitempty"`
+	Lang        string    `json:"lang,omitempty"`
+	HumanPlayer int       `json:"humanPlayer,omitempty"`  // Camp de l'humain avant un éventuel échange (voir Swapped)
+	FirstPlayer int       `json:"firstPlayer,omitempty"`  // Absent des exports antérieurs : le joueur 1 a commencé
+	Color1      string    `json:"player1Color,omitempty"` // Absentes des exports antérieurs aux couleurs : rouge et jaune
+	Color2      string    `json:"player2Color,omitempty"`
+	Rows        int       `json:"rows"`
+	Cols        int       `json:"cols"`
+	WinLength   int       `json:"win"`
+	Overline    *bool     `json:"allowOverline,omitempty"` // Absente quand un alignement plus long gagne aussi (par défaut)
+	ExactWin    bool      `json:"exactWin,omitempty"`      // Exports antérieurs à allowOverline : vrai équivaut à allowOverline false
+	DoubleWin   string    `json:"doubleWin,omitempty"`     // Absente des exports antérieurs à la règle : le joueur qui a joué gagne
+	GravityOff  bool      `json:"gravityOff,omitempty"`
+	MaxMoves    int       `json:"maxMoves,omitempty"`
+	Repetition  bool      `json:"repetitionDraw,omitempty"`
+	OpeningBook bool      `json:"openingBook,omitempty"` // Absent des exports antérieurs au répertoire : l'IA s'en passe
+	Position    string    `json:"position,omitempty"`    // Position de départ (voir encodePosition), vide pour un plateau vide
+	ToMove      int       `json:"toMove,omitempty"`      // Joueur au trait dans la position de départ
+	Swapped     bool      `json:"swapped,omitempty"`     // Règle du gâteau appliquée après le premier coup
+	Seed        int64     `json:"seed,omitempty"`
+	Winner      int       `json:"winner"`
+	Termination string    `json:"termination,omitempty"` // "resign", "timeout" ou "agreement" si la partie s'est terminée hors du plateau
+	ExportedAt  time.Time `json:"exportedAt"`
 }
 
 // GameResponse structure pour les réponses API JSON
 type GameResponse struct {
-	Success   bool       `json:"success"`
-	Message   string     `json:"message"`
-	GameState *GameState `json:"gameState,omitempty"`
-	Winner    int        `json:"winner,omitempty"`
+	Success        bool        `json:"success"`
+	Message        string      `json:"message"`
+	ErrorCode      string      `json:"errorCode,omitempty"` // Code ERROR_* lorsque Success vaut false
+	GameState      *GameState  `json:"gameState,omitempty"`
+	Winner         int         `json:"winner,omitempty"`
+	RoomCode       string      `json:"roomCode,omitempty"`       // Code du salon pour les réponses de /api/room
+	Player         int         `json:"player,omitempty"`         // Joueur attribué à la session dans le salon, absent pour un spectateur
+	Spectators     int         `json:"spectators,omitempty"`     // Nombre de spectateurs du salon
+	AIMove         *AIDecision `json:"aiMove,omitempty"`         // Coup de l'IA et ses raisons, pour /api/ai-move
+	Takeback       int         `json:"takeback,omitempty"`       // Joueur du salon qui attend la réponse à sa demande de reprise
+	TakebackAnswer string      `json:"takebackAnswer,omitempty"` // Réponse à la demande de reprise : "accepted" ou "declined"
+	DrawAnswer     string      `json:"drawAnswer,omitempty"`     // Réponse à la proposition de nul : "accepted" ou "declined"
+	AlmostFull     []int       `json:"almostFull,omitempty"`     // Colonnes où il ne reste qu'une case après le coup (et la réponse de l'IA)
 }
 
 // ============================================================================
 // GLOBAL VARIABLES
 // ============================================================================
 
-var currentGame *GameState
-var tmpl *template.Template
+var config = Config{
+	Addr:          DEFAULT_ADDR,
+	TemplatesDir:  DEFAULT_TEMPLATES_DIR,
+	StaticDir:     DEFAULT_STATIC_DIR,
+	AIThinkDelay:  DEFAULT_AI_THINK_DELAY,
+	MoveTimeLimit: DEFAULT_MOVE_TIME_LIMIT,
+	LogFormat:     LOG_FORMAT_TEXT,
+	RateLimit:     DEFAULT_RATE_LIMIT,
+	RateBurst:     DEFAULT_RATE_BURST,
+}
+
+// Messages d'état par langue, puis par clé
+// Une clé absente d'une langue retombe sur le français
+// Les messages qui désignent un joueur reçoivent le nom de sa couleur (voir playerMessage)
+var statusMessages = map[string]map[string]string{
+	LANG_FR: {
+		MSG_GAME_OVER:      "❌ La partie est terminée",
+		MSG_INVALID_COLUMN: "❌ Colonne invalide",
+		MSG_COLUMN_FULL:    "❌ Colonne pleine !",
+		MSG_NOTHING_UNDONE: "❌ Aucun coup à annuler",
+		MSG_MOVE_UNDONE:    "↩️ Coup annulé",
+		MSG_WIN_PLAYER_1:   "🎉 Le Joueur %s (Joueur 1) gagne ! 🎉",
+		MSG_WIN_PLAYER_2:   "🎉 Le Joueur %s (Joueur 2) gagne ! 🎉",
+		MSG_DRAW:           "🤝 Match nul ! Égalité parfaite ! 🤝",
+		MSG_DRAW_FULL:      "🤝 Match nul !",
+		MSG_DRAW_BLOCKED:   "🤝 Match nul : plus aucun alignement possible !",
+		MSG_DRAW_MAX_MOVES: "🤝 Match nul : nombre maximal de coups atteint !",
+		MSG_DRAW_DOUBLE:    "🤝 Match nul : les deux joueurs sont alignés !",
+		MSG_DRAW_REPEATED:  "🤝 Match nul : la même position s'est répétée trois fois !",
+		MSG_DRAW_AGREED:    "🤝 Match nul par accord des deux joueurs",
+		MSG_RESIGN_1:       "🏳️ Le Joueur %s a abandonné",
+		MSG_RESIGN_2:       "🏳️ Le Joueur %s a abandonné",
+		MSG_TURN_1:         "Au tour du Joueur %s",
+		MSG_TURN_2:         "Au tour du Joueur %s",
+		MSG_TIMEOUT_1:      "⏱️ Temps écoulé : le Joueur %s perd au temps",
+		MSG_TIMEOUT_2:      "⏱️ Temps écoulé : le Joueur %s perd au temps",
+		MSG_SWAPPED:        "🔄 Échange : les joueurs changent de couleur",
+		MSG_UNDO_DISABLED:  "❌ L'annulation est désactivée pour cette partie",
+		MSG_UNDO_EXHAUSTED: "❌ Plus aucune annulation disponible",
+		MSG_AI_THINKING:    "⏳ L'ordinateur réfléchit, patientez",
+	},
+	LANG_EN: {
+		MSG_GAME_OVER:      "❌ The game is over",
+		MSG_INVALID_COLUMN: "❌ Invalid column",
+		MSG_COLUMN_FULL:    "❌ Column full!",
+		MSG_NOTHING_UNDONE: "❌ No move to undo",
+		MSG_MOVE_UNDONE:    "↩️ Move undone",
+		MSG_WIN_PLAYER_1:   "🎉 %s (Player 1) wins! 🎉",
+		MSG_WIN_PLAYER_2:   "🎉 %s (Player 2) wins! 🎉",
+		MSG_DRAW:           "🤝 Draw! A perfect tie! 🤝",
+		MSG_DRAW_FULL:      "🤝 Draw!",
+		MSG_DRAW_BLOCKED:   "🤝 Draw: no line can be completed anymore!",
+		MSG_DRAW_MAX_MOVES: "🤝 Draw: move limit reached!",
+		MSG_DRAW_DOUBLE:    "🤝 Draw: both players completed a line!",
+		MSG_DRAW_REPEATED:  "🤝 Draw: the same position occurred three times!",
+		MSG_DRAW_AGREED:    "🤝 Draw agreed by both players",
+		MSG_RESIGN_1:       "🏳️ %s resigned",
+		MSG_RESIGN_2:       "🏳️ %s resigned",
+		MSG_TURN_1:         "%s to play",
+		MSG_TURN_2:         "%s to play",
+		MSG_TIMEOUT_1:      "⏱️ Time's up: %s loses on time",
+		MSG_TIMEOUT_2:      "⏱️ Time's up: %s loses on time",
+		MSG_SWAPPED:        "🔄 Swap: players switch colors",
+		MSG_UNDO_DISABLED:  "❌ Undo is disabled for this game",
+		MSG_UNDO_EXHAUSTED: "❌ No undo left",
+		MSG_AI_THINKING:    "⏳ The computer is thinking, please wait",
+	},
+}
+
+// Nom des couleurs de jetons par langue ; les clés de la langue par défaut forment la liste blanche
+var colorNames = map[string]map[string]string{
+	LANG_FR: {
+		COLOR_RED:    "Rouge",
+		COLOR_YELLOW: "Jaune",
+		COLOR_BLUE:   "Bleu",
+		COLOR_GREEN:  "Vert",
+		COLOR_PURPLE: "Violet",
+		COLOR_ORANGE: "Orange",
+	},
+	LANG_EN: {
+		COLOR_RED:    "Red",
+		COLOR_YELLOW: "Yellow",
+		COLOR_BLUE:   "Blue",
+		COLOR_GREEN:  "Green",
+		COLOR_PURPLE: "Purple",
+		COLOR_ORANGE: "Orange",
+	},
+}
+
+// Raisons des coups de l'IA en clair par langue, puis par AI_REASON_* ; %d reçoit la colonne (à partir de 1)
+// Une raison absente d'une langue retombe sur le français (voir explainDecision)
+var aiReasonTexts = map[string]map[string]string{
+	LANG_FR: {
+		AI_REASON_ONLY_MOVE: "Colonne %d : c'était le seul coup jouable",
+		AI_REASON_WINS:      "Colonne %d : le coup aligne immédiatement et gagne",
+		AI_REASON_BLOCKS:    "Colonne %d : le coup bloque l'alignement gagnant de l'adversaire",
+		AI_REASON_BLUNDER:   "Colonne %d : une bévue, un gain ou un blocage était possible ailleurs",
+		AI_REASON_FORCED:    "Colonne %d : la recherche voit un gain forcé",
+		AI_REASON_LOSING:    "Colonne %d : la recherche ne voit que des défaites, le coup les retarde",
+		AI_REASON_CLEAR:     "Colonne %d : choisie à l'évaluation, nettement devant les autres",
+		AI_REASON_MARGINAL:  "Colonne %d : choisie à l'évaluation, de peu devant les autres",
+		AI_REASON_BOOK:      "Colonne %d : coup tiré du répertoire d'ouvertures",
+		AI_REASON_STALL:     "Colonne %d : le coup crée le moins de menaces possible (personnalité stall)",
+		AI_REASON_MIRROR:    "Colonne %d : symétrique du dernier coup adverse (personnalité mirror)",
+	},
+	LANG_EN: {
+		AI_REASON_ONLY_MOVE: "Column %d: it was the only playable move",
+		AI_REASON_WINS:      "Column %d: the move completes a line and wins",
+		AI_REASON_BLOCKS:    "Column %d: the move blocks the opponent's winning line",
+		AI_REASON_BLUNDER:   "Column %d: a blunder, a win or a block was available elsewhere",
+		AI_REASON_FORCED:    "Column %d: the search sees a forced win",
+		AI_REASON_LOSING:    "Column %d: the search only sees losses, the move delays them",
+		AI_REASON_CLEAR:     "Column %d: chosen by evaluation, well ahead of the others",
+		AI_REASON_MARGINAL:  "Column %d: chosen by evaluation, narrowly ahead of the others",
+		AI_REASON_BOOK:      "Column %d: move taken from the opening book",
+		AI_REASON_STALL:     "Column %d: the move creates as few threats as possible (stall personality)",
+		AI_REASON_MIRROR:    "Column %d: mirrors the opponent's last move (mirror personality)",
+	},
+}
+
+// Comparaison du coup de l'IA avec la meilleure autre colonne, par langue ; reçoit le score du coup, puis la colonne et le score de l'autre
+var aiAlternativeTexts = map[string]string{
+	LANG_FR: " (score %d ; meilleure autre colonne : %d, score %d)",
+	LANG_EN: " (score %d; best other column: %d, score %d)",
+}
+
+// Teintes des jetons dans l'image du plateau, reprises de style.css
+var tokenColors = map[string]color.RGBA{
+	COLOR_RED:    {0xc9, 0x2a, 0x2a, 0xff},
+	COLOR_YELLOW: {0xf5, 0x9f, 0x00, 0xff},
+	COLOR_BLUE:   {0x18, 0x64, 0xab, 0xff},
+	COLOR_GREEN:  {0x2b, 0x8a, 0x3e, 0xff},
+	COLOR_PURPLE: {0x67, 0x41, 0xd9, 0xff},
+	COLOR_ORANGE: {0xe8, 0x59, 0x0c, 0xff},
+}
+
+// Fond du plateau et cases vides dans l'image, comme sur la page, et anneau de l'alignement gagnant
+var (
+	imageBoardColor = color.RGBA{0x1e, 0x3c, 0x72, 0xff}
+	imageEmptyColor = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	imageHaloColor  = color.RGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+// Clé du message de fin de partie pour chaque résultat
+var winnerMessageKeys = map[int]string{
+	PLAYER_1:    MSG_WIN_PLAYER_1,
+	PLAYER_2:    MSG_WIN_PLAYER_2,
+	PLAYER_DRAW: MSG_DRAW,
+}
+
+// Répertoire d'ouvertures du plateau classique (6x7, alignement de 4) : coups de l'IA pour les quatre
+// premiers demi-coups, indexés par la suite des coups déjà joués en notation d'export (voir encodeMoves)
+// Seule la plus petite d'une suite et de son miroir gauche-droite y figure (voir openingBookMove)
+// Calculés hors ligne par minimaxRoot à profondeur 11, bien au-delà de ce que permet le temps de jeu
+var openingBook = map[string]int{
+	// Premier coup des rouges, puis réponse des jaunes
+	"": 3, "0": 3, "1": 3, "2": 3, "3": 3,
+	// Deuxième coup des rouges après le centre
+	"30": 4, "31": 3, "32": 4, "33": 3,
+	// Deuxième coup des jaunes, après leur réponse au centre
+	"030": 3, "031": 3, "032": 3, "033": 3, "034": 3, "035": 3, "036": 3,
+	"130": 3, "131": 3, "132": 3, "133": 3, "134": 3, "135": 3, "136": 3,
+	"230": 3, "231": 3, "232": 2, "233": 3, "234": 3, "235": 3, "236": 3,
+	"330": 3, "331": 2, "332": 4, "333": 3,
+}
+
+// Bornes de l'histogramme du temps de réflexion de l'IA, en secondes
+var aiThinkBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// Compteurs de /metrics, communs à tout le processus comme ceux d'un client Prometheus
+var metrics = newMetrics()
+
+// Clés de hachage des positions (voir hashBoard)
+var zobrist = newZobristKeys()
+
+// Contrat OpenAPI 3 de l'API JSON, maintenu à la main dans openapi.json et intégré au binaire
+// Toute route /api/* ajoutée ou modifiée dans setupServer doit y être décrite
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
 
 // ============================================================================
 // INITIALIZATION
 // ============================================================================
 
-// Initialise le générateur aléatoire pour les mouvements de l'IA
+// Initialise le générateur aléatoire qui tire la graine des parties créées sans graine
 func init() {
 	rand.Seed(time.Now().UnixNano())
 }
@@ -72,459 +1154,8101 @@ func init() {
 // ============================================================================
 
 func main() {
-	// Initialisation du jeu avec l'état par défaut
-	initializeGame()
+	// Lecture de la configuration
+	loadConfig()
 
-	// Chargement du template HTML
-	loadTemplates()
+	// Chargement du template HTML, puis initialisation des gestionnaires de parties
+	s := newServer(loadTemplates(config.TemplatesDir))
 
-	// Configuration du serveur HTTP
-	setupServer()
+	// Arrêt propre sur SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Démarrage du serveur
-	log.Println("🎮 Serveur démarré sur http://localhost:8080")
+	server := &http.Server{Addr: config.Addr, Handler: s.setupServer(config.StaticDir, config.CORSOrigins, config.AuthToken)}
+	server.RegisterOnShutdown(s.hub.closeStreams)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+	log.Printf("🎮 Serveur démarré sur %s", config.Addr)
 	log.Println("📱 Ouvrez votre navigateur et commencez à jouer !")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+
+	<-ctx.Done()
+	stop()
+	s.shutdown(server)
 }
 
-// ============================================================================
-// SETUP FUNCTIONS
-// ============================================================================
+// Arrête le serveur en laissant SHUTDOWN_TIMEOUT aux requêtes en cours, puis sauvegarde les parties
+func (s *Server) shutdown(server *http.Server) {
+	log.Println("🛑 Arrêt du serveur...")
 
-func initializeGame() {
-	currentGame = &GameState{
-		Board:         [BOARD_ROWS][BOARD_COLS]int{},
-		CurrentPlayer: PLAYER_1,
-		Mode:          GAME_MODE_TWO_PLAYER,
-		GameOver:      false,
-		Winner:        0,
-		StatusMessage: "",
+	ctx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("⚠️ Requêtes interrompues à l'arrêt: %v", err)
 	}
-}
 
-func loadTemplates() {
-	var err error
-	tmpl, err = template.ParseFiles("templates/index.html")
-	if err != nil {
-		log.Fatal("❌ Erreur lors du chargement du template:", err)
-	}
+	// Sauvegarde après la fin des requêtes, pour inclure les derniers coups joués
+	s.games.persist()
+	log.Println("💾 Parties sauvegardées, au revoir !")
 }
 
-func setupServer() {
+// ============================================================================
+// SETUP FUNCTIONS
+// ============================================================================
+
+// Applique à la configuration les surcharges de l'environnement puis de la ligne de commande
+func loadConfig() {
+	if value := os.Getenv(AI_THINK_DELAY_ENV); value != "" {
+		delay, err := time.ParseDuration(value)
+		if err != nil || delay < 0 {
+			log.Fatalf("❌ %s invalide: %q", AI_THINK_DELAY_ENV, value)
+		}
+		config.AIThinkDelay = delay
+	}
+	if value := os.Getenv(MOVE_TIME_LIMIT_ENV); value != "" {
+		limit, err := time.ParseDuration(value)
+		if err != nil || limit < 0 {
+			log.Fatalf("❌ %s invalide: %q", MOVE_TIME_LIMIT_ENV, value)
+		}
+		config.MoveTimeLimit = limit
+	}
+	config.AuthToken = os.Getenv(AUTH_TOKEN_ENV)
+
+	flag.StringVar(&config.Addr, "addr", config.Addr, "adresse d'écoute du serveur HTTP")
+	flag.StringVar(&config.TemplatesDir, "templates", config.TemplatesDir, "dossier des templates HTML")
+	flag.StringVar(&config.StaticDir, "static", config.StaticDir, "dossier des fichiers statiques")
+	flag.DurationVar(&config.AIThinkDelay, "ai-delay", config.AIThinkDelay, "pause avant la réponse de l'IA (0 pour aucune)")
+	flag.DurationVar(&config.MoveTimeLimit, "move-time", config.MoveTimeLimit, "temps accordé à chaque coup humain (0 pour aucune limite)")
+	corsOrigins := flag.String("cors-origins", "", "origines autorisées à appeler l'API, séparées par des virgules (* pour toutes)")
+	flag.StringVar(&config.LogFormat, "log-format", config.LogFormat, "format du journal : text ou json")
+	flag.Float64Var(&config.RateLimit, "rate-limit", config.RateLimit, "requêtes par seconde accordées à chaque client sur les coups et l'IA (0 pour aucune limite)")
+	flag.IntVar(&config.RateBurst, "rate-burst", config.RateBurst, "rafale maximale de requêtes d'un client")
+	flag.StringVar(&config.AuthToken, "auth-token", config.AuthToken, "jeton exigé sur les requêtes qui modifient une partie (vide : serveur ouvert)")
+	flag.Parse()
+
+	setupLogger(config.LogFormat)
+
+	for _, origin := range strings.Split(*corsOrigins, ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			config.CORSOrigins = append(config.CORSOrigins, origin)
+		}
+	}
+
+	if config.AIThinkDelay < 0 {
+		log.Fatalf("❌ -ai-delay invalide: %v", config.AIThinkDelay)
+	}
+	if config.MoveTimeLimit < 0 {
+		log.Fatalf("❌ -move-time invalide: %v", config.MoveTimeLimit)
+	}
+	if config.RateLimit < 0 {
+		log.Fatalf("❌ -rate-limit invalide: %v", config.RateLimit)
+	}
+	if config.RateBurst < 1 {
+		log.Fatalf("❌ -rate-burst invalide: %d", config.RateBurst)
+	}
+
+	log.Printf("⚙️ Configuration: adresse %s, templates %s, statiques %s, délai de l'IA %v, temps par coup %v",
+		config.Addr, config.TemplatesDir, config.StaticDir, config.AIThinkDelay, config.MoveTimeLimit)
+	if len(config.CORSOrigins) > 0 {
+		log.Printf("🌍 API ouverte aux origines: %s", strings.Join(config.CORSOrigins, ", "))
+	}
+	if config.AuthToken != "" {
+		log.Println("🔒 Requêtes qui modifient réservées aux porteurs du jeton d'accès")
+	}
+}
+
+// Installe le journal structuré (log/slog) dans le format demandé
+// Les messages de log.Printf passent aussi par lui, au niveau INFO
+func setupLogger(format string) {
+	var handler slog.Handler
+	switch format {
+	case LOG_FORMAT_TEXT:
+		handler = slog.NewTextHandler(os.Stderr, nil)
+	case LOG_FORMAT_JSON:
+		handler = slog.NewJSONHandler(os.Stderr, nil)
+	default:
+		log.Fatalf("❌ -log-format invalide: %q (%s ou %s)", format, LOG_FORMAT_TEXT, LOG_FORMAT_JSON)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// Crée le serveur de jeu : gestionnaires de parties, de salons et de classement, diffusion temps réel
+// Les parties et le classement sauvegardés sont restaurés, et le ménage des sessions inactives démarre
+func newServer(tmpl *template.Template) *Server {
+	s := &Server{
+		games:      newGameManager(),
+		rooms:      newRoomManager(),
+		players:    newPlayerStore(),
+		tournament: newTournamentManager(),
+		hub:        newHub(),
+		tmpl:       tmpl,
+	}
+
+	// Reprise des parties sauvegardées avant le dernier arrêt
+	saved, err := LoadGames(SAVE_FILE)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		log.Println("📂 Aucune sauvegarde trouvée, démarrage à neuf")
+	case err != nil:
+		log.Printf("⚠️ Sauvegarde illisible, démarrage à neuf: %v", err)
+	default:
+		s.games.restore(saved)
+		log.Printf("📂 %d partie(s) restaurée(s)", len(saved))
+	}
+
+	ratings, err := LoadRatings(RATINGS_FILE)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		log.Printf("⚠️ Classement illisible, démarrage à neuf: %v", err)
+	default:
+		s.players.ratings = ratings
+		log.Printf("🏆 %d joueur(s) classé(s)", len(ratings))
+	}
+
+	tournament, err := LoadTournament(TOURNAMENT_FILE)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		log.Printf("⚠️ Tournoi illisible, démarrage à neuf: %v", err)
+	default:
+		s.tournament.restore(tournament)
+		log.Printf("🏅 Tournoi restauré : %d inscrit(s)", len(tournament.Tournament.Players))
+	}
+
+	if config.RateLimit > 0 {
+		s.limiter = newRateLimiter(config.RateLimit, config.RateBurst)
+		go s.limiter.runEviction(SESSION_CLEANUP_INTERVAL)
+	}
+
+	go s.games.runEviction(SESSION_CLEANUP_INTERVAL)
+	go s.rooms.runEviction(SESSION_CLEANUP_INTERVAL)
+	return s
+}
+
+func loadTemplates(dir string) *template.Template {
+	tmpl, err := template.ParseFiles(filepath.Join(dir, "index.html"))
+	if err != nil {
+		log.Fatal("❌ Erreur lors du chargement du template:", err)
+	}
+	return tmpl
+}
+
+// Enregistre les routes du serveur sur un nouveau multiplexeur
+func (s *Server) setupServer(staticDir string, corsOrigins []string, authToken string) http.Handler {
 	mux := http.NewServeMux()
 
 	// Fichiers statiques (CSS, images, etc.)
-	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(staticDir))))
 
 	// Routes principales du jeu
-	mux.HandleFunc("/", serveIndex)
-	mux.HandleFunc("/game/mode", handleModeChange)
-	mux.HandleFunc("/game/move", handleMove)
-	mux.HandleFunc("/game/new", handleNewGame)
+	// s.limit protège les routes qui modifient une partie ou font réfléchir l'IA ; les lectures, les sondes
+	// et les fichiers statiques restent libres
+	mux.HandleFunc("/", s.serveIndex)
+	mux.HandleFunc("/game/mode", s.limit(s.handleModeChange))
+	mux.HandleFunc("/game/move", s.limit(s.handleMove))
+	mux.HandleFunc("/game/new", s.limit(s.handleNewGame))
+	mux.HandleFunc("/game/undo", s.limit(s.handleUndo))
 
 	// API JSON (compatibilité ascendante)
-	mux.HandleFunc("/api/game", getGameStateAPI)
-	mux.HandleFunc("/api/new-game", newGameAPI)
-	mux.HandleFunc("/api/move", handleMoveAPI)
-	mux.HandleFunc("/api/ai-move", aiMoveAPI)
+	mux.HandleFunc("/api/game", s.getGameStateAPI)
+	mux.HandleFunc("/api/new-game", s.limit(s.newGameAPI))
+	mux.HandleFunc("/api/move", s.limit(s.handleMoveAPI))
+	mux.HandleFunc("/api/move/legal", s.moveLegalAPI)
+	mux.HandleFunc("/api/ai-move", s.limit(s.aiMoveAPI))
+	mux.HandleFunc("/api/ai/explanation", s.aiExplanationAPI)
+	mux.HandleFunc("/api/simulate", s.limit(s.simulateAPI))
+	mux.HandleFunc("/api/simulate/batch", s.limit(s.simulateBatchAPI))
+	mux.HandleFunc("/api/pop", s.limit(s.popAPI))
+	mux.HandleFunc("/api/place", s.limit(s.placeAPI))
+	mux.HandleFunc("/api/undo", s.limit(s.undoAPI))
+	mux.HandleFunc("/api/resign", s.limit(s.resignAPI))
+	mux.HandleFunc("/api/draw/offer", s.limit(s.drawOfferAPI))
+	mux.HandleFunc("/api/draw/respond", s.limit(s.drawRespondAPI))
+	mux.HandleFunc("/api/swap", s.limit(s.swapAPI))
+	mux.HandleFunc("/api/analyze", s.limit(s.analyzeAPI))
+	mux.HandleFunc("/api/hint", s.limit(s.hintAPI))
+	mux.HandleFunc("/api/solve", s.limit(s.solveAPI))
+	mux.HandleFunc("/api/moves/preview", s.previewMovesAPI)
+	mux.HandleFunc("/api/threats", s.threatsAPI)
+	mux.HandleFunc("/api/lines", s.linesAPI)
+	mux.HandleFunc("/api/game/export", s.exportGameAPI)
+	mux.HandleFunc("/api/game/ascii", s.asciiGameAPI)
+	mux.HandleFunc("/api/game/image.png", s.imageGameAPI)
+	mux.HandleFunc("/api/game/log", s.moveLogAPI)
+	mux.HandleFunc("/api/history", s.historyAPI)
+	mux.HandleFunc("/api/game/delta", s.gameDeltaAPI)
+	mux.HandleFunc("/api/game/stream", s.gameStreamAPI)
+	mux.HandleFunc("/api/game/events", s.gameEventsAPI)
+	mux.HandleFunc("/api/game/import", s.limit(s.importGameAPI))
+	mux.HandleFunc("/api/game/pgn", s.limit(s.pgnGameAPI))
+	mux.HandleFunc("/api/position", s.limit(s.positionAPI))
+	mux.HandleFunc("/api/branch", s.limit(s.branchAPI))
+	mux.HandleFunc("/api/replay/start", s.limit(s.startReplayAPI))
+	mux.HandleFunc("/api/replay/next", s.limit(func(w http.ResponseWriter, r *http.Request) { s.stepReplayAPI(w, r, 1) }))
+	mux.HandleFunc("/api/replay/prev", s.limit(func(w http.ResponseWriter, r *http.Request) { s.stepReplayAPI(w, r, -1) }))
+	mux.HandleFunc("/api/stats", s.statsAPI)
+	mux.HandleFunc("/api/stats/reset", s.limit(s.resetStatsAPI))
+	mux.HandleFunc("/api/leaderboard", s.leaderboardAPI)
+	mux.HandleFunc("/api/tournament", s.tournamentAPI)
+	mux.HandleFunc("/api/tournament/", s.limit(s.tournamentActionAPI))
+	mux.HandleFunc("/api/openapi.json", s.openAPIAPI)
+
+	// Parties en ligne : /api/room crée un salon, /api/room/{code}[/join|/move|/resign|/leave] l'utilise
+	mux.HandleFunc("/api/room", s.limit(s.createRoomAPI))
+	mux.HandleFunc("/api/room/", s.limit(s.roomAPI))
+
+	// Sondes pour un répartiteur de charge ou Kubernetes, indépendantes des parties
+	mux.HandleFunc("/healthz", s.healthzAPI)
+	mux.HandleFunc("/readyz", s.readyzAPI)
+
+	// Mesures au format Prometheus
+	mux.HandleFunc("/metrics", s.metricsAPI)
+
+	// Temps réel : diffusion de l'état à chaque coup
+	mux.HandleFunc("/ws", s.limit(s.handleWebSocket))
+
+	return logHandler(gzipHandler(corsHandler(authHandler(mux, authToken), corsOrigins)), mux)
+}
+
+// Champs ajoutés au journal de la requête en cours par les gestionnaires (voir logAttrs)
+type requestLog struct {
+	attrs []slog.Attr
+}
+
+type requestLogKey struct{}
+
+// Complète la ligne de journal de la requête, écrite une fois la réponse envoyée
+func logAttrs(r *http.Request, attrs ...slog.Attr) {
+	if entry, ok := r.Context().Value(requestLogKey{}).(*requestLog); ok {
+		entry.attrs = append(entry.attrs, attrs...)
+	}
+}
+
+// Journalise chaque requête en une ligne structurée : méthode, chemin, route, statut et durée,
+// plus les champs ajoutés par le gestionnaire (session, colonne, issue du coup...)
+// mux sert à retrouver la route qui a traité la requête
+func logHandler(next http.Handler, mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &requestLog{}
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		_, route := mux.Handler(r)
+
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), requestLogKey{}, entry)))
+
+		level := slog.LevelInfo
+		if recorder.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		attrs := append([]slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("handler", route),
+			slog.Int("status", recorder.status),
+			slog.Duration("latency", time.Since(start)),
+		}, entry.attrs...)
+		slog.LogAttrs(r.Context(), level, "requête", attrs...)
+	})
+}
+
+// ResponseWriter qui retient le statut de la réponse pour le journal
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if !s.wroteHeader {
+		s.status = status
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(data []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(data)
+}
+
+// Hijack laisse passer la connexion WebSocket ; elle est journalisée à sa fermeture
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack non supporté")
+	}
+	s.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+// Flush laisse passer l'envoi immédiat des événements d'un flux SSE
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Ajoute les en-têtes CORS aux réponses de /api/* pour les origines autorisées,
+// et répond directement aux requêtes préliminaires (OPTIONS) de ces origines
+// Sans origine autorisée, le gestionnaire est inchangé : seul le même domaine peut appeler l'API
+func corsHandler(next http.Handler, origins []string) http.Handler {
+	if len(origins) == 0 {
+		return next
+	}
+
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[origin] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !strings.HasPrefix(r.URL.Path, "/api/") || !(allowed[origin] || allowed["*"]) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Add("Vary", "Origin")
+		if allowed[origin] {
+			// Origine nommée : le cookie de session peut accompagner les requêtes
+			header.Set("Access-Control-Allow-Origin", origin)
+			header.Set("Access-Control-Allow-Credentials", "true")
+		} else {
+			header.Set("Access-Control-Allow-Origin", "*")
+		}
+		header.Set("Access-Control-Expose-Headers", COLUMN_BASE_HEADER)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			header.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			header.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+COLUMN_BASE_HEADER)
+			header.Set("Access-Control-Max-Age", strconv.Itoa(int(CORS_MAX_AGE.Seconds())))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Exige le jeton d'accès sur les requêtes qui modifient (toute méthode autre que GET, HEAD et OPTIONS)
+// de /api/* et /game/*, et à l'ouverture des WebSockets (/ws, /api/room/{code}/ws), qui acceptent des coups ;
+// les lectures, la page, les fichiers statiques et les sondes restent publics
+// Le jeton se présente en "Authorization: Bearer <jeton>", ou comme mot de passe d'une authentification
+// Basic (nom quelconque), que les navigateurs demandent d'eux-mêmes pour les formulaires de la page
+// Sans jeton configuré, le gestionnaire est inchangé
+func authHandler(next http.Handler, token string) http.Handler {
+	if token == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		protected := strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/game/")
+		switch {
+		case websocket.IsWebSocketUpgrade(r):
+			protected = true
+		case r.Method == http.MethodGet, r.Method == http.MethodHead, r.Method == http.MethodOptions:
+			protected = false
+		}
+		if !protected || subtle.ConstantTimeCompare([]byte(requestToken(r)), []byte(token)) == 1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		logAttrs(r, slog.String("outcome", ERROR_UNAUTHORIZED))
+		w.Header().Add("WWW-Authenticate", `Bearer realm="puissance4"`)
+		w.Header().Add("WWW-Authenticate", `Basic realm="puissance4"`)
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			writeAPIError(w, http.StatusUnauthorized, ERROR_UNAUTHORIZED, "Jeton d'accès absent ou invalide", nil)
+			return
+		}
+		http.Error(w, "Jeton d'accès absent ou invalide", http.StatusUnauthorized)
+	})
+}
+
+// Jeton d'accès présenté par la requête : en-tête Bearer, ou mot de passe Basic ; vide si aucun
+func requestToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+	return ""
+}
+
+// Compresse les réponses en gzip pour les clients qui l'acceptent (Accept-Encoding)
+// Les réponses de moins de GZIP_MIN_SIZE octets, les requêtes partielles (Range)
+// et la connexion WebSocket passent sans compression
+func gzipHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) || r.Header.Get("Range") != "" || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer gw.Close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// Indique si le client accepte une réponse compressée en gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, param, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		// "gzip;q=0" signifie que le client refuse gzip
+		if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+			q, err := strconv.ParseFloat(value, 64)
+			return err == nil && q > 0
+		}
+		return true
+	}
+	return false
+}
+
+// ResponseWriter qui retient le début de la réponse pour décider de la compresser :
+// tant que GZIP_MIN_SIZE octets n'ont pas été écrits, rien n'est envoyé au client
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	buf         []byte
+	gz          *gzip.Writer
+	wroteHeader bool // L'en-tête a été transmis au ResponseWriter d'origine
+	hijacked    bool
+}
+
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	if g.wroteHeader {
+		return
+	}
+	g.status = status
+}
+
+func (g *gzipResponseWriter) Write(data []byte) (int, error) {
+	if g.gz != nil {
+		return g.gz.Write(data)
+	}
+	if g.wroteHeader {
+		return g.ResponseWriter.Write(data)
+	}
+
+	g.buf = append(g.buf, data...)
+	if len(g.buf) >= GZIP_MIN_SIZE {
+		if err := g.flushBuffer(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// Envoie l'en-tête et le début retenu, compressé si la réponse s'y prête
+func (g *gzipResponseWriter) flushBuffer() error {
+	header := g.Header()
+	if header.Get("Content-Type") == "" && len(g.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(g.buf))
+	}
+
+	compress := len(g.buf) >= GZIP_MIN_SIZE &&
+		header.Get("Content-Encoding") == "" &&
+		g.status != http.StatusPartialContent &&
+		g.status != http.StatusNoContent &&
+		g.status != http.StatusNotModified
+	if compress {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		header.Del("Accept-Ranges")
+	}
+
+	g.ResponseWriter.WriteHeader(g.status)
+	g.wroteHeader = true
+
+	data := g.buf
+	g.buf = nil
+	if compress {
+		g.gz = gzip.NewWriter(g.ResponseWriter)
+		_, err := g.gz.Write(data)
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	_, err := g.ResponseWriter.Write(data)
+	return err
+}
+
+// Termine la réponse : envoie ce qui a été retenu et la fin du flux gzip
+func (g *gzipResponseWriter) Close() {
+	if g.hijacked {
+		return
+	}
+	if !g.wroteHeader {
+		g.flushBuffer()
+	}
+	if g.gz != nil {
+		g.gz.Close()
+	}
+}
+
+// Hijack laisse passer une éventuelle connexion WebSocket servie derrière le compresseur
+func (g *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := g.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack non supporté")
+	}
+	g.hijacked = true
+	return hijacker.Hijack()
+}
 
-	http.DefaultServeMux = mux
+// Flush envoie sans attendre ce qui a été écrit, compressé ou non, pour les flux SSE
+func (g *gzipResponseWriter) Flush() {
+	if !g.wroteHeader {
+		g.flushBuffer()
+	}
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	if flusher, ok := g.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
 }
 
 // ============================================================================
-// HTTP HANDLERS - PAGES HTML
+// SESSIONS - GESTION DES PARTIES MULTIPLES
 // ============================================================================
 
-// Affiche la page principale du jeu
-func serveIndex(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/" {
-		http.NotFound(w, r)
+// Crée un gestionnaire de parties vide
+func newGameManager() *GameManager {
+	return &GameManager{
+		games:    make(map[string]*GameState),
+		replays:  make(map[string]*Replay),
+		lastSeen: make(map[string]time.Time),
+	}
+}
+
+// Retourne la partie de la session, en la créant si elle n'existe pas encore
+func (m *GameManager) get(sessionID string) *GameState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	game, ok := m.games[sessionID]
+	if !ok {
+		game = startNewGame(defaultGameOptions(DEFAULT_LANG))
+		m.games[sessionID] = game
+	}
+	m.lastSeen[sessionID] = time.Now()
+	return game
+}
+
+// Indique si la session a déjà une partie, sans en créer
+func (m *GameManager) exists(sessionID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.games[sessionID]
+	return ok
+}
+
+// Indique si game est toujours la partie de la session (elle a pu être remplacée entre-temps)
+func (m *GameManager) isCurrent(sessionID string, game *GameState) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.games[sessionID] == game
+}
+
+// Remplace la partie de la session par une nouvelle partie
+// Le bilan de la partie remplacée est reporté sur la nouvelle
+func (m *GameManager) reset(sessionID string, game *GameState) *GameState {
+	m.mu.Lock()
+	previous := m.games[sessionID]
+	m.mu.Unlock()
+
+	// Hors de m.mu : on ne prend jamais game.mu en détenant m.mu
+	if previous != nil {
+		previous.mu.Lock()
+		game.Stats = previous.Stats
+		// La version continue celle de la partie remplacée : un client qui la connaît reçoit l'état complet
+		game.Version = previous.Version
+		game.deltaFrom = previous.Version + 1
+		// Une partie abandonnée en cours de route est close à l'heure de son remplacement
+		if previous.EndedAt.IsZero() {
+			previous.EndedAt = time.Now()
+		}
+		previous.mu.Unlock()
+	}
+
+	m.mu.Lock()
+	m.games[sessionID] = game
+	m.lastSeen[sessionID] = time.Now()
+	m.mu.Unlock()
+
+	// La partie remplacée rejoint l'historique de la session si elle était terminée
+	if previous != nil && previous != game {
+		m.archive(sessionID, previous)
+	}
+	return game
+}
+
+// Ajoute la partie à l'historique de la session (HISTORY_FILE) si elle est terminée, en journalisant les erreurs
+// La partie ne doit plus être celle de la session : une fois archivée, elle n'évolue plus
+func (m *GameManager) archive(sessionID string, game *GameState) {
+	game.mu.RLock()
+	if !game.GameOver {
+		game.mu.RUnlock()
+		return
+	}
+	record := HistoryRecord{Session: sessionID, EndedAt: game.EndedAt}
+	data, err := json.Marshal(game)
+	game.mu.RUnlock()
+	if err == nil {
+		record.Game = data
+		m.historyMu.Lock()
+		err = AppendHistory(HISTORY_FILE, record)
+		m.historyMu.Unlock()
+	}
+	if err != nil {
+		log.Printf("❌ Erreur d'archivage de la partie: %v", err)
+	}
+}
+
+// Envoie à send, de la plus ancienne à la plus récente, les parties terminées de la session archivées
+// dans HISTORY_FILE, finies à partir de since, dans la limite de limit (0 sans limite)
+// Retourne le nombre de parties envoyées ; un historique encore vide n'est pas une erreur
+func (m *GameManager) streamHistory(sessionID string, since time.Time, limit int, send func(json.RawMessage) error) (int, error) {
+	// Seules les lignes complètes à cet instant sont lues : un ajout concurrent reste hors de portée
+	m.historyMu.Lock()
+	info, err := os.Stat(HISTORY_FILE)
+	m.historyMu.Unlock()
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := os.Open(HISTORY_FILE)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	sent := 0
+	reader := bufio.NewReader(io.LimitReader(file, info.Size()))
+	for limit == 0 || sent < limit {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var record HistoryRecord
+			if jsonErr := json.Unmarshal(line, &record); jsonErr != nil {
+				return sent, jsonErr
+			}
+			if record.Session == sessionID && !record.EndedAt.Before(since) {
+				if sendErr := send(record.Game); sendErr != nil {
+					return sent, sendErr
+				}
+				sent++
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// Démarre une relecture pour la session, à l'étape 0 (plateau vide)
+func (m *GameManager) startReplay(sessionID string, game *GameState) Replay {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	replay := &Replay{game: game}
+	m.replays[sessionID] = replay
+	m.lastSeen[sessionID] = time.Now()
+	return *replay
+}
+
+// Avance (delta > 0) ou recule (delta < 0) la relecture de la session, sans sortir de ses bornes
+// Retourne une copie de la relecture à la nouvelle étape, ou false si aucune relecture n'est en cours
+func (m *GameManager) stepReplay(sessionID string, delta int) (Replay, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	replay := m.replays[sessionID]
+	if replay == nil {
+		return Replay{}, false
+	}
+	replay.step = min(max(replay.step+delta, 0), len(replay.game.Moves))
+	m.lastSeen[sessionID] = time.Now()
+	return *replay, true
+}
+
+// Supprime les parties inactives depuis plus de SESSION_IDLE_TIMEOUT
+// Retourne le nombre de parties supprimées
+func (m *GameManager) evictIdle(now time.Time) int {
+	m.mu.Lock()
+	evicted := make(map[string]*GameState)
+	for id, seen := range m.lastSeen {
+		if now.Sub(seen) > SESSION_IDLE_TIMEOUT {
+			evicted[id] = m.games[id]
+			delete(m.games, id)
+			delete(m.replays, id)
+			delete(m.lastSeen, id)
+		}
+	}
+	m.mu.Unlock()
+
+	// Hors de m.mu : une partie terminée rejoint l'historique de sa session avant d'être oubliée
+	for id, game := range evicted {
+		if game != nil {
+			m.archive(id, game)
+		}
+	}
+	return len(evicted)
+}
+
+// Nettoie périodiquement les parties inactives (à lancer dans une goroutine)
+func (m *GameManager) runEviction(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if n := m.evictIdle(now); n > 0 {
+			log.Printf("🧹 %d partie(s) inactive(s) supprimée(s)", n)
+		}
+	}
+}
+
+// Remplace les parties gérées par celles restaurées depuis le disque
+func (m *GameManager) restore(saved map[string]*GameState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for id, game := range saved {
+		m.games[id] = game
+		m.lastSeen[id] = now
+	}
+}
+
+// Sauvegarde toutes les parties sur le disque, en journalisant les erreurs
+func (m *GameManager) persist() {
+	// Une seule sauvegarde à la fois, pour qu'un état ancien n'écrase pas un état récent
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+
+	m.mu.Lock()
+	snapshot := make(map[string]*GameState, len(m.games))
+	for id, game := range m.games {
+		snapshot[id] = game
+	}
+	m.mu.Unlock()
+
+	if err := SaveGames(SAVE_FILE, snapshot); err != nil {
+		log.Printf("❌ Erreur de sauvegarde: %v", err)
+	}
+}
+
+// Retourne l'identifiant de session du client, en émettant un cookie à la première visite
+func getSessionID(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(SESSION_COOKIE_NAME); err == nil && cookie.Value != "" {
+		logAttrs(r, slog.String("session", sessionLogID(cookie.Value)))
+		return cookie.Value
+	}
+
+	id := newSessionID()
+	logAttrs(r, slog.String("session", sessionLogID(id)), slog.Bool("new_session", true))
+	http.SetCookie(w, &http.Cookie{
+		Name:     SESSION_COOKIE_NAME,
+		Value:    id,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return id
+}
+
+// Début de l'identifiant de session, pour le journal
+func sessionLogID(id string) string {
+	if len(id) > LOG_SESSION_PREFIX_LENGTH {
+		return id[:LOG_SESSION_PREFIX_LENGTH]
+	}
+	return id
+}
+
+// Génère un identifiant de session aléatoire
+func newSessionID() string {
+	buf := make([]byte, 16)
+	if _, err := crand.Read(buf); err != nil {
+		log.Fatal("❌ Impossible de générer un identifiant de session:", err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// Détermine la langue des messages : paramètre ?lang= d'abord, puis en-tête Accept-Language
+// Les langues sont essayées dans l'ordre de l'en-tête ; à défaut, on parle français
+func requestLang(r *http.Request) string {
+	if lang := strings.ToLower(r.URL.Query().Get("lang")); statusMessages[lang] != nil {
+		return lang
+	}
+
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		lang := strings.ToLower(strings.SplitN(tag, "-", 2)[0])
+		if statusMessages[lang] != nil {
+			return lang
+		}
+	}
+	return DEFAULT_LANG
+}
+
+// ============================================================================
+// ROOMS - PARTIES EN LIGNE ENTRE DEUX SESSIONS
+// ============================================================================
+
+// Crée un gestionnaire de salons vide
+func newRoomManager() *RoomManager {
+	return &RoomManager{rooms: make(map[string]*Room)}
+}
+
+// Crée un salon dont la session devient le joueur 1
+// Les messages d'état suivent la langue du créateur
+func (m *RoomManager) create(sessionID, lang string) *Room {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	code := newRoomCode()
+	for m.rooms[code] != nil {
+		code = newRoomCode()
+	}
+
+	room := &Room{
+		Code:       code,
+		game:       startNewGame(defaultGameOptions(lang)),
+		players:    map[string]int{sessionID: PLAYER_1},
+		spectators: make(map[string]int),
+		lastSeen:   time.Now(),
+	}
+	m.rooms[code] = room
+	return room
+}
+
+// Fait entrer la session dans le salon, à la première place libre
+// Une session déjà présente retrouve sa place ; le salon complet, elle devient spectatrice (joueur 0)
+func (m *RoomManager) join(code, sessionID string) (*Room, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	room := m.rooms[code]
+	if room == nil {
+		return nil, 0, errRoomNotFound
+	}
+	room.lastSeen = time.Now()
+
+	if player, ok := room.players[sessionID]; ok {
+		return room, player, nil
+	}
+
+	taken := make(map[int]bool, len(room.players))
+	for _, player := range room.players {
+		taken[player] = true
+	}
+	for _, player := range []int{PLAYER_1, PLAYER_2} {
+		if !taken[player] {
+			delete(room.spectators, sessionID)
+			room.players[sessionID] = player
+			return room, player, nil
+		}
+	}
+
+	if err := room.addSpectator(sessionID); err != nil {
+		return nil, 0, err
+	}
+	return room, 0, nil
+}
+
+// Inscrit la session parmi les spectateurs, dans la limite de MAX_ROOM_SPECTATORS
+// L'appelant doit détenir RoomManager.mu
+func (room *Room) addSpectator(sessionID string) error {
+	if _, ok := room.spectators[sessionID]; ok {
+		return nil
+	}
+	if len(room.spectators) >= MAX_ROOM_SPECTATORS {
+		return errRoomFull
+	}
+	room.spectators[sessionID] = 0
+	return nil
+}
+
+// Ouvre une connexion WebSocket de la session sur le salon et retourne son joueur
+// Une session qui n'a pas de place y entre en spectatrice (joueur 0), le temps de la connexion
+func (m *RoomManager) watch(code, sessionID string) (*Room, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	room := m.rooms[code]
+	if room == nil {
+		return nil, 0, errRoomNotFound
+	}
+	room.lastSeen = time.Now()
+
+	if player, ok := room.players[sessionID]; ok {
+		return room, player, nil
+	}
+	if err := room.addSpectator(sessionID); err != nil {
+		return nil, 0, err
+	}
+	room.spectators[sessionID]++
+	return room, 0, nil
+}
+
+// Ferme une connexion WebSocket ouverte par watch
+// Un spectateur est retiré du salon quand sa dernière connexion se ferme
+func (m *RoomManager) unwatch(room *Room, sessionID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	connections, ok := room.spectators[sessionID]
+	if !ok {
+		return
+	}
+	if connections <= 1 {
+		delete(room.spectators, sessionID)
+		return
+	}
+	room.spectators[sessionID] = connections - 1
+}
+
+// Indique si la session regarde le salon en spectatrice
+func (m *RoomManager) spectating(room *Room, sessionID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := room.spectators[sessionID]
+	return ok
+}
+
+// Nombre de spectateurs du salon
+func (m *RoomManager) spectatorCount(room *Room) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(room.spectators)
+}
+
+// Retourne le salon et le joueur de la session (0 si elle n'y est pas)
+func (m *RoomManager) lookup(code, sessionID string) (*Room, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	room := m.rooms[code]
+	if room == nil {
+		return nil, 0, errRoomNotFound
+	}
+	room.lastSeen = time.Now()
+	return room, room.players[sessionID], nil
+}
+
+// Fait sortir la session du salon ; le salon disparaît quand plus aucun joueur n'y est
+func (m *RoomManager) leave(code, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	room := m.rooms[code]
+	if room == nil {
+		return errRoomNotFound
+	}
+	if _, ok := room.spectators[sessionID]; ok {
+		delete(room.spectators, sessionID)
+		return nil
+	}
+	if _, ok := room.players[sessionID]; !ok {
+		return errNotInRoom
+	}
+
+	delete(room.players, sessionID)
+	if len(room.players) == 0 {
+		delete(m.rooms, code)
+	}
+	return nil
+}
+
+// Supprime les salons inactifs depuis plus de SESSION_IDLE_TIMEOUT, abandonnés sans /leave
+// Retourne le nombre de salons supprimés
+func (m *RoomManager) evictIdle(now time.Time) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	evicted := 0
+	for code, room := range m.rooms {
+		if now.Sub(room.lastSeen) > SESSION_IDLE_TIMEOUT {
+			delete(m.rooms, code)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// Nettoie périodiquement les salons inactifs (à lancer dans une goroutine)
+func (m *RoomManager) runEviction(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if n := m.evictIdle(now); n > 0 {
+			log.Printf("🧹 %d salon(s) inactif(s) supprimé(s)", n)
+		}
+	}
+}
+
+// Génère un code de salon aléatoire
+func newRoomCode() string {
+	buf := make([]byte, ROOM_CODE_LENGTH)
+	if _, err := crand.Read(buf); err != nil {
+		log.Fatal("❌ Impossible de générer un code de salon:", err)
+	}
+	for i, b := range buf {
+		buf[i] = ROOM_CODE_ALPHABET[int(b)%len(ROOM_CODE_ALPHABET)]
+	}
+	return string(buf)
+}
+
+// ============================================================================
+// RATINGS - CLASSEMENT ELO
+// ============================================================================
+
+// Crée un classement vide
+func newPlayerStore() *PlayerStore {
+	return &PlayerStore{ratings: make(map[string]PlayerRating)}
+}
+
+// Met à jour le classement des deux joueurs après une partie, puis le sauvegarde
+// scoreA vaut 1 si A gagne, 0 s'il perd et 0.5 en cas de match nul
+func (s *PlayerStore) recordGame(nameA, nameB string, scoreA float64) {
+	s.mu.Lock()
+	a, b := s.rating(nameA), s.rating(nameB)
+	// Ce que l'un gagne, l'autre le perd
+	delta := ELO_K * (scoreA - eloExpected(a.Rating, b.Rating))
+	a.Rating += delta
+	b.Rating -= delta
+	a.Games++
+	b.Games++
+	s.ratings[nameA], s.ratings[nameB] = a, b
+
+	snapshot := make(map[string]PlayerRating, len(s.ratings))
+	for name, rating := range s.ratings {
+		snapshot[name] = rating
+	}
+	s.mu.Unlock()
+
+	// Une seule sauvegarde à la fois ; l'état écrit est au moins aussi récent que celui d'avant
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	if err := SaveRatings(RATINGS_FILE, snapshot); err != nil {
+		log.Printf("❌ Erreur de sauvegarde du classement: %v", err)
+	}
+}
+
+// Retourne le classement d'un joueur, ELO_INITIAL s'il n'a jamais joué
+// L'appelant doit détenir s.mu
+func (s *PlayerStore) rating(name string) PlayerRating {
+	if rating, ok := s.ratings[name]; ok {
+		return rating
+	}
+	return PlayerRating{Rating: ELO_INITIAL}
+}
+
+// Retourne les joueurs du meilleur classement au moins bon (par nom à égalité)
+func (s *PlayerStore) leaderboard() []LeaderboardEntry {
+	s.mu.Lock()
+	entries := make([]LeaderboardEntry, 0, len(s.ratings))
+	for name, rating := range s.ratings {
+		entries = append(entries, LeaderboardEntry{Name: name, Rating: int(math.Round(rating.Rating)), Games: rating.Games})
+	}
+	s.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Rating != entries[j].Rating {
+			return entries[i].Rating > entries[j].Rating
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+// Retourne une copie du classement de tous les joueurs
+func (s *PlayerStore) snapshot() map[string]PlayerRating {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := make(map[string]PlayerRating, len(s.ratings))
+	for name, rating := range s.ratings {
+		snapshot[name] = rating
+	}
+	return snapshot
+}
+
+// Score attendu du joueur classé ratingA face au joueur classé ratingB (formule Elo)
+func eloExpected(ratingA, ratingB float64) float64 {
+	return 1 / (1 + math.Pow(10, (ratingB-ratingA)/400))
+}
+
+// Reporte au classement le résultat d'une partie classée qui vient de se terminer
+// Seul le premier résultat compte : une annulation suivie d'une autre fin ne reclasse pas
+func (s *PlayerStore) applyRating(game *GameState) {
+	game.mu.Lock()
+	if !game.GameOver || !game.Rated || game.RatingApplied {
+		game.mu.Unlock()
+		return
+	}
+	game.RatingApplied = true
+	names, winner := game.Players, game.Winner
+	game.mu.Unlock()
+
+	// Hors du verrou de la partie : on ne prend jamais s.mu en détenant game.mu
+	scoreA := 0.5
+	switch winner {
+	case PLAYER_1:
+		scoreA = 1
+	case PLAYER_2:
+		scoreA = 0
+	}
+	s.recordGame(names[0], names[1], scoreA)
+}
+
+// Renseigne les noms des joueurs et décide si la partie compte pour le classement Elo
+// À deux, la partie est classée dès que les deux joueurs sont nommés ; contre l'IA, seulement
+// sur demande (rated), l'IA étant classée sous le nom "IA " suivi de sa difficulté
+// À appeler avant de partager la partie, une fois la difficulté normalisée par newGameState
+func (g *GameState) setPlayers(names [2]string, rated bool) error {
+	for i, name := range names {
+		name = strings.TrimSpace(name)
+		if len(name) > MAX_PLAYER_NAME_LENGTH {
+			return fmt.Errorf("nom de joueur trop long (%d caractères au plus)", MAX_PLAYER_NAME_LENGTH)
+		}
+		if strings.HasPrefix(name, AI_PLAYER_NAME_PREFIX) {
+			return fmt.Errorf("le nom %q est réservé à l'IA", name)
+		}
+		names[i] = name
+	}
+
+	if g.Mode == GAME_MODE_AI {
+		human := names[g.HumanPlayer-1]
+		if rated && human == "" {
+			return errors.New("une partie classée contre l'IA demande le nom du joueur")
+		}
+		names[g.aiPlayer()-1] = AI_PLAYER_NAME_PREFIX + g.Difficulty
+	} else {
+		if rated && (names[0] == "" || names[1] == "") {
+			return errors.New("une partie classée demande le nom des deux joueurs")
+		}
+		rated = names[0] != "" && names[1] != ""
+		if rated && names[0] == names[1] {
+			return errors.New("les deux joueurs doivent avoir des noms différents")
+		}
+	}
+
+	g.Players = names
+	g.Rated = rated
+	return nil
+}
+
+// ============================================================================
+// TOURNAMENT - TOURNOI À ÉLIMINATION DIRECTE
+// ============================================================================
+
+// Crée un tournoi vide, inscriptions ouvertes
+func newTournamentManager() *TournamentManager {
+	return &TournamentManager{
+		t:     Tournament{Status: TOURNAMENT_REGISTRATION, Players: []string{}},
+		links: make(map[string]tournamentLink),
+	}
+}
+
+// Reprend le tournoi sauvegardé avant le dernier arrêt
+func (m *TournamentManager) restore(save tournamentSave) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.t = save.Tournament
+	if m.t.Status == "" {
+		m.t.Status = TOURNAMENT_REGISTRATION
+	}
+	if m.t.Players == nil {
+		m.t.Players = []string{}
+	}
+	m.links = save.Links
+	if m.links == nil {
+		m.links = make(map[string]tournamentLink)
+	}
+}
+
+// Inscrit un joueur au tournoi, tant que le tableau n'est pas tiré
+func (m *TournamentManager) register(name string) *MoveError {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "":
+		return &MoveError{http.StatusBadRequest, ERROR_INVALID_REQUEST, "Nom du joueur manquant"}
+	case len(name) > MAX_PLAYER_NAME_LENGTH:
+		return &MoveError{http.StatusBadRequest, ERROR_INVALID_REQUEST, fmt.Sprintf("Nom de joueur trop long (%d caractères au plus)", MAX_PLAYER_NAME_LENGTH)}
+	case strings.HasPrefix(name, AI_PLAYER_NAME_PREFIX):
+		return &MoveError{http.StatusBadRequest, ERROR_INVALID_REQUEST, fmt.Sprintf("Le nom %q est réservé à l'IA", name)}
+	}
+
+	m.mu.Lock()
+	switch {
+	case m.t.Status != TOURNAMENT_REGISTRATION:
+		m.mu.Unlock()
+		return &MoveError{http.StatusConflict, ERROR_TOURNAMENT_CLOSED, "Les inscriptions sont closes"}
+	case len(m.t.Players) >= MAX_TOURNAMENT_PLAYERS:
+		m.mu.Unlock()
+		return &MoveError{http.StatusConflict, ERROR_TOURNAMENT_CLOSED, fmt.Sprintf("Le tournoi est complet (%d joueurs)", MAX_TOURNAMENT_PLAYERS)}
+	}
+	for _, player := range m.t.Players {
+		if player == name {
+			m.mu.Unlock()
+			return &MoveError{http.StatusBadRequest, ERROR_INVALID_REQUEST, fmt.Sprintf("%s est déjà inscrit", name)}
+		}
+	}
+	m.t.Players = append(m.t.Players, name)
+	m.mu.Unlock()
+
+	m.persist()
+	return nil
+}
+
+// Tire le tableau : les inscrits sont classés par Elo (ratings), puis placés comme des têtes de série,
+// la première rencontrant la dernière ; à un nombre d'inscrits qui n'est pas une puissance de deux,
+// les meilleures têtes de série passent le premier tour d'office
+func (m *TournamentManager) start(ratings map[string]PlayerRating) *MoveError {
+	m.mu.Lock()
+	switch {
+	case m.t.Status != TOURNAMENT_REGISTRATION:
+		m.mu.Unlock()
+		return &MoveError{http.StatusConflict, ERROR_TOURNAMENT_CLOSED, "Le tableau est déjà tiré"}
+	case len(m.t.Players) < 2:
+		m.mu.Unlock()
+		return &MoveError{http.StatusConflict, ERROR_TOURNAMENT_CLOSED, "Il faut au moins deux inscrits"}
+	}
+
+	seeds := append([]string{}, m.t.Players...)
+	sort.SliceStable(seeds, func(i, j int) bool {
+		return tournamentRating(ratings, seeds[i]) > tournamentRating(ratings, seeds[j])
+	})
+
+	size := 2
+	for size < len(seeds) {
+		size *= 2
+	}
+
+	// Ordre des têtes de série dans le tableau : 1 8 4 5 2 7 3 6 pour huit places
+	order := []int{1}
+	for len(order) < size {
+		next := make([]int, 0, 2*len(order))
+		for _, seed := range order {
+			next = append(next, seed, 2*len(order)+1-seed)
+		}
+		order = next
+	}
+
+	id := 0
+	m.t.Rounds = nil
+	for matches := size / 2; matches >= 1; matches /= 2 {
+		round := make([]*TournamentMatch, matches)
+		for i := range round {
+			id++
+			round[i] = &TournamentMatch{ID: id, Round: len(m.t.Rounds) + 1}
+		}
+		m.t.Rounds = append(m.t.Rounds, round)
+	}
+	for i, match := range m.t.Rounds[0] {
+		if seed := order[2*i]; seed <= len(seeds) {
+			match.Player1 = seeds[seed-1]
+		}
+		if seed := order[2*i+1]; seed <= len(seeds) {
+			match.Player2 = seeds[seed-1]
+		}
+		if match.Player2 == "" {
+			match.Bye = true
+			m.advance(match, match.Player1)
+		}
+	}
+	m.t.Status = TOURNAMENT_RUNNING
+	m.mu.Unlock()
+
+	m.persist()
+	return nil
+}
+
+// Classement Elo d'un inscrit, ELO_INITIAL s'il n'a jamais joué
+func tournamentRating(ratings map[string]PlayerRating, name string) float64 {
+	if rating, ok := ratings[name]; ok {
+		return rating.Rating
+	}
+	return ELO_INITIAL
+}
+
+// Retourne le match de numéro id, nil s'il n'existe pas
+// L'appelant doit détenir m.mu
+func (m *TournamentManager) match(id int) *TournamentMatch {
+	for _, round := range m.t.Rounds {
+		for _, match := range round {
+			if match.ID == id {
+				return match
+			}
+		}
+	}
+	return nil
+}
+
+// Vérifie que le match id peut être joué : ses deux joueurs sont connus et il n'a pas de vainqueur
+// L'appelant doit détenir m.mu
+func (m *TournamentManager) playableMatch(id int) (*TournamentMatch, *MoveError) {
+	if m.t.Status != TOURNAMENT_RUNNING {
+		return nil, &MoveError{http.StatusConflict, ERROR_TOURNAMENT_CLOSED, "Aucun tournoi n'est en cours"}
+	}
+	match := m.match(id)
+	switch {
+	case match == nil:
+		return nil, &MoveError{http.StatusNotFound, ERROR_MATCH_NOT_FOUND, fmt.Sprintf("Aucun match n°%d", id)}
+	case match.Winner != "":
+		return nil, &MoveError{http.StatusConflict, ERROR_MATCH_NOT_PLAYABLE, "Ce match est déjà joué"}
+	case match.Player1 == "" || match.Player2 == "":
+		return nil, &MoveError{http.StatusConflict, ERROR_MATCH_NOT_PLAYABLE, "Ce match attend encore un de ses joueurs"}
+	}
+	return match, nil
+}
+
+// Retourne les joueurs du match id, s'il peut être joué
+func (m *TournamentManager) matchPlayers(id int) ([2]string, *MoveError) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	match, err := m.playableMatch(id)
+	if err != nil {
+		return [2]string{}, err
+	}
+	return [2]string{match.Player1, match.Player2}, nil
+}
+
+// Relie la partie de la session, commencée à startedAt entre players, au match id
+// Le match a pu être joué entre-temps : il est revérifié
+func (m *TournamentManager) link(id int, players [2]string, sessionID string, startedAt time.Time) *MoveError {
+	m.mu.Lock()
+	match, err := m.playableMatch(id)
+	if err == nil && (match.Player1 != players[0] || match.Player2 != players[1]) {
+		err = &MoveError{http.StatusConflict, ERROR_MATCH_NOT_PLAYABLE, "Les joueurs du match ont changé"}
+	}
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	m.links[sessionID] = tournamentLink{Match: id, StartedAt: startedAt}
+	m.mu.Unlock()
+
+	m.persist()
+	return nil
+}
+
+// Reporte le résultat d'un match joué hors du serveur, ou corrige celui d'une partie liée
+// winner doit être l'un des deux joueurs du match
+func (m *TournamentManager) report(id int, winner string) *MoveError {
+	m.mu.Lock()
+	match, err := m.playableMatch(id)
+	if err == nil && winner != match.Player1 && winner != match.Player2 {
+		err = &MoveError{http.StatusBadRequest, ERROR_INVALID_REQUEST, fmt.Sprintf("%s ne joue pas ce match", winner)}
+	}
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	m.advance(match, winner)
+	m.mu.Unlock()
+
+	m.persist()
+	return nil
+}
+
+// Reporte au tableau la fin de la partie d'une session liée à un match
+// Un nul ne qualifie personne : le match reste à rejouer
+func (m *TournamentManager) recordGame(sessionID string, game *GameState) {
+	// Hors de m.mu : on ne prend jamais game.mu en le détenant
+	game.mu.RLock()
+	over, winner, players, startedAt := game.GameOver, game.Winner, game.Players, game.StartedAt
+	game.mu.RUnlock()
+
+	m.mu.Lock()
+	link, ok := m.links[sessionID]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+	if startedAt.After(link.StartedAt) {
+		// La session est passée à une autre partie : celle du match est abandonnée
+		delete(m.links, sessionID)
+		m.mu.Unlock()
+		m.persist()
+		return
+	}
+	// Une partie plus ancienne est celle que la partie du match va remplacer
+	if !over || !startedAt.Equal(link.StartedAt) {
+		m.mu.Unlock()
+		return
+	}
+
+	delete(m.links, sessionID)
+	match, err := m.playableMatch(link.Match)
+	if err == nil && (winner == PLAYER_1 || winner == PLAYER_2) && players == [2]string{match.Player1, match.Player2} {
+		m.advance(match, players[winner-1])
+	}
+	m.mu.Unlock()
+
+	m.persist()
+}
+
+// Donne le match à winner et le qualifie pour le tour suivant, ou lui donne le tournoi après la finale
+// Les parties encore liées au match ne comptent plus
+// L'appelant doit détenir m.mu
+func (m *TournamentManager) advance(match *TournamentMatch, winner string) {
+	match.Winner = winner
+	for sessionID, link := range m.links {
+		if link.Match == match.ID {
+			delete(m.links, sessionID)
+		}
+	}
+
+	if match.Round == len(m.t.Rounds) {
+		m.t.Winner = winner
+		m.t.Status = TOURNAMENT_FINISHED
+		log.Printf("🏅 Tournoi remporté par %s", winner)
+		return
+	}
+
+	// Le match i du tour alimente le match i/2 du tour suivant, à la place 1 ou 2 selon sa parité
+	index := 0
+	for i, other := range m.t.Rounds[match.Round-1] {
+		if other == match {
+			index = i
+		}
+	}
+	next := m.t.Rounds[match.Round][index/2]
+	if index%2 == 0 {
+		next.Player1 = winner
+	} else {
+		next.Player2 = winner
+	}
+}
+
+// Vide le tableau et rouvre les inscriptions
+func (m *TournamentManager) reset() {
+	m.mu.Lock()
+	m.t = Tournament{Status: TOURNAMENT_REGISTRATION, Players: []string{}}
+	m.links = make(map[string]tournamentLink)
+	m.mu.Unlock()
+
+	m.persist()
+}
+
+// Retourne une copie du tableau, chaque match indiquant s'il se joue dans une session
+func (m *TournamentManager) snapshot() Tournament {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.copyTournament()
+}
+
+// Copie profonde du tableau, que l'appelant peut encoder sans verrou
+// L'appelant doit détenir m.mu
+func (m *TournamentManager) copyTournament() Tournament {
+	playing := make(map[int]bool, len(m.links))
+	for _, link := range m.links {
+		playing[link.Match] = true
+	}
+
+	t := m.t
+	t.Players = append([]string{}, m.t.Players...)
+	t.Rounds = make([][]*TournamentMatch, len(m.t.Rounds))
+	for i, round := range m.t.Rounds {
+		t.Rounds[i] = make([]*TournamentMatch, len(round))
+		for j, match := range round {
+			copied := *match
+			copied.Playing = playing[match.ID]
+			t.Rounds[i][j] = &copied
+		}
+	}
+	if len(t.Rounds) == 0 {
+		t.Rounds = nil
+	}
+	return t
+}
+
+// Sauvegarde le tableau et ses liens
+func (m *TournamentManager) persist() {
+	m.mu.Lock()
+	save := tournamentSave{Tournament: m.copyTournament(), Links: make(map[string]tournamentLink, len(m.links))}
+	for sessionID, link := range m.links {
+		save.Links[sessionID] = link
+	}
+	m.mu.Unlock()
+
+	// Une seule sauvegarde à la fois ; l'état écrit est au moins aussi récent que celui d'avant
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+	if err := SaveTournament(TOURNAMENT_FILE, save); err != nil {
+		log.Printf("❌ Erreur de sauvegarde du tournoi: %v", err)
+	}
+}
+
+// ============================================================================
+// RATE LIMITING - LIMITATION DU DÉBIT PAR CLIENT
+// ============================================================================
+
+// Crée un limiteur accordant rate requêtes par seconde à chaque client, par rafales d'au plus burst
+func newRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Consomme un jeton du client s'il en reste
+// Sinon, retourne false et le délai avant que le prochain jeton soit disponible
+func (l *RateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// Oublie les clients sans requête depuis RATE_LIMIT_IDLE_TIMEOUT : leur seau serait plein de toute façon
+func (l *RateLimiter) evictIdle(now time.Time) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := 0
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.last) > RATE_LIMIT_IDLE_TIMEOUT {
+			delete(l.buckets, key)
+			n++
+		}
+	}
+	return n
+}
+
+// Nettoie périodiquement les seaux des clients inactifs (à lancer dans une goroutine)
+func (l *RateLimiter) runEviction(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		l.evictIdle(now)
+	}
+}
+
+// Identifie le client pour la limitation : sa session si le serveur la connaît, sinon son adresse IP
+// Un cookie inventé à chaque requête retombe ainsi sur l'adresse IP ; l'en-tête X-Forwarded-For
+// est ignoré, n'importe quel client pouvant le falsifier
+func (s *Server) rateLimitKey(r *http.Request) string {
+	if cookie, err := r.Cookie(SESSION_COOKIE_NAME); err == nil && s.games.exists(cookie.Value) {
+		return "session:" + cookie.Value
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// Applique la limitation de débit au gestionnaire : au-delà, la requête est refusée avec 429
+// et un en-tête Retry-After (JSON sur /api/*, texte sur les formulaires)
+func (s *Server) limit(next http.HandlerFunc) http.HandlerFunc {
+	if s.limiter == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := s.limiter.allow(s.rateLimitKey(r), time.Now())
+		if ok {
+			next(w, r)
+			return
+		}
+
+		logAttrs(r, slog.String("outcome", ERROR_RATE_LIMITED))
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			writeAPIError(w, http.StatusTooManyRequests, ERROR_RATE_LIMITED, "Trop de requêtes, réessayez dans un instant", nil)
+			return
+		}
+		http.Error(w, "Trop de requêtes, réessayez dans un instant", http.StatusTooManyRequests)
+	}
+}
+
+// Applique la limitation de débit à un coup reçu sur un WebSocket, au compte du client comme limit :
+// la connexion n'est limitée qu'à son ouverture, chacun de ses messages l'est ici
+// Retourne le refus RATE_LIMITED à renvoyer sur la connexion, ou nil si le coup peut être joué
+func (s *Server) limitMessage(r *http.Request) *MoveError {
+	if s.limiter == nil {
+		return nil
+	}
+	if ok, _ := s.limiter.allow(s.rateLimitKey(r), time.Now()); ok {
+		return nil
+	}
+	return &MoveError{http.StatusTooManyRequests, ERROR_RATE_LIMITED, "Trop de requêtes, réessayez dans un instant"}
+}
+
+// ============================================================================
+// METRICS - MESURES AU FORMAT PROMETHEUS
+// ============================================================================
+
+func newMetrics() *Metrics {
+	return &Metrics{
+		gamesStarted: make(map[string]uint64),
+		aiThinkCount: make([]uint64, len(aiThinkBuckets)+1),
+	}
+}
+
+// Compte ce qui a changé dans la partie depuis son dernier passage : début, coups joués, fin
+// Appelé par onGameUpdated et broadcastRoom, game.mu détenu en écriture
+// Un coup annulé puis rejoué compte deux fois, une partie finie de nouveau après annulation aussi
+func (m *Metrics) observeGame(g *GameState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mark := &g.counted
+	if !mark.seen {
+		// Une partie importée ou posée arrive avec ses coups : seuls les suivants sont comptés
+		m.gamesStarted[g.Mode]++
+		*mark = metricsMark{seen: true, moves: g.MoveCount}
+	}
+
+	if g.MoveCount > mark.moves {
+		m.moves += uint64(g.MoveCount - mark.moves)
+	}
+	mark.moves = g.MoveCount
+
+	if g.GameOver && !mark.over {
+		switch g.Winner {
+		case PLAYER_1, PLAYER_2:
+			m.gamesWon[g.Winner-1]++
+		case PLAYER_DRAW:
+			m.gamesDrawn++
+		}
+	}
+	mark.over = g.GameOver
+}
+
+// Compte un coup de l'IA et son temps de réflexion
+func (m *Metrics) observeAIMove(elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seconds := elapsed.Seconds()
+	m.aiMoves++
+	m.aiThinkSum += seconds
+	bucket := sort.SearchFloat64s(aiThinkBuckets, seconds)
+	m.aiThinkCount[bucket]++
+}
+
+// Écrit les compteurs au format texte d'exposition de Prometheus
+func (m *Metrics) writeTo(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	modes := make([]string, 0, len(m.gamesStarted))
+	for mode := range m.gamesStarted {
+		modes = append(modes, mode)
+	}
+	sort.Strings(modes)
+
+	fmt.Fprintln(w, "# HELP games_started_total Parties commencées, par mode.")
+	fmt.Fprintln(w, "# TYPE games_started_total counter")
+	for _, mode := range modes {
+		fmt.Fprintf(w, "games_started_total{mode=%q} %d\n", mode, m.gamesStarted[mode])
+	}
+
+	fmt.Fprintln(w, "# HELP moves_total Coups joués, retraits compris.")
+	fmt.Fprintln(w, "# TYPE moves_total counter")
+	fmt.Fprintf(w, "moves_total %d\n", m.moves)
+
+	fmt.Fprintln(w, "# HELP ai_moves_total Coups calculés par l'IA, simulations comprises.")
+	fmt.Fprintln(w, "# TYPE ai_moves_total counter")
+	fmt.Fprintf(w, "ai_moves_total %d\n", m.aiMoves)
+
+	fmt.Fprintln(w, "# HELP games_won_total Parties gagnées, par joueur.")
+	fmt.Fprintln(w, "# TYPE games_won_total counter")
+	for i, won := range m.gamesWon {
+		fmt.Fprintf(w, "games_won_total{player=\"%d\"} %d\n", i+1, won)
+	}
+
+	fmt.Fprintln(w, "# HELP games_drawn_total Parties nulles.")
+	fmt.Fprintln(w, "# TYPE games_drawn_total counter")
+	fmt.Fprintf(w, "games_drawn_total %d\n", m.gamesDrawn)
+
+	fmt.Fprintln(w, "# HELP ai_think_seconds Temps de réflexion de l'IA par coup.")
+	fmt.Fprintln(w, "# TYPE ai_think_seconds histogram")
+	var cumulative uint64
+	for i, bound := range aiThinkBuckets {
+		cumulative += m.aiThinkCount[i]
+		fmt.Fprintf(w, "ai_think_seconds_bucket{le=\"%s\"} %d\n", strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	cumulative += m.aiThinkCount[len(aiThinkBuckets)]
+	fmt.Fprintf(w, "ai_think_seconds_bucket{le=\"+Inf\"} %d\n", cumulative)
+	fmt.Fprintf(w, "ai_think_seconds_sum %s\n", strconv.FormatFloat(m.aiThinkSum, 'g', -1, 64))
+	fmt.Fprintf(w, "ai_think_seconds_count %d\n", cumulative)
+}
+
+// ============================================================================
+// PERSISTENCE - SAUVEGARDE SUR DISQUE
+// ============================================================================
+
+// Écrit les parties au format JSON dans le fichier indiqué
+// L'écriture passe par un fichier temporaire pour ne jamais laisser une sauvegarde tronquée
+func SaveGames(path string, games map[string]*GameState) error {
+	encoded := make(map[string]json.RawMessage, len(games))
+	for id, game := range games {
+		game.mu.RLock()
+		data, err := json.Marshal(game)
+		game.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+		encoded[id] = data
+	}
+
+	data, err := json.Marshal(encoded)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// Écrit data dans le fichier indiqué en passant par un fichier temporaire renommé,
+// pour ne jamais laisser une sauvegarde tronquée
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Écrit le classement au format JSON, par le même fichier temporaire que SaveGames
+func SaveRatings(path string, ratings map[string]PlayerRating) error {
+	data, err := json.Marshal(ratings)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// Écrit le tableau du tournoi et ses liens vers les sessions au format JSON
+func SaveTournament(path string, save tournamentSave) error {
+	data, err := json.Marshal(save)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// Ajoute une partie terminée à la fin du fichier d'historique, sur une seule ligne
+// L'appelant sérialise les ajouts (voir GameManager.historyMu)
+func AppendHistory(path string, record HistoryRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Relit le tournoi sauvegardé par SaveTournament
+// Retourne une erreur os.ErrNotExist si aucun tournoi n'existe
+func LoadTournament(path string) (tournamentSave, error) {
+	var save tournamentSave
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return save, err
+	}
+	if err := json.Unmarshal(data, &save); err != nil {
+		return save, err
+	}
+	return save, nil
+}
+
+// Relit le classement sauvegardé par SaveRatings
+// Retourne une erreur os.ErrNotExist si aucun classement n'existe
+func LoadRatings(path string) (map[string]PlayerRating, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ratings := make(map[string]PlayerRating)
+	if err := json.Unmarshal(data, &ratings); err != nil {
+		return nil, err
+	}
+	return ratings, nil
+}
+
+// Relit les parties sauvegardées par SaveGames
+// Retourne une erreur os.ErrNotExist si aucune sauvegarde n'existe
+func LoadGames(path string) (map[string]*GameState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var saved map[string]*GameState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+
+	for id, game := range saved {
+		// Une entrée "null" dans le fichier ne doit pas produire une partie nil
+		if game == nil || len(game.Board) == 0 {
+			delete(saved, id)
+			continue
+		}
+
+		// Les sauvegardes antérieures aux plateaux configurables n'ont pas de dimensions
+		if game.Rows == 0 || game.Cols == 0 {
+			game.Rows, game.Cols = game.Board.rows(), game.Board.cols()
+		}
+		if game.Variant == "" {
+			game.Variant = VARIANT_STANDARD
+		}
+
+		// Les sauvegardes antérieures au choix du premier joueur commençaient toujours par les Rouges
+		if game.FirstPlayer == 0 {
+			game.FirstPlayer = PLAYER_1
+		}
+
+		// Un plateau impossible (fichier modifié à la main, bogue passé) ferait planter ou tricher la partie
+		if err := validateBoard(game.Board, game.Variant, game.GravityOff, game.FirstPlayer); err != nil {
+			log.Printf("⚠️ Partie %s ignorée, plateau invalide: %v", sessionLogID(id), err)
+			delete(saved, id)
+			continue
+		}
+		if game.Board.rows() != game.Rows || game.Board.cols() != game.Cols {
+			log.Printf("⚠️ Partie %s ignorée, plateau de %dx%d au lieu de %dx%d", sessionLogID(id), game.Board.rows(), game.Board.cols(), game.Rows, game.Cols)
+			delete(saved, id)
+			continue
+		}
+		if game.WinLength == 0 {
+			game.WinLength = WINNING_COUNT
+		}
+		if game.HumanPlayer == 0 {
+			game.HumanPlayer = PLAYER_1
+		}
+		if game.Lang == "" {
+			game.Lang = DEFAULT_LANG
+		}
+		if game.Personality == "" {
+			game.Personality = PERSONALITY_STANDARD
+		}
+		if game.DoubleWin == "" {
+			game.DoubleWin = DOUBLE_WIN_MOVER
+		}
+		if game.WinDirection == "" && game.Winner != PLAYER_DRAW {
+			game.WinDirection = cellsDirection(game.WinningCells)
+		}
+		if game.Player1Color == "" || game.Player2Color == "" {
+			game.Player1Color, game.Player2Color = COLOR_RED, COLOR_YELLOW
+		}
+		// Le temps passé serveur arrêté n'est décompté à personne
+		if !game.TurnDeadline.IsZero() {
+			game.restartClock()
+		}
+		// Les sauvegardes antérieures au compteur de coups et à la feuille de match les reconstruisent depuis l'historique
+		game.MoveCount = len(game.Moves)
+		// Une partie restaurée a déjà été comptée avant l'arrêt : seuls ses coups suivants le seront
+		game.counted = metricsMark{seen: true, moves: game.MoveCount, over: game.GameOver}
+		// Les coups des sauvegardes antérieures n'ont pas de version : seuls les changements suivants sont décrits
+		for i := range game.Moves {
+			if game.Moves[i].Version == 0 {
+				game.Moves[i].Version = game.Version
+			}
+		}
+		game.deltaFrom = game.Version
+		if len(game.MoveLog) != len(game.Moves) {
+			game.MoveLog = make([]string, len(game.Moves))
+			for i, move := range game.Moves {
+				game.MoveLog[i] = moveNotation(move)
+			}
+		}
+	}
+	return saved, nil
+}
+
+// Applique les effets de bord d'une modification de partie : classement, tournoi, mesures, sauvegarde et diffusion
+// La version est incrémentée après le classement, qui modifie lui aussi la partie, et avant la diffusion
+func (s *Server) onGameUpdated(sessionID string, game *GameState) {
+	s.players.applyRating(game)
+	s.tournament.recordGame(sessionID, game)
+
+	game.mu.Lock()
+	game.Version++
+	// Les coups joués depuis la modification précédente sont publiés par cette version
+	for i := len(game.Moves) - 1; i >= 0 && game.Moves[i].Version == 0; i-- {
+		game.Moves[i].Version = game.Version
+	}
+	metrics.observeGame(game)
+	game.mu.Unlock()
+
+	s.games.persist()
+	s.hub.broadcast(sessionID, game)
+}
+
+// ============================================================================
+// WEBSOCKET - DIFFUSION EN TEMPS RÉEL
+// ============================================================================
+
+// Crée un hub sans abonnés
+func newHub() *Hub {
+	return &Hub{
+		clients: make(map[string]map[*wsClient]bool),
+		streams: make(map[string]map[chan streamEvent]bool),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Abonne une connexion aux mises à jour de la partie d'une session
+// (ou d'un salon, sous la clé ROOM_HUB_PREFIX + code)
+func (h *Hub) subscribe(key string, client *wsClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.clients[key] == nil {
+		h.clients[key] = make(map[*wsClient]bool)
+	}
+	h.clients[key][client] = true
+}
+
+// Désabonne une connexion et ferme le socket
+func (h *Hub) unsubscribe(key string, client *wsClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.clients[key], client)
+	if len(h.clients[key]) == 0 {
+		delete(h.clients, key)
+	}
+	client.conn.Close()
+}
+
+// Abonne un flux SSE aux mises à jour de la partie d'une session
+// Le canal ne garde que le dernier état : un client lent saute les versions intermédiaires
+func (h *Hub) subscribeStream(key string) chan streamEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan streamEvent, 1)
+	if h.streams[key] == nil {
+		h.streams[key] = make(map[chan streamEvent]bool)
+	}
+	h.streams[key][ch] = true
+	return ch
+}
+
+// Désabonne un flux SSE
+func (h *Hub) unsubscribeStream(key string, ch chan streamEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.streams[key], ch)
+	if len(h.streams[key]) == 0 {
+		delete(h.streams, key)
+	}
+}
+
+// Termine tous les flux SSE, pour que l'arrêt du serveur n'attende pas leur déconnexion
+func (h *Hub) closeStreams() {
+	close(h.stop)
+}
+
+// Envoie l'état de la partie à toutes les connexions de la session
+func (h *Hub) broadcast(sessionID string, game *GameState) {
+	h.publish(sessionID, game, GameResponse{})
+}
+
+// Envoie l'état de la partie du salon à ses joueurs et spectateurs connectés
+// Les mesures de la partie sont mises à jour au passage
+func (s *Server) broadcastRoom(room *Room) {
+	s.publishRoom(room, GameResponse{})
+}
+
+// Diffuse l'état du salon en complétant response, qui peut porter la réponse à une demande de reprise ou de nul
+func (s *Server) publishRoom(room *Room, response GameResponse) {
+	room.game.mu.Lock()
+	metrics.observeGame(room.game)
+	response.Takeback = room.takeback
+	room.game.mu.Unlock()
+
+	response.RoomCode = room.Code
+	response.Spectators = s.rooms.spectatorCount(room)
+	s.hub.publish(ROOM_HUB_PREFIX+room.Code, room.game, response)
+}
+
+// Complète la réponse avec l'état de la partie et l'envoie à toutes les connexions abonnées sous key
+// Les flux SSE reçoivent l'état seul ; la partie n'est encodée que si quelqu'un écoute
+func (h *Hub) publish(key string, game *GameState, response GameResponse) {
+	h.mu.Lock()
+	clients := make([]*wsClient, 0, len(h.clients[key]))
+	for client := range h.clients[key] {
+		clients = append(clients, client)
+	}
+	streams := make([]chan streamEvent, 0, len(h.streams[key]))
+	for ch := range h.streams[key] {
+		streams = append(streams, ch)
+	}
+	h.mu.Unlock()
+
+	if len(clients) == 0 && len(streams) == 0 {
+		return
+	}
+
+	game.mu.RLock()
+	response.Success = true
+	response.Message = game.StatusMessage
+	response.GameState = game
+	response.Winner = game.Winner
+	response.AlmostFull = game.Board.almostFullColumns()
+	// Un message par numérotation des colonnes demandée par les clients
+	encoded := make(map[int][]byte, 1)
+	var err error
+	for _, client := range clients {
+		if _, ok := encoded[client.columnBase]; !ok && err == nil {
+			encoded[client.columnBase], err = encodeResponse(response, client.columnBase)
+		}
+	}
+	var event streamEvent
+	if err == nil && len(streams) > 0 {
+		event.version = game.Version
+		event.data, err = json.Marshal(game)
+	}
+	game.mu.RUnlock()
+	if err != nil {
+		log.Printf("❌ Erreur d'encodage JSON: %v", err)
+		return
+	}
+
+	for _, ch := range streams {
+		offerStream(ch, event)
+	}
+
+	for _, client := range clients {
+		if err := client.send(encoded[client.columnBase]); err != nil {
+			h.unsubscribe(key, client)
+		}
+	}
+}
+
+// Dépose un état dans le canal d'un flux SSE sans jamais bloquer la diffusion :
+// l'état pas encore lu est remplacé, et de deux diffusions concurrentes la version la plus récente reste
+func offerStream(ch chan streamEvent, event streamEvent) {
+	for {
+		select {
+		case ch <- event:
+			return
+		default:
+		}
+		select {
+		case old := <-ch:
+			if old.version > event.version {
+				event = old
+			}
+		default:
+		}
+	}
+}
+
+// Écrit un message JSON déjà encodé sur la connexion
+func (c *wsClient) send(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(WS_WRITE_TIMEOUT))
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// Encode puis envoie une réponse sur la connexion
+func (c *wsClient) sendResponse(response GameResponse) error {
+	data, err := marshalResponse(response, c.columnBase)
+	if err != nil {
+		return err
+	}
+	return c.send(data)
+}
+
+// Ouvre une connexion WebSocket sur la partie de la session
+// Le client envoie {"col": n} ; le nouvel état est diffusé à toutes les connexions de la session
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	sessionID := getSessionID(w, r)
+	columnBase := requestColumnBase(w, r)
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade a déjà répondu au client avec une erreur HTTP
+		return
+	}
+
+	client := &wsClient{conn: conn, columnBase: columnBase}
+	s.hub.subscribe(sessionID, client)
+	defer s.hub.unsubscribe(sessionID, client)
+
+	// État initial pour le nouveau client
+	game := s.games.get(sessionID)
+	game.mu.RLock()
+	winner := game.Winner
+	game.mu.RUnlock()
+	if err := client.sendResponse(GameResponse{Success: true, GameState: game, Winner: winner}); err != nil {
+		return
+	}
+
+	for {
+		var req struct {
+			Col int `json:"col"`
+		}
+		if err := conn.ReadJSON(&req); err != nil {
+			// Déconnexion du client ou message illisible
+			return
+		}
+		req.Col -= client.columnBase
+
+		// La partie est relue à chaque message : elle a pu être remplacée entre-temps
+		game := s.games.get(sessionID)
+
+		// Chaque coup reçu compte pour la limitation de débit, comme un appel à /api/move
+		moveErr := s.limitMessage(r)
+		if moveErr == nil {
+			ctx, cancel := context.WithTimeout(r.Context(), AI_REQUEST_TIMEOUT)
+			game.mu.Lock()
+			moveErr = game.playMove(req.Col)
+			if moveErr == nil && game.isAITurn() {
+				game.aiMakeMove(ctx)
+			}
+			game.mu.Unlock()
+			cancel()
+		}
+
+		if moveErr != nil {
+			// Perdue au temps, la partie a changé malgré le refus du coup
+			if moveErr.Code == ERROR_TIMEOUT {
+				s.onGameUpdated(sessionID, game)
+			}
+			client.sendResponse(GameResponse{
+				Success:   false,
+				Message:   moveErr.Message,
+				ErrorCode: moveErr.Code,
+				GameState: game,
+			})
+			continue
+		}
+		s.onGameUpdated(sessionID, game)
+	}
+}
+
+// ============================================================================
+// HTTP HANDLERS - PAGES HTML
+// ============================================================================
+
+// Affiche la page principale du jeu
+func (s *Server) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.renderGame(w, s.games.get(getSessionID(w, r)))
+}
+
+// Affiche la page du jeu en détenant le verrou de lecture de la partie
+func (s *Server) renderGame(w http.ResponseWriter, game *GameState) {
+	game.mu.RLock()
+	defer game.mu.RUnlock()
+
+	if err := s.tmpl.Execute(w, game); err != nil {
+		log.Printf("❌ Erreur d'affichage: %v", err)
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
+	}
+}
+
+// Gère le changement de mode de jeu (2 joueurs / IA)
+func (s *Server) handleModeChange(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	sessionID := getSessionID(w, r)
+	current := s.games.get(sessionID)
+
+	// Le changement de mode conserve les dimensions de la partie en cours
+	current.mu.RLock()
+	rows, cols, winLength, err := parseDimensionsForm(r, current)
+	current.mu.RUnlock()
+	if err != nil {
+		http.Error(w, "❌ "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mode, err := parseMode(r.FormValue("mode"))
+	if err != nil {
+		http.Error(w, "❌ "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts := defaultGameOptions(requestLang(r))
+	opts.Mode = mode
+	opts.Difficulty = r.FormValue("difficulty")
+	opts.Variant = r.FormValue("variant")
+	opts.Rows, opts.Cols, opts.WinLength = rows, cols, winLength
+	opts.HumanPlayer, _ = strconv.Atoi(r.FormValue("human"))
+	opts.FirstPlayer, _ = strconv.Atoi(r.FormValue("first"))
+	game := s.games.reset(sessionID, startNewGame(opts))
+	s.onGameUpdated(sessionID, game)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// Gère le placement d'un jeton
+func (s *Server) handleMove(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	sessionID := getSessionID(w, r)
+	game := s.games.get(sessionID)
+
+	game.mu.Lock()
+	played := game.playFormMove(r.FormValue("col"))
+	outcome := "refused"
+	if played {
+		outcome = game.moveOutcome(nil)
+	}
+	logAttrs(r, slog.String("col", r.FormValue("col")), slog.String("outcome", outcome))
+
+	// La réponse de l'IA est retenue le temps de la pause : le verrou est relâché pour que l'état
+	// reste lisible, et aiThinking refuse d'ici là tout autre coup (double clic, autre onglet)
+	thinking := played && game.isAITurn()
+	if thinking {
+		game.aiThinking = true
+	}
+	game.mu.Unlock()
+
+	if played {
+		s.onGameUpdated(sessionID, game)
+	}
+	if thinking {
+		time.Sleep(config.AIThinkDelay) // Petite pause pour l'effet visuel
+
+		// Une nouvelle partie a pu remplacer celle-ci pendant la pause, un abandon la terminer
+		current := s.games.isCurrent(sessionID, game)
+		ctx, cancel := context.WithTimeout(r.Context(), AI_REQUEST_TIMEOUT)
+		game.mu.Lock()
+		game.aiThinking = false
+		if current && game.isAITurn() {
+			game.aiMakeMove(ctx)
+		}
+		game.mu.Unlock()
+		cancel()
+		if current {
+			s.onGameUpdated(sessionID, game)
+		}
+	}
+	s.renderGame(w, game)
+}
+
+// Joue le coup reçu du formulaire ; la réponse de l'IA est laissée à handleMove
+// L'appelant doit détenir g.mu en écriture
+// Retourne false si le coup est refusé, la raison étant placée dans StatusMessage
+// (un coup hors délai est refusé mais retourne true : la partie est perdue au temps)
+func (g *GameState) playFormMove(colStr string) bool {
+	// L'IA prépare sa réponse au coup précédent
+	if err := g.checkAIThinking(); err != nil {
+		g.StatusMessage = err.Message
+		return false
+	}
+
+	// Un coup hors délai fait perdre la partie au temps : elle a changé, même si le coup est refusé
+	if g.checkClock() != nil {
+		return true
+	}
+
+	// Aucun jeton ne peut être ajouté sur une partie terminée
+	if g.GameOver {
+		g.StatusMessage = g.message(MSG_GAME_OVER)
+		return false
+	}
+
+	// Récupération et validation de la colonne
+	col, err := strconv.Atoi(colStr)
+	if err != nil || col < 0 || col >= g.Cols {
+		g.StatusMessage = g.message(MSG_INVALID_COLUMN)
+		return false
+	}
+
+	// Placement du jeton
+	row := g.placePiece(col, g.CurrentPlayer)
+	if row == -1 {
+		g.StatusMessage = g.message(MSG_COLUMN_FULL)
+		return false
+	}
+
+	// Vérification de la victoire ou du match nul
+	g.checkGameEnd(row, col)
+	return true
+}
+
+// Commence une nouvelle partie
+func (s *Server) handleNewGame(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	sessionID := getSessionID(w, r)
+	current := s.games.get(sessionID)
+
+	// La revanche reprend les réglages de la partie en cours, sauf ceux que le formulaire précise
+	current.mu.RLock()
+	opts := current.options()
+	rows, cols, winLength, err := parseDimensionsForm(r, current)
+	current.mu.RUnlock()
+	if err != nil {
+		http.Error(w, "❌ "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts.Rows, opts.Cols, opts.WinLength = rows, cols, winLength
+
+	if mode := r.FormValue("mode"); mode != "" {
+		opts.Mode = mode
+	}
+	if opts.Mode, err = parseMode(opts.Mode); err != nil {
+		http.Error(w, "❌ "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if difficulty := r.FormValue("difficulty"); difficulty != "" {
+		opts.Difficulty = difficulty
+	}
+	if variant := r.FormValue("variant"); variant != "" {
+		opts.Variant = variant
+	}
+	if lang := strings.ToLower(r.FormValue("lang")); statusMessages[lang] != nil {
+		opts.Lang = lang
+	}
+	if human := r.FormValue("human"); human != "" {
+		opts.HumanPlayer, _ = strconv.Atoi(human)
+	}
+	if first := r.FormValue("first"); first != "" {
+		opts.FirstPlayer, _ = strconv.Atoi(first)
+	}
+	if color := r.FormValue("player1Color"); color != "" {
+		opts.Player1Color = color
+	}
+	if color := r.FormValue("player2Color"); color != "" {
+		opts.Player2Color = color
+	}
+	if err := validateColors(opts.Player1Color, opts.Player2Color); err != nil {
+		http.Error(w, "❌ "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if seed := r.FormValue("seed"); seed != "" {
+		if opts.Seed, err = strconv.ParseInt(seed, 10, 64); err != nil {
+			http.Error(w, "❌ Graine invalide", http.StatusBadRequest)
+			return
+		}
+	}
+
+	game := s.games.reset(sessionID, startNewGame(opts))
+	s.onGameUpdated(sessionID, game)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// Lit les dimensions rows, cols et win du formulaire
+// Un champ absent reprend la valeur de la partie en cours
+func parseDimensionsForm(r *http.Request, current *GameState) (rows, cols, winLength int, err error) {
+	values := []struct {
+		name     string
+		fallback int
+		dest     *int
+	}{
+		{"rows", current.Rows, &rows},
+		{"cols", current.Cols, &cols},
+		{"win", current.WinLength, &winLength},
+	}
+
+	for _, v := range values {
+		*v.dest = v.fallback
+		if raw := r.FormValue(v.name); raw != "" {
+			if *v.dest, err = strconv.Atoi(raw); err != nil {
+				return 0, 0, 0, fmt.Errorf("valeur invalide pour %s", v.name)
+			}
+		}
+	}
+
+	return rows, cols, winLength, validateDimensions(rows, cols, winLength)
+}
+
+// Annule le dernier coup
+func (s *Server) handleUndo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	sessionID := getSessionID(w, r)
+	game := s.games.get(sessionID)
+
+	game.mu.Lock()
+	if err := game.checkUndoAllowed(); err != nil {
+		game.StatusMessage = err.Message
+	} else if !game.undoLastMove() {
+		game.StatusMessage = game.message(MSG_NOTHING_UNDONE)
+	}
+	game.mu.Unlock()
+
+	s.onGameUpdated(sessionID, game)
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// ============================================================================
+// BOARD - FONCTIONS PURES SUR LE PLATEAU
+// ============================================================================
+
+// Crée un plateau vide de rows lignes et cols colonnes
+func newBoard(rows, cols int) Board {
+	board := make(Board, rows)
+	for row := range board {
+		board[row] = make([]int, cols)
+	}
+	return board
+}
+
+// Retourne une copie indépendante du plateau
+func (b Board) Clone() Board {
+	clone := make(Board, len(b))
+	for row := range b {
+		clone[row] = append([]int(nil), b[row]...)
+	}
+	return clone
+}
+
+// Vérifie qu'un plateau venu de l'extérieur (sauvegarde, import) est possible : lignes de même longueur,
+// cases vides ou occupées par un joueur, aucun jeton au-dessus d'une case vide (sauf sans gravité), et,
+// hors Pop Out où les retraits faussent le compte, autant de jetons à chaque joueur ou un de plus
+// au joueur first, qui a commencé
+func validateBoard(board Board, variant string, gravityOff bool, first int) error {
+	if first != PLAYER_1 && first != PLAYER_2 {
+		return fmt.Errorf("premier joueur %d inconnu", first)
+	}
+
+	counts := [3]int{}
+	for row := range board {
+		if len(board[row]) != board.cols() {
+			return fmt.Errorf("ligne %d de longueur %d au lieu de %d", row+1, len(board[row]), board.cols())
+		}
+		for col, cell := range board[row] {
+			if cell != CELL_EMPTY && cell != PLAYER_1 && cell != PLAYER_2 {
+				return fmt.Errorf("case (%d, %d) : valeur %d inconnue", row+1, col+1, cell)
+			}
+			if !gravityOff && cell != CELL_EMPTY && row+1 < board.rows() && board[row+1][col] == CELL_EMPTY {
+				return fmt.Errorf("jeton flottant en (%d, %d) : la case du dessous est vide", row+1, col+1)
+			}
+			counts[cell]++
+		}
+	}
+
+	if variant != VARIANT_POP_OUT {
+		if diff := counts[first] - counts[PLAYER_2+PLAYER_1-first]; diff != 0 && diff != 1 {
+			return fmt.Errorf("%d jeton(s) rouge(s) pour %d jaune(s) : impossible en jouant chacun son tour", counts[PLAYER_1], counts[PLAYER_2])
+		}
+	}
+	return nil
+}
+
+// Clé canonique du plateau : la plus petite de son écriture et de celle de son miroir gauche-droite
+// Deux positions symétriques ont la même valeur, elles partagent donc la même clé
+func canonicalBoard(board Board) string {
+	size := board.rows() * (board.cols() + 1)
+	direct := make([]byte, 0, size)
+	mirror := make([]byte, 0, size)
+	for _, row := range board {
+		for col := range row {
+			direct = append(direct, byte('0'+row[col]))
+			mirror = append(mirror, byte('0'+row[len(row)-1-col]))
+		}
+		direct = append(direct, '/')
+		mirror = append(mirror, '/')
+	}
+	if string(mirror) < string(direct) {
+		return string(mirror)
+	}
+	return string(direct)
+}
+
+// Nombre de lignes du plateau
+func (b Board) rows() int {
+	return len(b)
+}
+
+// Nombre de colonnes du plateau
+func (b Board) cols() int {
+	if len(b) == 0 {
+		return 0
+	}
+	return len(b[0])
+}
+
+// Retourne la ligne où tomberait un jeton joué dans la colonne, sans rien placer
+// Retourne -1 si la colonne est pleine ou hors du plateau
+func (b Board) dropRow(col int) int {
+	if col < 0 || col >= b.cols() {
+		return -1
+	}
+	for row := b.rows() - 1; row >= 0; row-- {
+		if b[row][col] == CELL_EMPTY {
+			return row
+		}
+	}
+	return -1
+}
+
+// Place un jeton dans la colonne spécifiée sur une copie du plateau
+// Retourne le nouveau plateau et la ligne où le jeton a été placé, ou -1 si la colonne est pleine
+// ou hors du plateau (dropRow la vérifie) : l'appelant n'a pas à valider col pour éviter un débordement
+func (b Board) Place(col, player int) (Board, int) {
+	row := b.dropRow(col)
+	if row == -1 {
+		return b, -1
+	}
+	next := b.Clone()
+	next[row][col] = player
+	return next, row
+}
+
+// Retire le jeton du bas de la colonne sur une copie du plateau, les jetons au-dessus descendant d'une case
+func (b Board) Pop(col int) Board {
+	next := b.Clone()
+	for row := b.rows() - 1; row > 0; row-- {
+		next[row][col] = next[row-1][col]
+	}
+	next[0][col] = CELL_EMPTY
+	return next
+}
+
+// Vérifie s'il y a un gagnant après un mouvement
+// Sans allowOverline, seul un alignement d'exactement winLength jetons gagne : un alignement plus long
+// dans une direction ne compte pas, mais un alignement exact dans une autre direction gagne
+// Retourne le gagnant (0 si aucun), les cases de l'alignement gagnant (nil si aucun) et sa direction (WIN_*, vide si aucun)
+func (b Board) checkForWin(row, col, winLength int, allowOverline bool) (int, [][2]int, string) {
+	player := b[row][col]
+
+	// Horizontale, verticale puis les deux diagonales
+	for _, dir := range lineDirections {
+		cells := b.checkDirection(row, col, dir.dRow, dir.dCol, player)
+		if len(cells) == winLength || (len(cells) > winLength && allowOverline) {
+			return player, cells, dir.name
+		}
+	}
+
+	return 0, nil, ""
+}
+
+// Retourne les cases de l'alignement du joueur passant par (row, col) dans une direction
+// Les cases sont ordonnées d'une extrémité à l'autre de l'alignement
+func (b Board) checkDirection(row, col, dRow, dCol, player int) [][2]int {
+	rows, cols := b.rows(), b.cols()
+	var cells [][2]int
+
+	// Remontée jusqu'à l'extrémité dans le sens opposé
+	i, j := row, col
+	for i-dRow >= 0 && i-dRow < rows && j-dCol >= 0 && j-dCol < cols && b[i-dRow][j-dCol] == player {
+		i, j = i-dRow, j-dCol
+	}
+
+	// Parcours de l'alignement jusqu'à l'autre extrémité
+	for ; i >= 0 && i < rows && j >= 0 && j < cols && b[i][j] == player; i, j = i+dRow, j+dCol {
+		cells = append(cells, [2]int{i, j})
+	}
+
+	return cells
+}
+
+// Direction d'alignement : pas d'une case à la suivante, et nom renvoyé dans WinDirection
+type lineDirection struct {
+	dRow, dCol int
+	name       string
+}
+
+// Directions d'alignement : horizontale, verticale et les deux diagonales
+var lineDirections = []lineDirection{
+	{0, 1, WIN_HORIZONTAL},
+	{1, 0, WIN_VERTICAL},
+	{1, 1, WIN_DIAGONAL_DOWN},
+	{-1, 1, WIN_DIAGONAL_UP},
+}
+
+// Direction d'un alignement d'après ses cases, ordonnées comme checkDirection les retourne ;
+// vide pour moins de deux cases
+func cellsDirection(cells [][2]int) string {
+	if len(cells) < 2 {
+		return ""
+	}
+	dRow, dCol := cells[1][0]-cells[0][0], cells[1][1]-cells[0][1]
+	for _, dir := range lineDirections {
+		if dir.dRow == dRow && dir.dCol == dCol {
+			return dir.name
+		}
+	}
+	return ""
+}
+
+// Appelle fn pour chaque fenêtre de winLength cases alignées du plateau
+// counts donne le nombre de cases vides (indice CELL_EMPTY) et de jetons de chaque joueur
+// Le parcours s'arrête dès que fn retourne false
+func (b Board) eachWindow(winLength int, fn func(counts [3]int) bool) {
+	rows, cols := b.rows(), b.cols()
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			for _, d := range lineDirections {
+				endRow := row + d.dRow*(winLength-1)
+				endCol := col + d.dCol*(winLength-1)
+				if endRow < 0 || endRow >= rows || endCol >= cols {
+					continue
+				}
+
+				var counts [3]int
+				for k := 0; k < winLength; k++ {
+					counts[b[row+d.dRow*k][col+d.dCol*k]]++
+				}
+				if !fn(counts) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Vérifie s'il reste au moins une fenêtre de winLength cases pouvant encore devenir
+// un alignement, c'est-à-dire ne contenant les jetons que d'un seul joueur
+func (b Board) canAnyoneStillWin(winLength int) bool {
+	open := false
+	b.eachWindow(winLength, func(counts [3]int) bool {
+		open = counts[PLAYER_1] == 0 || counts[PLAYER_2] == 0
+		return !open
+	})
+	return open
+}
+
+// Cherche un alignement gagnant sur tout le plateau, sans connaître le dernier coup joué
+// Sans allowOverline, les alignements plus longs que winLength sont ignorés (voir checkForWin)
+// Retourne le joueur aligné, 0 si aucun, ou PLAYER_BOTH si les deux joueurs le sont
+func (b Board) scanBoardForWinner(winLength int, allowOverline bool) int {
+	var aligned [3]bool
+	if !allowOverline {
+		// Une fenêtre pleine peut appartenir à un alignement trop long : il faut mesurer
+		// l'alignement entier passant par chaque jeton
+		for row := range b {
+			for col, player := range b[row] {
+				if player != CELL_EMPTY && !aligned[player] {
+					winner, _, _ := b.checkForWin(row, col, winLength, false)
+					aligned[player] = winner == player
+				}
+			}
+		}
+	} else {
+		b.eachWindow(winLength, func(counts [3]int) bool {
+			for _, player := range []int{PLAYER_1, PLAYER_2} {
+				if counts[player] == winLength {
+					aligned[player] = true
+				}
+			}
+			return !(aligned[PLAYER_1] && aligned[PLAYER_2])
+		})
+	}
+
+	switch {
+	case aligned[PLAYER_1] && aligned[PLAYER_2]:
+		return PLAYER_BOTH
+	case aligned[PLAYER_1]:
+		return PLAYER_1
+	case aligned[PLAYER_2]:
+		return PLAYER_2
+	default:
+		return 0
+	}
+}
+
+// Liste tous les alignements d'au moins winLength jetons du plateau (d'exactement winLength sans allowOverline),
+// dans l'ordre de parcours des cases ; chaque alignement n'est compté qu'une fois, en entier
+func (b Board) completedLines(winLength int, allowOverline bool) []BoardLine {
+	rows, cols := b.rows(), b.cols()
+	lines := []BoardLine{}
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			player := b[row][col]
+			if player == CELL_EMPTY {
+				continue
+			}
+			for _, d := range lineDirections {
+				// Seule la première case de l'alignement le rapporte
+				prevRow, prevCol := row-d.dRow, col-d.dCol
+				if prevRow >= 0 && prevRow < rows && prevCol >= 0 && prevCol < cols && b[prevRow][prevCol] == player {
+					continue
+				}
+				cells := b.checkDirection(row, col, d.dRow, d.dCol, player)
+				if len(cells) == winLength || (len(cells) > winLength && allowOverline) {
+					lines = append(lines, BoardLine{Player: player, Cells: cells, Direction: d.name})
+				}
+			}
+		}
+	}
+	return lines
+}
+
+// Vérifie si le plateau est plein (match nul possible)
+// Toutes les cases sont examinées : sans gravité, une case vide peut se trouver sous un jeton
+func (b Board) isBoardFull() bool {
+	for row := range b {
+		for _, cell := range b[row] {
+			if cell == CELL_EMPTY {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Retourne toutes les colonnes jouables
+func (b Board) getValidMoves() []int {
+	var moves []int
+	for col := 0; col < b.cols(); col++ {
+		if b.isValidMove(col) {
+			moves = append(moves, col)
+		}
+	}
+	return moves
+}
+
+// Nombre de jetons de chaque colonne, de gauche à droite
+// Avec gravité, c'est la hauteur de la pile : le prochain jeton tombe sur la ligne rows()-1-hauteur.
+// Sans gravité, les cases vides sous un jeton ne comptent pas
+func (b Board) columnHeights() []int {
+	heights := make([]int, b.cols())
+	for row := range b {
+		for col, cell := range b[row] {
+			if cell != CELL_EMPTY {
+				heights[col]++
+			}
+		}
+	}
+	return heights
+}
+
+// Clés de Zobrist : une valeur aléatoire par case et par joueur, et une pour le trait au joueur 2
+type zobristKeys struct {
+	cells [MAX_BOARD_SIZE * MAX_BOARD_SIZE][2]uint64
+	side  uint64
+}
+
+// Tire les clés de Zobrist depuis ZOBRIST_SEED
+func newZobristKeys() *zobristKeys {
+	rng := rand.New(rand.NewSource(ZOBRIST_SEED))
+	keys := &zobristKeys{side: rng.Uint64()}
+	for i := range keys.cells {
+		keys.cells[i] = [2]uint64{rng.Uint64(), rng.Uint64()}
+	}
+	return keys
+}
+
+// Empreinte de Zobrist d'une position : le plateau et le joueur au trait
+// Deux positions égales ont la même empreinte ; deux positions différentes n'en partagent une
+// qu'avec une probabilité négligeable
+func hashBoard(board Board, toMove int) uint64 {
+	var hash uint64
+	for row := range board {
+		for col, cell := range board[row] {
+			if cell != CELL_EMPTY {
+				hash ^= zobrist.cells[row*MAX_BOARD_SIZE+col][cell-1]
+			}
+		}
+	}
+	if toMove == PLAYER_2 {
+		hash ^= zobrist.side
+	}
+	return hash
+}
+
+// Défait un coup de l'historique sur le plateau, en place : la case d'un jeton posé se vide,
+// et la colonne d'un retrait remonte d'une case, le jeton retrouvant sa place en bas
+func (b Board) unplay(move Move) {
+	if move.Pop {
+		for row := 0; row < move.Row; row++ {
+			b[row][move.Col] = b[row+1][move.Col]
+		}
+		b[move.Row][move.Col] = move.Player
+		return
+	}
+	b[move.Row][move.Col] = CELL_EMPTY
+}
+
+// Colonnes où il ne reste qu'une case libre, d'après columnHeights ; vide si aucune
+func (b Board) almostFullColumns() []int {
+	var cols []int
+	for col, height := range b.columnHeights() {
+		if height == b.rows()-1 {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
+
+// Vérifie si un mouvement est valide (la colonne n'est pas pleine)
+func (b Board) isValidMove(col int) bool {
+	return col >= 0 && col < b.cols() && b[0][col] == CELL_EMPTY
+}
+
+// ============================================================================
+// GAME LOGIC - CORE FUNCTIONS
+// ============================================================================
+
+// MarshalJSON ajoute à l'état des champs calculés à la volée :
+//   - ValidColumns : pour chaque colonne, true si elle est jouable (toujours false une fois la partie terminée) ;
+//     sans gravité, une colonne reste jouable tant qu'elle a une case vide
+//   - Heights : nombre de jetons de chaque colonne (voir columnHeights)
+//   - Duration : durée de la partie en secondes, jusqu'à maintenant si elle est en cours
+//   - TimeRemaining : temps restant au joueur attendu, en secondes (0 sans pendule)
+//   - MovesRemaining : coups restants avant le nul imposé par MaxMoves (0 sans limite)
+//   - UndosRemaining : annulations encore permises, -1 sans limite
+//   - TurnNumber : numéro du tour en cours, comme dans la feuille de match (un tour = un coup de chaque joueur)
+//   - RepetitionCount : occurrences de la position courante depuis le début, elle comprise (voir repetitionCount) ;
+//     au-delà de 1, la position est déjà apparue
+//   - LastAIDecision : remplacé par une copie sans statistiques de recherche (Search)
+//
+// L'appelant doit détenir g.mu en lecture, comme pour tout encodage de la partie
+func (g *GameState) MarshalJSON() ([]byte, error) {
+	return g.marshalColumns(0)
+}
+
+// Encode l'état comme MarshalJSON, les colonnes de Moves, LastMove, WinningCells et LastAIDecision
+// décalées de columnBase (voir requestColumnBase) ; Board, ValidColumns et Heights restent des tableaux
+// indicés à partir de 0
+func (g *GameState) marshalColumns(columnBase int) ([]byte, error) {
+	// Type sans méthodes, pour que l'encodage de l'état ne rappelle pas MarshalJSON
+	type plainState GameState
+
+	// Une colonne qui a moins de jetons que de lignes a une case vide : en haut avec gravité, n'importe où sans
+	heights := g.Board.columnHeights()
+	validColumns := make([]bool, g.Cols)
+	if !g.GameOver {
+		for col := range validColumns {
+			validColumns[col] = heights[col] < g.Rows
+		}
+	}
+
+	var duration time.Duration
+	switch {
+	case g.StartedAt.IsZero():
+		// Partie restaurée d'une sauvegarde antérieure à l'horodatage
+	case g.EndedAt.IsZero():
+		duration = time.Since(g.StartedAt)
+	default:
+		duration = g.EndedAt.Sub(g.StartedAt)
+	}
+
+	// Temps restant au joueur attendu, pour afficher un compte à rebours
+	var remaining time.Duration
+	if !g.TurnDeadline.IsZero() && !g.GameOver {
+		remaining = max(time.Until(g.TurnDeadline), 0)
+	}
+
+	// Une fois la partie terminée, CurrentPlayer reste celui du dernier coup : le trait est à son adversaire
+	toMove := g.CurrentPlayer
+	if g.GameOver && len(g.Moves) > 0 {
+		toMove = PLAYER_2 + PLAYER_1 - g.Moves[len(g.Moves)-1].Player
+	}
+
+	// Coups restants avant le nul imposé par MaxMoves
+	movesRemaining := 0
+	if g.MaxMoves > 0 {
+		movesRemaining = max(g.MaxMoves-len(g.Moves), 0)
+	}
+
+	// Les statistiques de recherche ne sortent qu'avec ?debug=1 (voir aiExplanationAPI)
+	var lastAIDecision *AIDecision
+	if g.LastAIDecision != nil {
+		decision := g.LastAIDecision.shifted(columnBase)
+		decision.Search = nil
+		lastAIDecision = &decision
+	}
+
+	// Copies décalées : la partie elle-même reste numérotée à partir de 0
+	moves, lastMove, winningCells := g.Moves, g.LastMove, g.WinningCells
+	if columnBase != 0 {
+		moves = make([]Move, len(g.Moves))
+		for i, move := range g.Moves {
+			move.Col += columnBase
+			moves[i] = move
+		}
+		if g.LastMove != nil {
+			lastMove = &[2]int{g.LastMove[0], g.LastMove[1] + columnBase}
+		}
+		if g.WinningCells != nil {
+			winningCells = make([][2]int, len(g.WinningCells))
+			for i, cell := range g.WinningCells {
+				winningCells[i] = [2]int{cell[0], cell[1] + columnBase}
+			}
+		}
+	}
+
+	return json.Marshal(struct {
+		*plainState
+		Moves           []Move
+		LastMove        *[2]int
+		WinningCells    [][2]int
+		LastAIDecision  *AIDecision
+		ValidColumns    []bool
+		Heights         []int
+		Duration        float64
+		TimeRemaining   float64
+		MovesRemaining  int
+		UndosRemaining  int
+		TurnNumber      int
+		RepetitionCount int
+	}{(*plainState)(g), moves, lastMove, winningCells, lastAIDecision, validColumns, heights, duration.Seconds(), remaining.Seconds(), movesRemaining, g.undosRemaining(), g.MoveCount/2 + 1, g.repetitionCount(toMove)})
+}
+
+// UnmarshalJSON relit une partie sauvegardée ; une sauvegarde antérieure à AllowUndo garde ses annulations,
+// une sauvegarde antérieure à AllowOverline en reprend la règle depuis ExactWin
+func (g *GameState) UnmarshalJSON(data []byte) error {
+	type plainState GameState
+	g.AllowUndo = true
+	g.AllowOverline = true
+	legacy := struct {
+		*plainState
+		ExactWin bool
+	}{plainState: (*plainState)(g)}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+	if legacy.ExactWin {
+		g.AllowOverline = false
+	}
+	return nil
+}
+
+// String rend la partie en texte : numéros de colonnes (notation d'export), plateau
+// avec '.' pour une case vide, 'R' pour les rouges et 'Y' pour les jaunes, puis l'état
+// L'appelant doit détenir g.mu en lecture
+func (g *GameState) String() string {
+	var sb strings.Builder
+
+	for col := 0; col < g.Cols; col++ {
+		sb.WriteString(" " + strconv.FormatInt(int64(col), 36))
+	}
+	sb.WriteString("\n")
+
+	symbols := map[int]string{CELL_EMPTY: ".", PLAYER_1: "R", PLAYER_2: "Y"}
+	for _, row := range g.Board {
+		for _, cell := range row {
+			sb.WriteString(" " + symbols[cell])
+		}
+		sb.WriteString("\n")
+	}
+
+	switch {
+	case g.StatusMessage != "":
+		sb.WriteString(g.StatusMessage)
+	case g.CurrentPlayer == PLAYER_1:
+		sb.WriteString(g.playerMessage(MSG_TURN_1, PLAYER_1))
+	default:
+		sb.WriteString(g.playerMessage(MSG_TURN_2, PLAYER_2))
+	}
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+// Dessine le plateau : un disque par case sur fond bleu, aux couleurs des joueurs, les jetons
+// de l'alignement gagnant cerclés d'un anneau
+// L'appelant doit détenir g.mu en lecture
+func (g *GameState) image() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, g.Cols*IMAGE_CELL_SIZE, g.Rows*IMAGE_CELL_SIZE))
+
+	winning := make(map[[2]int]bool, len(g.WinningCells))
+	for _, cell := range g.WinningCells {
+		winning[cell] = true
+	}
+	fills := map[int]color.RGBA{
+		CELL_EMPTY: imageEmptyColor,
+		PLAYER_1:   tokenColors[g.Player1Color],
+		PLAYER_2:   tokenColors[g.Player2Color],
+	}
+
+	// Chaque pixel prend la couleur du disque de sa case, de l'anneau ou du fond
+	center := IMAGE_CELL_SIZE / 2
+	haloRadius := IMAGE_TOKEN_RADIUS + IMAGE_HALO_WIDTH
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		row, dy := y/IMAGE_CELL_SIZE, y%IMAGE_CELL_SIZE-center
+		for x := 0; x < img.Bounds().Dx(); x++ {
+			col, dx := x/IMAGE_CELL_SIZE, x%IMAGE_CELL_SIZE-center
+			dist := dx*dx + dy*dy
+
+			switch {
+			case dist <= IMAGE_TOKEN_RADIUS*IMAGE_TOKEN_RADIUS:
+				img.SetRGBA(x, y, fills[g.Board[row][col]])
+			case dist <= haloRadius*haloRadius && winning[[2]int{row, col}]:
+				img.SetRGBA(x, y, imageHaloColor)
+			default:
+				img.SetRGBA(x, y, imageBoardColor)
+			}
+		}
+	}
+	return img
+}
+
+// Place un jeton dans la colonne spécifiée
+// Retourne la ligne où le jeton a été placé, ou -1 si la colonne est pleine ou hors du plateau
+func (g *GameState) placePiece(col, player int) int {
+	board, row := g.Board.Place(col, player)
+	if row != -1 {
+		g.Board = board
+		g.appendMove(Move{Col: col, Row: row, Player: player, At: time.Now()})
+	}
+	return row
+}
+
+// Ajoute un coup à l'historique et à la feuille de match
+func (g *GameState) appendMove(move Move) {
+	g.Moves = append(g.Moves, move)
+	g.MoveCount++
+	g.MoveLog = append(g.MoveLog, moveNotation(move))
+	g.logEvent(EVENT_MOVE, "%s", moveNotation(move))
+	g.updateLastMove()
+	// Jouer plutôt que répondre retire la proposition de nul en attente, comme aux échecs
+	g.DrawOffer = 0
+}
+
+// Repère la case du dernier coup de l'historique, pour que l'interface puisse la mettre en évidence
+func (g *GameState) updateLastMove() {
+	if len(g.Moves) == 0 {
+		g.LastMove = nil
+		return
+	}
+	last := g.Moves[len(g.Moves)-1]
+	g.LastMove = &[2]int{last.Row, last.Col}
+}
+
+// Ajoute un événement au journal de la partie, en oubliant le plus ancien au-delà de MAX_GAME_EVENTS
+// L'appelant doit détenir g.mu en écriture
+func (g *GameState) logEvent(eventType, format string, args ...interface{}) {
+	event := Event{Time: time.Now(), Type: eventType, Detail: fmt.Sprintf(format, args...)}
+	if len(g.Events) >= MAX_GAME_EVENTS {
+		copy(g.Events, g.Events[1:])
+		g.Events[len(g.Events)-1] = event
+		return
+	}
+	g.Events = append(g.Events, event)
+}
+
+// Refuse toute action sur le plateau pendant que l'IA prépare sa réponse, pour que l'humain
+// ne joue pas deux fois de suite ni ne devance le coup de l'IA
+func (g *GameState) checkAIThinking() *MoveError {
+	if g.aiThinking {
+		return &MoveError{http.StatusConflict, ERROR_AI_THINKING, g.message(MSG_AI_THINKING)}
+	}
+	return nil
+}
+
+// Joue le coup d'un joueur humain après avoir vérifié qu'il est autorisé
+// Retourne une MoveError décrivant le refus, ou nil si le coup a été joué
+func (g *GameState) playMove(col int) *MoveError {
+	if err := g.checkAIThinking(); err != nil {
+		return err
+	}
+	if err := g.checkClock(); err != nil {
+		return err
+	}
+
+	switch {
+	case g.GameOver:
+		return &MoveError{http.StatusConflict, ERROR_GAME_OVER, "La partie est terminée"}
+	case g.isAITurn():
+		return &MoveError{http.StatusConflict, ERROR_NOT_YOUR_TURN, "C'est au tour de l'ordinateur"}
+	case col < 0 || col >= g.Cols:
+		// Avant tout accès au plateau : une colonne négative ferait paniquer l'indexation
+		return &MoveError{http.StatusBadRequest, ERROR_COLUMN_OUT_OF_RANGE, "Colonne invalide"}
+	}
+
+	row := g.placePiece(col, g.CurrentPlayer)
+	if row == -1 {
+		return &MoveError{http.StatusBadRequest, ERROR_COLUMN_FULL, "Colonne pleine"}
+	}
+
+	g.checkGameEnd(row, col)
+	return nil
+}
+
+// Pose le jeton du joueur au trait sur une case vide précise, sans le faire tomber (GravityOff)
+// Retourne une MoveError décrivant le refus, ou nil si le coup a été joué
+// L'appelant doit détenir g.mu en écriture
+func (g *GameState) placeCell(row, col int) *MoveError {
+	if err := g.checkAIThinking(); err != nil {
+		return err
+	}
+	if err := g.checkClock(); err != nil {
+		return err
+	}
+
+	switch {
+	case !g.GravityOff:
+		return &MoveError{http.StatusBadRequest, ERROR_PLACE_NOT_ALLOWED, "La pose sur une case précise n'est autorisée que sans gravité"}
+	case g.GameOver:
+		return &MoveError{http.StatusConflict, ERROR_GAME_OVER, "La partie est terminée"}
+	case g.isAITurn():
+		return &MoveError{http.StatusConflict, ERROR_NOT_YOUR_TURN, "C'est au tour de l'ordinateur"}
+	case row < 0 || row >= g.Rows || col < 0 || col >= g.Cols:
+		return &MoveError{http.StatusBadRequest, ERROR_CELL_OUT_OF_RANGE, "Case hors du plateau"}
+	case g.Board[row][col] != CELL_EMPTY:
+		return &MoveError{http.StatusBadRequest, ERROR_CELL_OCCUPIED, "Case déjà occupée"}
+	}
+
+	board := g.Board.Clone()
+	board[row][col] = g.CurrentPlayer
+	g.Board = board
+	g.appendMove(Move{Col: col, Row: row, Player: g.CurrentPlayer, Placed: true, At: time.Now()})
+
+	// Seules les lignes passant par la case posée ont changé : checkForWin suffit, même sous un trou,
+	// et isBoardFull examine toutes les cases pour le nul
+	g.checkGameEnd(row, col)
+	return nil
+}
+
+// Annule le dernier coup (et la réponse de l'IA en mode IA, pour rendre la main à l'humain)
+// Retourne false s'il n'y a aucun coup à annuler
+func (g *GameState) undoLastMove() bool {
+	if len(g.Moves) == 0 {
+		return false
+	}
+
+	// En mode IA, annuler la seule réponse de l'IA lui rendrait la main :
+	// on annule aussi le coup humain qui la précède
+	// Après un échange, annuler la réponse de l'IA annule aussi l'échange : l'ouverture redevient
+	// celle de l'IA et l'humain peut de nouveau y répondre
+	unswap := false
+	if g.Mode == GAME_MODE_AI && g.Moves[len(g.Moves)-1].Player != g.HumanPlayer {
+		switch {
+		case len(g.Moves) < 2:
+			// Seule l'ouverture de l'IA a été jouée
+			return false
+		case g.Swapped && len(g.Moves) == 2:
+			unswap = true
+		default:
+			g.popMove()
+		}
+	}
+	last := g.popMove()
+	g.UndosUsed++
+	if g.Swapped && (unswap || len(g.Moves) == 0) {
+		g.exchangeSides()
+	}
+
+	// La partie reprend : son résultat ne compte plus dans le bilan
+	if g.GameOver {
+		g.recordResult(-1)
+		g.EndedAt = time.Time{}
+	}
+
+	g.CurrentPlayer = last.Player
+	g.GameOver = false
+	g.Winner = 0
+	g.WinningCells = nil
+	g.WinDirection = ""
+	g.DrawOffer = 0
+	g.StatusMessage = g.message(MSG_MOVE_UNDONE)
+	g.logEvent(EVENT_UNDO, "retour au coup %d", len(g.Moves))
+	g.restartClock()
+	return true
+}
+
+// Nombre d'annulations encore permises : 0 si elles sont désactivées, -1 sans limite
+func (g *GameState) undosRemaining() int {
+	switch {
+	case !g.AllowUndo:
+		return 0
+	case g.MaxUndos == 0:
+		return -1
+	default:
+		return max(g.MaxUndos-g.UndosUsed, 0)
+	}
+}
+
+// Vérifie que les options de la partie permettent encore une annulation, et que l'IA ne prépare
+// pas sa réponse au coup qui serait annulé
+func (g *GameState) checkUndoAllowed() *MoveError {
+	if err := g.checkAIThinking(); err != nil {
+		return err
+	}
+	switch g.undosRemaining() {
+	case 0:
+		key := MSG_UNDO_EXHAUSTED
+		if !g.AllowUndo {
+			key = MSG_UNDO_DISABLED
+		}
+		return &MoveError{http.StatusConflict, ERROR_UNDO_NOT_ALLOWED, g.message(key)}
+	default:
+		return nil
+	}
+}
+
+// Retire le dernier coup de l'historique et vide sa case
+func (g *GameState) popMove() Move {
+	last := g.Moves[len(g.Moves)-1]
+	g.Moves = g.Moves[:len(g.Moves)-1]
+	// Un coup retiré ne peut pas s'exprimer en coups ajoutés : les versions connues jusqu'ici recevront l'état complet
+	g.deltaFrom = g.Version + 1
+	g.MoveCount--
+	if len(g.MoveLog) > 0 {
+		g.MoveLog = g.MoveLog[:len(g.MoveLog)-1]
+	}
+	g.updateLastMove()
+	if g.LastAIDecision != nil && g.LastAIDecision.MoveNumber > len(g.Moves) {
+		g.LastAIDecision = nil
+	}
+
+	g.Board.unplay(last)
+	return last
+}
+
+// Nombre d'occurrences de la position courante (plateau et joueur au trait toMove) depuis le début
+// de la partie, elle comprise : les positions passées sont retrouvées en défaisant l'historique
+// Seuls les retraits Pop Out permettent de revenir à une position déjà vue
+func (g *GameState) repetitionCount(toMove int) int {
+	current := hashBoard(g.Board, toMove)
+	board := g.Board.Clone()
+	count := 1
+	for i := len(g.Moves) - 1; i >= 0; i-- {
+		board.unplay(g.Moves[i])
+		// Avant un coup, le trait était à son auteur
+		if hashBoard(board, g.Moves[i].Player) == current {
+			count++
+		}
+	}
+	return count
+}
+
+// Indique si la position, le trait étant à toMove, atteint la répétition qui rend la partie nulle
+func (g *GameState) repetitionReached(toMove int) bool {
+	return g.RepetitionDraw && g.repetitionCount(toMove) >= REPETITION_DRAW_COUNT
+}
+
+// Issue d'un coup pour le journal des requêtes : son code d'erreur s'il est refusé,
+// sinon "win" ou "draw" s'il termine la partie, "played" autrement
+// L'appelant doit détenir g.mu
+func (g *GameState) moveOutcome(err *MoveError) string {
+	switch {
+	case err != nil:
+		return err.Code
+	case g.Winner == PLAYER_DRAW:
+		return "draw"
+	case g.GameOver:
+		return "win"
+	default:
+		return "played"
+	}
+}
+
+// Vérifie la fin de partie (victoire ou match nul)
+// Un jeton posé ne peut compléter qu'un alignement de son joueur : seul un retrait (checkPopEnd)
+// peut aligner les deux joueurs à la fois
+func (g *GameState) checkGameEnd(row, col int) {
+	winner, cells, direction := g.Board.checkForWin(row, col, g.WinLength, g.AllowOverline)
+
+	if winner > 0 {
+		g.GameOver = true
+		g.Winner = winner
+		g.WinningCells = cells
+		g.WinDirection = direction
+		g.StatusMessage = getWinnerMessage(g.Lang, winner, g.Player1Color, g.Player2Color)
+	} else if g.moveLimitReached() {
+		g.GameOver = true
+		g.Winner = PLAYER_DRAW
+		g.StatusMessage = g.message(MSG_DRAW_MAX_MOVES)
+	} else if g.repetitionReached(PLAYER_2 + PLAYER_1 - g.Board[row][col]) {
+		g.GameOver = true
+		g.Winner = PLAYER_DRAW
+		g.StatusMessage = g.message(MSG_DRAW_REPEATED)
+	} else if g.Board.isBoardFull() && !g.canPop(PLAYER_2+PLAYER_1-g.Board[row][col]) {
+		// En Pop Out, un plateau plein n'est nul que si l'adversaire ne peut rien retirer
+		g.GameOver = true
+		g.Winner = PLAYER_DRAW
+		g.StatusMessage = g.message(MSG_DRAW_FULL)
+	} else if g.Variant != VARIANT_POP_OUT && !g.Board.canAnyoneStillWin(g.WinLength) {
+		// Plus aucun alignement possible : inutile de remplir le plateau
+		// (en Pop Out, les retraits peuvent rouvrir des alignements : pas de nul anticipé)
+		g.GameOver = true
+		g.Winner = PLAYER_DRAW
+		g.StatusMessage = g.message(MSG_DRAW_BLOCKED)
+	} else {
+		// Changement de joueur : la main passe à l'adversaire de celui qui vient de jouer
+		g.CurrentPlayer = PLAYER_2 + PLAYER_1 - g.Board[row][col]
+		g.StatusMessage = ""
+	}
+
+	if g.GameOver {
+		g.markEnded()
+	}
+	g.restartClock()
+}
+
+// Indique si la partie a atteint son nombre maximal de coups (MaxMoves)
+func (g *GameState) moveLimitReached() bool {
+	return g.MaxMoves > 0 && len(g.Moves) >= g.MaxMoves
+}
+
+// Horodate la fin de la partie et comptabilise son résultat dans le bilan
+func (g *GameState) markEnded() {
+	g.EndedAt = time.Now()
+	g.TurnDeadline = time.Time{}
+	g.DrawOffer = 0
+	g.recordResult(1)
+	g.logEvent(EVENT_END, "vainqueur %d après %d coups", g.Winner, len(g.Moves))
+}
+
+// Comptabilise (delta = 1) ou décompte (delta = -1) le résultat de la partie terminée
+// Seules les parties contre l'IA alimentent le bilan
+func (g *GameState) recordResult(delta int) {
+	if g.Mode != GAME_MODE_AI {
+		return
+	}
+
+	g.Stats.Games += delta
+	switch g.Winner {
+	case PLAYER_DRAW:
+		g.Stats.Draws += delta
+	case g.HumanPlayer:
+		g.Stats.Wins += delta
+	default:
+		g.Stats.Losses += delta
+	}
+}
+
+// Retire le jeton du joueur au bas de la colonne (variante Pop Out) : la colonne descend d'une case
+// Retourne une MoveError décrivant le refus, ou nil si le retrait a été joué
+// L'appelant doit détenir g.mu en écriture
+func (g *GameState) popPiece(col, player int) *MoveError {
+	if err := g.checkAIThinking(); err != nil {
+		return err
+	}
+	if err := g.checkClock(); err != nil {
+		return err
+	}
+
+	switch {
+	case g.Variant != VARIANT_POP_OUT:
+		return &MoveError{http.StatusBadRequest, ERROR_POP_NOT_ALLOWED, "Le retrait n'est autorisé qu'en variante Pop Out"}
+	case g.GameOver:
+		return &MoveError{http.StatusConflict, ERROR_GAME_OVER, "La partie est terminée"}
+	case player != g.CurrentPlayer:
+		return &MoveError{http.StatusConflict, ERROR_NOT_YOUR_TURN, "Ce n'est pas à ce joueur de jouer"}
+	case col < 0 || col >= g.Cols:
+		// Avant tout accès au plateau : une colonne négative ferait paniquer l'indexation
+		return &MoveError{http.StatusBadRequest, ERROR_COLUMN_OUT_OF_RANGE, "Colonne invalide"}
+	case g.Board[g.Rows-1][col] != player:
+		return &MoveError{http.StatusBadRequest, ERROR_NOT_YOUR_TOKEN, "Le jeton du bas de cette colonne n'est pas le vôtre"}
+	}
+
+	g.Board = g.Board.Pop(col)
+	g.appendMove(Move{Col: col, Row: g.Rows - 1, Player: player, Pop: true, At: time.Now()})
+	g.checkPopEnd(col, player)
+	return nil
+}
+
+// Vérifie la fin de partie après un retrait
+// Tous les jetons de la colonne ont bougé : chacun peut compléter un alignement, pour l'un ou
+// l'autre joueur. Si le retrait aligne les deux joueurs à la fois, DoubleWin décide : celui qui
+// a retiré gagne, ou la partie est nulle
+func (g *GameState) checkPopEnd(col, player int) {
+	opponent := PLAYER_2 + PLAYER_1 - player
+	var lines [3][][2]int
+	var directions [3]string
+
+	for row := 0; row < g.Rows; row++ {
+		if g.Board[row][col] == CELL_EMPTY {
+			continue
+		}
+		if winner, cells, direction := g.Board.checkForWin(row, col, g.WinLength, g.AllowOverline); winner > 0 && lines[winner] == nil {
+			lines[winner], directions[winner] = cells, direction
+		}
+	}
+
+	if lines[player] != nil && lines[opponent] != nil && g.DoubleWin == DOUBLE_WIN_DRAW {
+		g.GameOver = true
+		g.Winner = PLAYER_DRAW
+		g.WinningCells = append(lines[player], lines[opponent]...)
+		g.StatusMessage = g.message(MSG_DRAW_DOUBLE)
+		g.markEnded()
+		return
+	}
+
+	winner := 0
+	switch {
+	case lines[player] != nil:
+		winner = player
+	case lines[opponent] != nil:
+		winner = opponent
+	}
+
+	if winner > 0 {
+		g.GameOver = true
+		g.Winner = winner
+		g.WinningCells = lines[winner]
+		g.WinDirection = directions[winner]
+		g.StatusMessage = getWinnerMessage(g.Lang, winner, g.Player1Color, g.Player2Color)
+		g.markEnded()
+		return
+	}
+
+	if g.moveLimitReached() {
+		g.GameOver = true
+		g.Winner = PLAYER_DRAW
+		g.StatusMessage = g.message(MSG_DRAW_MAX_MOVES)
+		g.markEnded()
+		return
+	}
+
+	if g.repetitionReached(opponent) {
+		g.GameOver = true
+		g.Winner = PLAYER_DRAW
+		g.StatusMessage = g.message(MSG_DRAW_REPEATED)
+		g.markEnded()
+		return
+	}
+
+	g.CurrentPlayer = opponent
+	g.StatusMessage = ""
+	g.restartClock()
+}
+
+// Relance la pendule pour le joueur dont c'est le tour, depuis maintenant
+// Sans pendule, partie terminée ou au tour de l'IA, il n'y a pas d'heure limite
+func (g *GameState) restartClock() {
+	if g.MoveTimeLimit == 0 || g.GameOver || g.isAITurn() {
+		g.TurnDeadline = time.Time{}
+		return
+	}
+	g.TurnDeadline = time.Now().Add(g.MoveTimeLimit)
+}
+
+// Constate un dépassement du temps : si le joueur attendu a laissé passer son heure limite,
+// il perd la partie au temps et la MoveError à renvoyer est retournée (nil sinon)
+// L'appelant doit détenir g.mu en écriture, et appliquer onGameUpdated même en cas de refus
+func (g *GameState) checkClock() *MoveError {
+	if g.GameOver || g.TurnDeadline.IsZero() || time.Now().Before(g.TurnDeadline) {
+		return nil
+	}
+
+	g.loseOnTime(g.CurrentPlayer, time.Since(g.TurnDeadline).Round(time.Millisecond))
+	return &MoveError{http.StatusConflict, ERROR_TIMEOUT, "Temps écoulé : la partie est perdue au temps"}
+}
+
+// Termine la partie par la défaite au temps du joueur late, en retard de overdue
+// L'appelant doit détenir g.mu en écriture
+func (g *GameState) loseOnTime(late int, overdue time.Duration) {
+	g.GameOver = true
+	g.Winner = PLAYER_2 + PLAYER_1 - late
+	g.WinningCells = nil
+	g.WinDirection = ""
+	if late == PLAYER_1 {
+		g.StatusMessage = g.playerMessage(MSG_TIMEOUT_1, late)
+	} else {
+		g.StatusMessage = g.playerMessage(MSG_TIMEOUT_2, late)
+	}
+	g.logEvent(EVENT_TIMEOUT, "joueur %d en retard de %v", late, overdue)
+	g.markEnded()
+}
+
+// Vérifie si le joueur peut retirer un de ses jetons (variante Pop Out uniquement)
+func (g *GameState) canPop(player int) bool {
+	if g.Variant != VARIANT_POP_OUT {
+		return false
+	}
+	for col := 0; col < g.Cols; col++ {
+		if g.Board[g.Rows-1][col] == player {
+			return true
+		}
+	}
+	return false
+}
+
+// Termine la partie sur l'abandon du joueur, son adversaire étant déclaré vainqueur
+// Retourne une MoveError si la partie est déjà terminée
+// L'appelant doit détenir g.mu en écriture
+func (g *GameState) resign(player int) *MoveError {
+	if g.GameOver {
+		return &MoveError{http.StatusConflict, ERROR_GAME_OVER, "La partie est terminée"}
+	}
+
+	g.GameOver = true
+	g.Winner = PLAYER_2 + PLAYER_1 - player
+	g.WinningCells = nil
+	g.WinDirection = ""
+	if player == PLAYER_1 {
+		g.StatusMessage = g.playerMessage(MSG_RESIGN_1, player)
+	} else {
+		g.StatusMessage = g.playerMessage(MSG_RESIGN_2, player)
+	}
+	g.logEvent(EVENT_RESIGN, "abandon du joueur %d", player)
+	g.markEnded()
+	return nil
+}
+
+// Enregistre la proposition de nul du joueur, en attente de la réponse de son adversaire (voir answerDraw)
+// Contre l'IA, il n'y a personne pour répondre : seules les parties à deux joueurs et les salons le permettent
+// L'appelant doit détenir g.mu en écriture
+func (g *GameState) offerDraw(player int) *MoveError {
+	switch {
+	case g.GameOver:
+		return &MoveError{http.StatusConflict, ERROR_GAME_OVER, "La partie est terminée"}
+	case g.Mode == GAME_MODE_AI:
+		return &MoveError{http.StatusConflict, ERROR_DRAW_NOT_ALLOWED, "Le nul par accord ne se propose qu'entre deux joueurs"}
+	case g.DrawOffer == player:
+		return &MoveError{http.StatusConflict, ERROR_DRAW_OFFER_PENDING, "Votre proposition de nul attend déjà sa réponse"}
+	case g.DrawOffer != 0:
+		return &MoveError{http.StatusConflict, ERROR_DRAW_OFFER_PENDING, "Votre adversaire propose déjà le nul : répondez à sa proposition"}
+	}
+	g.DrawOffer = player
+	g.logEvent(EVENT_DRAW, "proposition de nul du joueur %d", player)
+	return nil
+}
+
+// Accepte ou refuse la proposition de nul de l'adversaire du joueur, puis l'efface
+// L'appelant doit détenir g.mu en écriture
+func (g *GameState) answerDraw(player int, accept bool) *MoveError {
+	if g.DrawOffer == 0 || g.DrawOffer == player {
+		return &MoveError{http.StatusConflict, ERROR_NO_DRAW_OFFER, "Votre adversaire n'a proposé aucun nul"}
+	}
+	if !accept {
+		g.logEvent(EVENT_DRAW, "nul refusé par le joueur %d", player)
+		g.DrawOffer = 0
+		return nil
+	}
+	g.agreeDraw()
+	return nil
+}
+
+// Termine la partie sur un nul accepté par les deux joueurs ; le résultat compte comme tout autre nul
+// L'appelant doit détenir g.mu en écriture
+func (g *GameState) agreeDraw() {
+	g.GameOver = true
+	g.Winner = PLAYER_DRAW
+	g.WinningCells = nil
+	g.WinDirection = ""
+	g.StatusMessage = g.message(MSG_DRAW_AGREED)
+	g.logEvent(EVENT_DRAW, "nul par accord")
+	g.markEnded()
+}
+
+// Retourne le message d'état associé à la clé, dans la langue demandée
+func translate(lang, key string) string {
+	if msg, ok := statusMessages[lang][key]; ok {
+		return msg
+	}
+	return statusMessages[DEFAULT_LANG][key]
+}
+
+// Retourne le message d'état associé à la clé, dans la langue de la partie
+func (g *GameState) message(key string) string {
+	return translate(g.Lang, key)
+}
+
+// Retourne le message d'état désignant le joueur, complété du nom de sa couleur
+func (g *GameState) playerMessage(key string, player int) string {
+	return fmt.Sprintf(g.message(key), g.ColorName(player))
+}
+
+// Nom de la couleur dans la langue demandée (la couleur elle-même si elle n'est pas connue)
+func translateColor(lang, color string) string {
+	if name, ok := colorNames[lang][color]; ok {
+		return name
+	}
+	if name, ok := colorNames[DEFAULT_LANG][color]; ok {
+		return name
+	}
+	return color
+}
+
+// Décrit en clair un coup de l'IA dans la langue demandée, avec la meilleure autre colonne quand la recherche l'a évaluée
+func explainDecision(lang string, d AIDecision) string {
+	format, ok := aiReasonTexts[lang][d.Reason]
+	if !ok {
+		format = aiReasonTexts[DEFAULT_LANG][d.Reason]
+	}
+	if format == "" {
+		return fmt.Sprintf("%d (%s)", d.Col+1, d.Reason)
+	}
+	text := fmt.Sprintf(format, d.Col+1)
+
+	if alt := d.Alternative; alt != nil {
+		compare, ok := aiAlternativeTexts[lang]
+		if !ok {
+			compare = aiAlternativeTexts[DEFAULT_LANG]
+		}
+		text += fmt.Sprintf(compare, d.Score, alt.Col+1, alt.Score)
+	}
+	return text
+}
+
+// ColorName retourne le nom de la couleur des jetons du joueur, dans la langue de la partie
+// Exportée pour le template (index.html)
+func (g *GameState) ColorName(player int) string {
+	if player == PLAYER_2 {
+		return translateColor(g.Lang, g.Player2Color)
+	}
+	return translateColor(g.Lang, g.Player1Color)
+}
+
+// Vérifie les couleurs choisies pour les deux joueurs : connues de colorNames et différentes
+func validateColors(color1, color2 string) error {
+	for _, color := range []string{color1, color2} {
+		if _, ok := colorNames[DEFAULT_LANG][color]; !ok {
+			return fmt.Errorf("couleur inconnue : %q", color)
+		}
+	}
+	if color1 == color2 {
+		return errors.New("les deux joueurs doivent avoir des couleurs différentes")
+	}
+	return nil
+}
+
+// Règle du gâteau : en réponse au premier coup, le second joueur peut prendre ce coup à son compte,
+// une seule fois par partie
+// Le plateau et le tour ne bougent pas, ce sont les joueurs qui changent de couleur : celui qui
+// échange possède désormais le jeton rouge et l'autre, devenu jaune, doit répondre
+// Contre l'IA, seul l'humain peut échanger (quand l'IA a ouvert) ; l'IA répond aussitôt
+// L'appelant doit détenir g.mu en écriture
+func (g *GameState) swapSides() *MoveError {
+	if err := g.checkAIThinking(); err != nil {
+		return err
+	}
+	if err := g.checkClock(); err != nil {
+		return err
+	}
+
+	switch {
+	case g.GameOver:
+		return &MoveError{http.StatusConflict, ERROR_GAME_OVER, "La partie est terminée"}
+	case len(g.Moves) != 1 || g.Swapped || g.StartBoard != nil:
+		return &MoveError{http.StatusConflict, ERROR_SWAP_NOT_ALLOWED, "L'échange n'est possible qu'en réponse au premier coup"}
+	case g.isAITurn():
+		return &MoveError{http.StatusConflict, ERROR_NOT_YOUR_TURN, "C'est au tour de l'ordinateur"}
+	}
+
+	g.exchangeSides()
+	g.deltaFrom = g.Version + 1
+	g.StatusMessage = g.message(MSG_SWAPPED)
+	g.logEvent(EVENT_SWAP, "le joueur 2 prend le premier coup à son compte")
+
+	if g.isAITurn() {
+		g.aiMakeMove(context.Background())
+	}
+	g.restartClock()
+	return nil
+}
+
+// Applique ou retire l'échange de la règle du gâteau : les joueurs (et l'humain contre l'IA)
+// changent de camp, le plateau et le tour ne bougent pas
+func (g *GameState) exchangeSides() {
+	g.Swapped = !g.Swapped
+	g.Players[0], g.Players[1] = g.Players[1], g.Players[0]
+	if g.Mode == GAME_MODE_AI {
+		g.HumanPlayer = g.aiPlayer()
+	}
+}
+
+// Joueur incarné par l'humain et noms des joueurs avant l'échange de la règle du gâteau :
+// l'export et la relecture partent de ces camps et rejouent l'échange après le premier coup
+func (g *GameState) sidesBeforeSwap() (int, [2]string) {
+	human, players := g.HumanPlayer, g.Players
+	if !g.Swapped {
+		return human, players
+	}
+	if g.Mode == GAME_MODE_AI {
+		human = g.aiPlayer()
+	}
+	return human, [2]string{players[1], players[0]}
+}
+
+// Retourne le message de victoire approprié, dans la langue demandée
+// Le vainqueur y est désigné par la couleur de ses jetons
+func getWinnerMessage(lang string, winner int, color1, color2 string) string {
+	key, ok := winnerMessageKeys[winner]
+	if !ok {
+		return ""
+	}
+	switch winner {
+	case PLAYER_1:
+		return fmt.Sprintf(translate(lang, key), translateColor(lang, color1))
+	case PLAYER_2:
+		return fmt.Sprintf(translate(lang, key), translateColor(lang, color2))
+	}
+	return translate(lang, key)
+}
+
+// Crée une nouvelle partie avec les réglages donnés
+// opts.FirstPlayer joue le premier coup ; si c'est l'IA, elle joue immédiatement l'ouverture
+// Les dimensions doivent avoir été validées avec validateDimensions, les couleurs avec validateColors
+func startNewGame(opts GameOptions) *GameState {
+	game := newGameState(opts.Mode, opts.Difficulty, opts.Variant, opts.Lang, opts.Rows, opts.Cols, opts.WinLength, opts.HumanPlayer)
+	if isValidPersonality(opts.Personality) {
+		game.Personality = opts.Personality
+	}
+	if opts.Player1Color != "" && opts.Player2Color != "" {
+		game.Player1Color, game.Player2Color = opts.Player1Color, opts.Player2Color
+	}
+	if opts.Seed != 0 {
+		game.Seed = opts.Seed
+	}
+	game.MoveTimeLimit = config.MoveTimeLimit
+	game.setFirstPlayer(opts.FirstPlayer)
+	game.playOpening()
+	return game
+}
+
+// Réglages d'une partie à deux joueurs sur le plateau classique, dans la langue donnée
+func defaultGameOptions(lang string) GameOptions {
+	return GameOptions{
+		Mode:         GAME_MODE_TWO_PLAYER,
+		Difficulty:   DIFFICULTY_EASY,
+		Personality:  PERSONALITY_STANDARD,
+		Variant:      VARIANT_STANDARD,
+		Lang:         lang,
+		Rows:         BOARD_ROWS,
+		Cols:         BOARD_COLS,
+		WinLength:    WINNING_COUNT,
+		HumanPlayer:  PLAYER_1,
+		FirstPlayer:  PLAYER_1,
+		Player1Color: COLOR_RED,
+		Player2Color: COLOR_YELLOW,
+	}
+}
+
+// Réglages de la partie, pour en démarrer une autre semblable ; la graine n'en fait pas partie
+// L'appelant doit détenir g.mu en lecture
+func (g *GameState) options() GameOptions {
+	return GameOptions{
+		Mode:         g.Mode,
+		Difficulty:   g.Difficulty,
+		Personality:  g.Personality,
+		Variant:      g.Variant,
+		Lang:         g.Lang,
+		Rows:         g.Rows,
+		Cols:         g.Cols,
+		WinLength:    g.WinLength,
+		HumanPlayer:  g.HumanPlayer,
+		FirstPlayer:  g.FirstPlayer,
+		Player1Color: g.Player1Color,
+		Player2Color: g.Player2Color,
+	}
+}
+
+// Donne le premier coup au joueur indiqué (le joueur 1 pour toute autre valeur que PLAYER_2)
+// À appeler avant le premier coup et avant setPosition, dont il fixe le décompte des jetons
+func (g *GameState) setFirstPlayer(player int) {
+	if player != PLAYER_2 {
+		player = PLAYER_1
+	}
+	g.FirstPlayer = player
+	g.CurrentPlayer = player
+}
+
+// Fait jouer l'ouverture à l'IA si elle a les rouges
+// À appeler une fois les options de la partie (graine...) renseignées
+func (g *GameState) playOpening() {
+	if g.isAITurn() {
+		g.aiMakeMove(context.Background())
+	}
+	g.restartClock()
+}
+
+// Crée l'état initial d'une partie, sans jouer aucun coup
+func newGameState(mode, difficulty, variant, lang string, rows, cols, winLength, humanPlayer int) *GameState {
+	if !isValidDifficulty(difficulty) {
+		difficulty = DIFFICULTY_EASY
+	}
+	if variant != VARIANT_POP_OUT {
+		variant = VARIANT_STANDARD
+	}
+	if statusMessages[lang] == nil {
+		lang = DEFAULT_LANG
+	}
+	if humanPlayer != PLAYER_2 {
+		humanPlayer = PLAYER_1
+	}
+
+	game := &GameState{
+		Board:          newBoard(rows, cols),
+		Rows:           rows,
+		Cols:           cols,
+		WinLength:      winLength,
+		FirstPlayer:    PLAYER_1,
+		CurrentPlayer:  PLAYER_1,
+		Mode:           mode,
+		Difficulty:     difficulty,
+		Personality:    PERSONALITY_STANDARD,
+		DoubleWin:      DOUBLE_WIN_MOVER,
+		Variant:        variant,
+		Lang:           lang,
+		HumanPlayer:    humanPlayer,
+		Player1Color:   COLOR_RED,
+		Player2Color:   COLOR_YELLOW,
+		Seed:           rand.Int63(),
+		AllowOverline:  true,
+		UseOpeningBook: true,
+		AllowUndo:      true,
+		StartedAt:      time.Now(),
+		GameOver:       false,
+		Winner:         0,
+		StatusMessage:  "",
+	}
+	game.logEvent(EVENT_START, "mode %s, difficulté %s, variante %s, %dx%d, alignement %d", mode, difficulty, variant, rows, cols, winLength)
+	return game
+}
+
+// Remplace le plateau vide d'une nouvelle partie par une position donnée, sans historique de coups
+// player est le joueur au trait ; 0 le déduit du nombre de jetons (FirstPlayer au trait à égalité)
+// Hors Pop Out, où chacun a joué à son tour, il doit correspondre à ce décompte
+// La position est refusée si elle est illégale, déjà gagnée ou sans coup jouable
+func (g *GameState) setPosition(board Board, player int) error {
+	if board.rows() != g.Rows || board.cols() != g.Cols {
+		return fmt.Errorf("plateau %dx%d au lieu de %dx%d", board.rows(), board.cols(), g.Rows, g.Cols)
+	}
+	if err := validateBoard(board, g.Variant, g.GravityOff, g.FirstPlayer); err != nil {
+		return err
+	}
+	if winner := board.scanBoardForWinner(g.WinLength, g.AllowOverline); winner != 0 {
+		return errors.New("la position est déjà gagnée")
+	}
+	if board.isBoardFull() {
+		return errors.New("le plateau est plein")
+	}
+
+	counts := [3]int{}
+	for _, row := range board {
+		for _, cell := range row {
+			counts[cell]++
+		}
+	}
+	toMove := g.FirstPlayer
+	if counts[g.FirstPlayer] > counts[PLAYER_2+PLAYER_1-g.FirstPlayer] {
+		toMove = PLAYER_2 + PLAYER_1 - g.FirstPlayer
+	}
+	switch {
+	case player == 0:
+		player = toMove
+	case player != PLAYER_1 && player != PLAYER_2:
+		return fmt.Errorf("joueur au trait invalide : %d", player)
+	case player != toMove && g.Variant != VARIANT_POP_OUT:
+		return fmt.Errorf("%d jeton(s) rouge(s) pour %d jaune(s) : c'est au joueur %d de jouer", counts[PLAYER_1], counts[PLAYER_2], toMove)
+	}
+
+	g.StartBoard = board.Clone()
+	g.StartPlayer = player
+	g.Board = board.Clone()
+	g.CurrentPlayer = player
+	g.logEvent(EVENT_START, "position %s, au tour du joueur %d", encodePosition(board), player)
+	return nil
+}
+
+// Vérifie que le mode sans gravité est compatible avec la partie : l'IA ne sait que faire tomber
+// ses jetons, et les retraits du Pop Out supposent des colonnes sans trou
+func checkGravityOff(gravityOff bool, mode, variant string) error {
+	switch {
+	case !gravityOff:
+		return nil
+	case mode != GAME_MODE_TWO_PLAYER:
+		return errors.New("le mode sans gravité se joue à deux joueurs")
+	case variant == VARIANT_POP_OUT:
+		return errors.New("le mode sans gravité est incompatible avec le Pop Out")
+	}
+	return nil
+}
+
+// Indique si la règle de double alignement est connue (DOUBLE_WIN_*)
+func isValidDoubleWin(rule string) bool {
+	return rule == DOUBLE_WIN_MOVER || rule == DOUBLE_WIN_DRAW
+}
+
+// Vérifie que les dimensions demandées décrivent une variante jouable
+func validateDimensions(rows, cols, winLength int) error {
+	if rows < MIN_BOARD_SIZE || rows > MAX_BOARD_SIZE {
+		return fmt.Errorf("nombre de lignes invalide (%d à %d)", MIN_BOARD_SIZE, MAX_BOARD_SIZE)
+	}
+	if cols < MIN_BOARD_SIZE || cols > MAX_BOARD_SIZE {
+		return fmt.Errorf("nombre de colonnes invalide (%d à %d)", MIN_BOARD_SIZE, MAX_BOARD_SIZE)
+	}
+	if winLength < MIN_WIN_LENGTH || winLength > min(rows, cols) {
+		return fmt.Errorf("longueur d'alignement invalide (%d à %d)", MIN_WIN_LENGTH, min(rows, cols))
+	}
+	return nil
+}
+
+// ============================================================================
+// EXPORT / IMPORT - REJEU DES PARTIES
+// ============================================================================
+
+// Construit l'export d'une partie ; l'appelant doit détenir g.mu en lecture
+func (g *GameState) export() GameExport {
+	human, _ := g.sidesBeforeSwap()
+	return GameExport{
+		Moves:       encodeMoves(g.Moves),
+		Mode:        g.Mode,
+		Difficulty:  g.Difficulty,
+		Personality: g.Personality,
+		Variant:     g.Variant,
+		Lang:        g.Lang,
+		HumanPlayer: human,
+		FirstPlayer: g.FirstPlayer,
+		Color1:      g.Player1Color,
+		Color2:      g.Player2Color,
+		Rows:        g.Rows,
+		Cols:        g.Cols,
+		WinLength:   g.WinLength,
+		Overline:    overlineOption(g.AllowOverline),
+		DoubleWin:   g.DoubleWin,
+		GravityOff:  g.GravityOff,
+		MaxMoves:    g.MaxMoves,
+		Repetition:  g.RepetitionDraw,
+		OpeningBook: g.UseOpeningBook,
+		Position:    encodePosition(g.StartBoard),
+		ToMove:      g.StartPlayer,
+		Swapped:     g.Swapped,
+		Seed:        g.Seed,
+		Winner:      g.Winner,
+		Termination: g.termination(),
+		ExportedAt:  time.Now(),
+	}
+}
+
+// Option allowOverline de l'export : absente pour la règle par défaut, où un alignement plus long gagne aussi
+func overlineOption(allow bool) *bool {
+	if allow {
+		return nil
+	}
+	return &allow
+}
+
+// Retourne la fin de partie hors du plateau (abandon ou temps dépassé), vide sinon
+// C'est l'événement noté juste avant la fin de partie dans le journal
+func (g *GameState) termination() string {
+	if !g.GameOver {
+		return ""
+	}
+	for i := len(g.Events) - 1; i > 0; i-- {
+		if g.Events[i].Type != EVENT_END {
+			continue
+		}
+		switch g.Events[i-1].Type {
+		case EVENT_RESIGN:
+			return TERMINATION_RESIGN
+		case EVENT_TIMEOUT:
+			return TERMINATION_TIMEOUT
+		case EVENT_DRAW:
+			return TERMINATION_AGREEMENT
+		}
+		return ""
+	}
+	return ""
+}
+
+// Reconstruit une partie en rejouant les coups d'un export
+// Chaque coup est validé : la séquence est refusée au premier coup illégal
+func importGame(exp GameExport) (*GameState, error) {
+	// Dimensions du Puissance 4 classique si non précisées
+	if exp.Rows == 0 {
+		exp.Rows = BOARD_ROWS
+	}
+	if exp.Cols == 0 {
+		exp.Cols = BOARD_COLS
+	}
+	if exp.WinLength == 0 {
+		exp.WinLength = WINNING_COUNT
+	}
+	if err := validateDimensions(exp.Rows, exp.Cols, exp.WinLength); err != nil {
+		return nil, err
+	}
+	if exp.MaxMoves < 0 {
+		return nil, fmt.Errorf("nombre maximal de coups invalide : %d", exp.MaxMoves)
+	}
+	if exp.FirstPlayer != 0 && exp.FirstPlayer != PLAYER_1 && exp.FirstPlayer != PLAYER_2 {
+		return nil, fmt.Errorf("premier joueur invalide : %d", exp.FirstPlayer)
+	}
+	if exp.Color1 == "" {
+		exp.Color1 = COLOR_RED
+	}
+	if exp.Color2 == "" {
+		exp.Color2 = COLOR_YELLOW
+	}
+	if err := validateColors(exp.Color1, exp.Color2); err != nil {
+		return nil, err
+	}
+	if exp.Personality == "" {
+		exp.Personality = PERSONALITY_STANDARD
+	}
+	if !isValidPersonality(exp.Personality) {
+		return nil, fmt.Errorf("personnalité de l'IA inconnue : %q", exp.Personality)
+	}
+	if exp.DoubleWin == "" {
+		exp.DoubleWin = DOUBLE_WIN_MOVER
+	}
+	if !isValidDoubleWin(exp.DoubleWin) {
+		return nil, fmt.Errorf("règle de double alignement inconnue : %q", exp.DoubleWin)
+	}
+
+	moves, err := decodeMoves(exp.Moves)
+	if err != nil {
+		return nil, err
+	}
+	if exp.Swapped && (len(moves) == 0 || exp.Position != "") {
+		return nil, errors.New("l'échange n'est possible qu'en réponse au premier coup d'un plateau vide")
+	}
+
+	// Pas d'ouverture automatique de l'IA : tous les coups viennent de l'export
+	game := newGameState(exp.Mode, exp.Difficulty, exp.Variant, exp.Lang, exp.Rows, exp.Cols, exp.WinLength, exp.HumanPlayer)
+	if err := checkGravityOff(exp.GravityOff, game.Mode, game.Variant); err != nil {
+		return nil, err
+	}
+	game.setFirstPlayer(exp.FirstPlayer)
+	game.Personality = exp.Personality
+	game.AllowOverline = !exp.ExactWin
+	if exp.Overline != nil {
+		game.AllowOverline = *exp.Overline
+	}
+	game.DoubleWin = exp.DoubleWin
+	game.GravityOff = exp.GravityOff
+	game.Player1Color, game.Player2Color = exp.Color1, exp.Color2
+	game.MaxMoves = exp.MaxMoves
+	game.RepetitionDraw = exp.Repetition
+	game.UseOpeningBook = exp.OpeningBook
+	if exp.Seed != 0 {
+		game.Seed = exp.Seed
+	}
+	if exp.Position != "" {
+		board, err := decodePosition(exp.Position)
+		if err != nil {
+			return nil, err
+		}
+		if err := game.setPosition(board, exp.ToMove); err != nil {
+			return nil, err
+		}
+	}
+	if err := game.replaySwapped(moves, exp.Swapped); err != nil {
+		return nil, err
+	}
+
+	// Abandon ou temps dépassé : les coups ne suffisent pas à terminer la partie
+	switch exp.Termination {
+	case "":
+	case TERMINATION_RESIGN, TERMINATION_TIMEOUT:
+		if game.GameOver || (exp.Winner != PLAYER_1 && exp.Winner != PLAYER_2) {
+			return nil, fmt.Errorf("fin de partie %q incohérente avec les coups et le résultat", exp.Termination)
+		}
+		loser := PLAYER_2 + PLAYER_1 - exp.Winner
+		if exp.Termination == TERMINATION_RESIGN {
+			game.resign(loser)
+		} else {
+			if loser != game.CurrentPlayer {
+				return nil, fmt.Errorf("le joueur %d ne peut pas perdre au temps : ce n'est pas son tour", loser)
+			}
+			game.loseOnTime(loser, 0)
+		}
+	case TERMINATION_AGREEMENT:
+		if game.GameOver || exp.Winner != PLAYER_DRAW || game.Mode == GAME_MODE_AI {
+			return nil, fmt.Errorf("fin de partie %q incohérente avec les coups et le résultat", exp.Termination)
+		}
+		game.agreeDraw()
+	default:
+		return nil, fmt.Errorf("fin de partie inconnue : %q", exp.Termination)
+	}
+
+	if err := validateBoard(game.Board, game.Variant, game.GravityOff, game.FirstPlayer); err != nil {
+		return nil, err
+	}
+
+	// Contrôle indépendant du rejeu, sur le plateau entier : un alignement non détecté ou
+	// deux joueurs alignés (seul un retrait du Pop Out le permet) trahissent un export incohérent
+	switch scanned := game.Board.scanBoardForWinner(game.WinLength, game.AllowOverline); {
+	case scanned == PLAYER_BOTH && game.Variant != VARIANT_POP_OUT:
+		return nil, errors.New("les deux joueurs ont un alignement")
+	case scanned > 0 && scanned != game.Winner:
+		return nil, fmt.Errorf("alignement du joueur %d non détecté par le rejeu", scanned)
+	}
+
+	if exp.Winner != 0 && exp.Winner != game.Winner {
+		return nil, fmt.Errorf("le résultat annoncé (%d) ne correspond pas aux coups (%d)", exp.Winner, game.Winner)
+	}
+	return game, nil
+}
+
+// Reconstruit l'état de la partie après ses step premiers coups
+// Le joueur à jouer et une éventuelle victoire sont ceux du moment
+func (r Replay) frame() (ReplayFrame, error) {
+	g := r.game
+	state := newGameState(g.Mode, g.Difficulty, g.Variant, g.Lang, g.Rows, g.Cols, g.WinLength, g.HumanPlayer)
+	state.setFirstPlayer(g.FirstPlayer)
+	state.Personality = g.Personality
+	state.AllowOverline = g.AllowOverline
+	state.DoubleWin = g.DoubleWin
+	state.GravityOff = g.GravityOff
+	state.Player1Color, state.Player2Color = g.Player1Color, g.Player2Color
+	state.MaxMoves = g.MaxMoves
+	state.RepetitionDraw = g.RepetitionDraw
+	state.Seed = g.Seed
+	state.HumanPlayer, state.Players = g.sidesBeforeSwap()
+	if g.StartBoard != nil {
+		if err := state.setPosition(g.StartBoard, g.StartPlayer); err != nil {
+			return ReplayFrame{}, err
+		}
+	}
+	if err := state.replaySwapped(g.Moves[:r.step], g.Swapped); err != nil {
+		return ReplayFrame{}, err
+	}
+	// Une étape de relecture ne compte pas dans le bilan
+	state.Stats = Stats{}
+
+	return ReplayFrame{Step: r.step, Total: len(g.Moves), GameState: state}, nil
+}
+
+// Rejoue une suite de coups comme replayMoves, en appliquant l'échange de la règle du gâteau
+// après le premier coup si swapped
+func (g *GameState) replaySwapped(moves []Move, swapped bool) error {
+	if !swapped || len(moves) == 0 {
+		return g.replayMoves(moves)
+	}
+	if err := g.replayMoves(moves[:1]); err != nil {
+		return err
+	}
+	if g.GameOver {
+		return errors.New("échange après la fin de la partie")
+	}
+	g.exchangeSides()
+	g.logEvent(EVENT_SWAP, "le joueur 2 prend le premier coup à son compte")
+	return g.replayMoves(moves[1:])
+}
+
+// Rejoue une suite de coups (colonne et éventuel retrait ou pose), chaque coup étant joué
+// par le joueur dont c'est le tour
+func (g *GameState) replayMoves(moves []Move) error {
+	for i, move := range moves {
+		col := move.Col
+		if g.GameOver {
+			return fmt.Errorf("coup %d joué après la fin de la partie", i+1)
+		}
+		if col >= g.Cols {
+			return fmt.Errorf("coup %d : colonne %d hors du plateau", i+1, col)
+		}
+
+		if move.Pop {
+			if err := g.popPiece(col, g.CurrentPlayer); err != nil {
+				return fmt.Errorf("coup %d : %s", i+1, err.Message)
+			}
+			continue
+		}
+		if move.Placed {
+			if err := g.placeCell(move.Row, col); err != nil {
+				return fmt.Errorf("coup %d : %s", i+1, err.Message)
+			}
+			continue
+		}
+
+		row := g.placePiece(col, g.CurrentPlayer)
+		if row == -1 {
+			return fmt.Errorf("coup %d : colonne %d pleine", i+1, col)
+		}
+		g.checkGameEnd(row, col)
+	}
+	return nil
+}
+
+// Encode les coups en notation compacte : un caractère base 36 par colonne,
+// précédé de "^" pour un retrait (variante Pop Out) ; une pose sans gravité s'écrit
+// "@" suivi de la ligne puis de la colonne, en base 36 (ex. "@23")
+func encodeMoves(moves []Move) string {
+	var sb strings.Builder
+	for _, move := range moves {
+		switch {
+		case move.Pop:
+			sb.WriteByte('^')
+		case move.Placed:
+			sb.WriteByte('@')
+			sb.WriteString(strconv.FormatInt(int64(move.Row), 36))
+		}
+		sb.WriteString(strconv.FormatInt(int64(move.Col), 36))
+	}
+	return sb.String()
+}
+
+// Note un coup pour la feuille de match : couleur, puis colonne numérotée à partir de 1
+// Un retrait est marqué par "^", comme dans la notation d'export ; une pose sans gravité
+// par "@" et sa ligne, numérotée à partir de 1 depuis le haut (ex. "R-@r3c4")
+func moveNotation(move Move) string {
+	color := "R"
+	if move.Player == PLAYER_2 {
+		color = "Y"
+	}
+	switch {
+	case move.Pop:
+		return fmt.Sprintf("%s-^c%d", color, move.Col+1)
+	case move.Placed:
+		return fmt.Sprintf("%s-@r%dc%d", color, move.Row+1, move.Col+1)
+	}
+	return fmt.Sprintf("%s-c%d", color, move.Col+1)
+}
+
+// Assemble la feuille de match en numérotant les coups par paire : "1. R-c4 Y-c3 2. R-c4"
+func moveTranscript(log []string) string {
+	var sb strings.Builder
+	for i, entry := range log {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		if i%2 == 0 {
+			fmt.Fprintf(&sb, "%d. ", i/2+1)
+		}
+		sb.WriteString(entry)
+	}
+	return sb.String()
+}
+
+// Écrit la partie en notation inspirée du PGN des échecs : des en-têtes [Clé "valeur"],
+// une ligne vide, puis la feuille de match terminée par le résultat
+// L'appelant doit détenir g.mu en lecture
+func (g *GameState) pgn() string {
+	exp := g.export()
+
+	var sb strings.Builder
+	header := func(key, value string) {
+		fmt.Fprintf(&sb, "[%s %s]\n", key, strconv.Quote(value))
+	}
+	header("Event", "Puissance 4")
+	header("Date", g.StartedAt.Format("2006.01.02"))
+	header("Mode", exp.Mode)
+	if exp.Difficulty != "" {
+		header("Difficulty", exp.Difficulty)
+	}
+	if exp.Personality != PERSONALITY_STANDARD {
+		header("Personality", exp.Personality)
+	}
+	if exp.Variant != "" {
+		header("Variant", exp.Variant)
+	}
+	if exp.Lang != "" {
+		header("Lang", exp.Lang)
+	}
+	if exp.HumanPlayer != 0 {
+		header("HumanPlayer", strconv.Itoa(exp.HumanPlayer))
+	}
+	header("Rows", strconv.Itoa(exp.Rows))
+	header("Cols", strconv.Itoa(exp.Cols))
+	header("Win", strconv.Itoa(exp.WinLength))
+	if exp.Overline != nil {
+		header("AllowOverline", strconv.FormatBool(*exp.Overline))
+	}
+	if exp.DoubleWin != DOUBLE_WIN_MOVER {
+		header("DoubleWin", exp.DoubleWin)
+	}
+	if exp.GravityOff {
+		header("GravityOff", "true")
+	}
+	if exp.Repetition {
+		header("RepetitionDraw", "true")
+	}
+	if exp.FirstPlayer == PLAYER_2 {
+		header("FirstPlayer", strconv.Itoa(exp.FirstPlayer))
+	}
+	if exp.Color1 != COLOR_RED || exp.Color2 != COLOR_YELLOW {
+		header("Player1Color", exp.Color1)
+		header("Player2Color", exp.Color2)
+	}
+	if exp.MaxMoves != 0 {
+		header("MaxMoves", strconv.Itoa(exp.MaxMoves))
+	}
+	if exp.OpeningBook {
+		header("OpeningBook", "true")
+	}
+	if exp.Position != "" {
+		header("Position", exp.Position)
+		header("ToMove", strconv.Itoa(exp.ToMove))
+	}
+	if exp.Swapped {
+		header("Swapped", "true")
+	}
+	if exp.Seed != 0 {
+		header("Seed", strconv.FormatInt(exp.Seed, 10))
+	}
+	header("Result", pgnResult(exp.Winner))
+	if exp.Termination != "" {
+		header("Termination", exp.Termination)
+	}
+
+	sb.WriteByte('\n')
+	if transcript := moveTranscript(g.MoveLog); transcript != "" {
+		sb.WriteString(transcript)
+		sb.WriteByte(' ')
+	}
+	sb.WriteString(pgnResult(exp.Winner))
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+// Résultat PGN correspondant au vainqueur (0 pour une partie en cours)
+func pgnResult(winner int) string {
+	switch winner {
+	case PLAYER_1:
+		return PGN_RESULT_PLAYER_1
+	case PLAYER_2:
+		return PGN_RESULT_PLAYER_2
+	case PLAYER_DRAW:
+		return PGN_RESULT_DRAW
+	default:
+		return PGN_RESULT_ONGOING
+	}
+}
+
+// Lit une partie écrite par pgn() et la reconstruit avec importGame, qui en valide chaque coup
+// Les en-têtes inconnus (Event, Date...) sont ignorés ; le résultat annoncé doit correspondre aux coups
+func importPGN(text, lang string) (*GameState, error) {
+	exp := GameExport{Lang: lang}
+	result := ""
+	var moves []Move
+
+	for n, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			key, value, err := parsePGNHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("ligne %d : %v", n+1, err)
+			}
+			if err := exp.setPGNHeader(key, value); err != nil {
+				return nil, fmt.Errorf("ligne %d : %v", n+1, err)
+			}
+			if key == "Result" {
+				result = value
+			}
+			continue
+		}
+
+		for _, token := range strings.Fields(line) {
+			switch {
+			case token == PGN_RESULT_PLAYER_1 || token == PGN_RESULT_PLAYER_2 || token == PGN_RESULT_DRAW || token == PGN_RESULT_ONGOING:
+				if result != "" && result != token {
+					return nil, fmt.Errorf("résultat %q différent de l'en-tête (%q)", token, result)
+				}
+				result = token
+			case strings.HasSuffix(token, ".") && strings.Trim(token, "0123456789.") == "":
+				// Numéro de coup
+			default:
+				move, err := parsePGNMove(token, len(moves), exp.FirstPlayer)
+				if err != nil {
+					return nil, err
+				}
+				moves = append(moves, move)
+			}
+		}
+	}
+
+	exp.Moves = encodeMoves(moves)
+	switch result {
+	case PGN_RESULT_PLAYER_1:
+		exp.Winner = PLAYER_1
+	case PGN_RESULT_PLAYER_2:
+		exp.Winner = PLAYER_2
+	case PGN_RESULT_DRAW:
+		exp.Winner = PLAYER_DRAW
+	}
+
+	game, err := importGame(exp)
+	if err != nil {
+		return nil, err
+	}
+	if result == PGN_RESULT_ONGOING && game.GameOver {
+		return nil, errors.New("la partie est annoncée en cours mais les coups la terminent")
+	}
+	return game, nil
+}
+
+// Découpe un en-tête [Clé "valeur"]
+func parsePGNHeader(line string) (key, value string, err error) {
+	inner, ok := strings.CutSuffix(strings.TrimPrefix(line, "["), "]")
+	if !ok {
+		return "", "", fmt.Errorf("en-tête non fermé : %s", line)
+	}
+	key, quoted, ok := strings.Cut(inner, " ")
+	if !ok || key == "" {
+		return "", "", fmt.Errorf("en-tête sans valeur : %s", line)
+	}
+	value, err = strconv.Unquote(strings.TrimSpace(quoted))
+	if err != nil {
+		return "", "", fmt.Errorf("valeur d'en-tête invalide : %s", line)
+	}
+	return key, value, nil
+}
+
+// Reporte un en-tête PGN dans l'export ; les en-têtes inconnus sont ignorés
+func (exp *GameExport) setPGNHeader(key, value string) error {
+	var err error
+	switch key {
+	case "Mode":
+		exp.Mode = value
+	case "Difficulty":
+		exp.Difficulty = value
+	case "Personality":
+		exp.Personality = value
+	case "Variant":
+		exp.Variant = value
+	case "Lang":
+		exp.Lang = value
+	case "HumanPlayer":
+		exp.HumanPlayer, err = strconv.Atoi(value)
+	case "Rows":
+		exp.Rows, err = strconv.Atoi(value)
+	case "Cols":
+		exp.Cols, err = strconv.Atoi(value)
+	case "Win":
+		exp.WinLength, err = strconv.Atoi(value)
+	case "AllowOverline":
+		var allow bool
+		allow, err = strconv.ParseBool(value)
+		exp.Overline = &allow
+	case "ExactWin":
+		exp.ExactWin, err = strconv.ParseBool(value)
+	case "DoubleWin":
+		exp.DoubleWin = value
+	case "GravityOff":
+		exp.GravityOff, err = strconv.ParseBool(value)
+	case "RepetitionDraw":
+		exp.Repetition, err = strconv.ParseBool(value)
+	case "FirstPlayer":
+		exp.FirstPlayer, err = strconv.Atoi(value)
+	case "Player1Color":
+		exp.Color1 = value
+	case "Player2Color":
+		exp.Color2 = value
+	case "MaxMoves":
+		exp.MaxMoves, err = strconv.Atoi(value)
+	case "OpeningBook":
+		exp.OpeningBook, err = strconv.ParseBool(value)
+	case "Position":
+		exp.Position = value
+	case "ToMove":
+		exp.ToMove, err = strconv.Atoi(value)
+	case "Swapped":
+		exp.Swapped, err = strconv.ParseBool(value)
+	case "Seed":
+		exp.Seed, err = strconv.ParseInt(value, 10, 64)
+	case "Termination":
+		exp.Termination = value
+	case "Result":
+		switch value {
+		case PGN_RESULT_PLAYER_1, PGN_RESULT_PLAYER_2, PGN_RESULT_DRAW, PGN_RESULT_ONGOING:
+		default:
+			return fmt.Errorf("résultat inconnu : %q", value)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("en-tête %s invalide : %q", key, value)
+	}
+	return nil
+}
+
+// Lit un coup de la feuille de match ("R-c4", "Y-^c2"), le index-ième de la partie
+// La couleur doit être celle du joueur dont c'est le tour : celle de first (le joueur 1 si 0)
+// aux coups pairs, l'autre aux impairs
+func parsePGNMove(token string, index, first int) (Move, error) {
+	color, rest, ok := strings.Cut(token, "-")
+	want := "R"
+	if (index%2 == 1) == (first != PLAYER_2) {
+		want = "Y"
+	}
+	if !ok || (color != "R" && color != "Y") {
+		return Move{}, fmt.Errorf("coup %d : %q illisible", index+1, token)
+	}
+	if color != want {
+		return Move{}, fmt.Errorf("coup %d : %q joué par la mauvaise couleur", index+1, token)
+	}
+
+	pop := strings.HasPrefix(rest, "^")
+	rest = strings.TrimPrefix(rest, "^")
+
+	// Pose sans gravité : "@r<ligne>" avant la colonne
+	row := 0
+	placed := !pop && strings.HasPrefix(rest, "@r")
+	if placed {
+		digits, after, ok := strings.Cut(strings.TrimPrefix(rest, "@r"), "c")
+		n, err := strconv.Atoi(digits)
+		if !ok || err != nil || n < 1 {
+			return Move{}, fmt.Errorf("coup %d : ligne de %q invalide", index+1, token)
+		}
+		row, rest = n-1, "c"+after
+	}
+
+	col, err := strconv.Atoi(strings.TrimPrefix(rest, "c"))
+	if !strings.HasPrefix(rest, "c") || err != nil || col < 1 {
+		return Move{}, fmt.Errorf("coup %d : colonne de %q invalide", index+1, token)
+	}
+	return Move{Col: col - 1, Row: row, Pop: pop, Placed: placed}, nil
+}
+
+// Décode la notation compacte produite par encodeMoves
+// Seuls Col, Pop et Placed (avec la ligne de la pose) sont renseignés : la ligne d'un jeton
+// tombé et le joueur viennent du rejeu
+func decodeMoves(notation string) ([]Move, error) {
+	moves := make([]Move, 0, len(notation))
+	pop := false
+	row := -1 // Ligne de la pose en cours de lecture, -1 hors d'une pose
+	placing := false
+	for _, c := range notation {
+		if c == '^' && !pop && !placing {
+			pop = true
+			continue
+		}
+		if c == '@' && !pop && !placing {
+			placing = true
+			continue
+		}
+		n, err := strconv.ParseInt(string(c), 36, 0)
+		if err != nil {
+			return nil, fmt.Errorf("coup %d : caractère %q invalide", len(moves)+1, c)
+		}
+		if placing && row == -1 {
+			row = int(n)
+			continue
+		}
+		move := Move{Col: int(n), Pop: pop}
+		if placing {
+			move.Row, move.Placed = row, true
+		}
+		moves = append(moves, move)
+		pop, placing, row = false, false, -1
+	}
+	if pop {
+		return nil, fmt.Errorf("coup %d : retrait sans colonne", len(moves)+1)
+	}
+	if placing {
+		return nil, fmt.Errorf("coup %d : pose sans case", len(moves)+1)
+	}
+	return moves, nil
+}
+
+// Écrit une position ligne par ligne, du haut vers le bas, séparées par "/" : un chiffre par case
+// (0 vide, 1 rouge, 2 jaune), par exemple "0000000/.../1220000" ; vide pour un plateau absent
+func encodePosition(board Board) string {
+	rows := make([]string, len(board))
+	for row := range board {
+		var sb strings.Builder
+		for _, cell := range board[row] {
+			sb.WriteByte(byte('0' + cell))
+		}
+		rows[row] = sb.String()
+	}
+	return strings.Join(rows, "/")
+}
+
+// Relit une position écrite par encodePosition ; sa cohérence est vérifiée par setPosition
+func decodePosition(notation string) (Board, error) {
+	lines := strings.Split(notation, "/")
+	board := make(Board, len(lines))
+	for row, line := range lines {
+		board[row] = make([]int, len(line))
+		for col, c := range line {
+			if c < '0' || c > '2' {
+				return nil, fmt.Errorf("position, ligne %d : caractère %q invalide", row+1, c)
+			}
+			board[row][col] = int(c - '0')
+		}
+	}
+	return board, nil
+}
+
+// ============================================================================
+// AI FUNCTIONS
+// ============================================================================
+
+// Joue une partie complète entre deux IA, sans rendu ni session
+// difficulties[0] joue les rouges, difficulties[1] les jaunes
+// La simulation s'arrête avec une erreur dès que ctx est annulé
+func simulateGame(ctx context.Context, difficulties, personalities [2]string, variant string, rows, cols, winLength int) (*GameState, error) {
+	game := newGameState(GAME_MODE_AI_VS_AI, difficulties[0], variant, DEFAULT_LANG, rows, cols, winLength, PLAYER_1)
+
+	// Garde-fou : une partie standard tient en rows*cols coups ; le Pop Out peut en jouer plus
+	maxMoves := 2 * rows * cols
+	for !game.GameOver {
+		played := len(game.Moves)
+		if played >= maxMoves {
+			return nil, fmt.Errorf("simulation interrompue après %d coups sans fin de partie", played)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("simulation interrompue au coup %d : %w", played+1, err)
+		}
+
+		game.Difficulty = difficulties[game.CurrentPlayer-1]
+		game.Personality = personalities[game.CurrentPlayer-1]
+		if !game.aiMakeMove(ctx) || len(game.Moves) == played {
+			return nil, fmt.Errorf("l'IA du joueur %d n'a pas pu jouer au coup %d", game.CurrentPlayer, played+1)
+		}
+	}
+
+	game.Difficulty, game.Personality = difficulties[0], personalities[0]
+	return game, nil
+}
+
+// Joue n parties entre les IA A et B sur un nombre borné de goroutines
+// Les couleurs alternent d'une partie à l'autre, pour ne pas avantager celle qui commence
+// Une fois ctx annulé, les parties restantes échouent aussitôt
+func simulateBatch(ctx context.Context, n int, difficultyA, difficultyB, variant string, rows, cols, winLength int) (BatchResult, error) {
+	type outcome struct {
+		aWon, bWon bool
+		moves      int
+		err        error
+	}
+
+	jobs := make(chan int)
+	outcomes := make(chan outcome)
+
+	workers := min(runtime.NumCPU(), n)
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				// A joue les rouges aux parties paires, les jaunes aux parties impaires
+				aPlayer, difficulties := PLAYER_1, [2]string{difficultyA, difficultyB}
+				if i%2 == 1 {
+					aPlayer, difficulties = PLAYER_2, [2]string{difficultyB, difficultyA}
+				}
+
+				game, err := simulateGame(ctx, difficulties, [2]string{PERSONALITY_STANDARD, PERSONALITY_STANDARD}, variant, rows, cols, winLength)
+				if err != nil {
+					outcomes <- outcome{err: err}
+					continue
+				}
+				outcomes <- outcome{
+					aWon:  game.Winner == aPlayer,
+					bWon:  game.Winner == PLAYER_2+PLAYER_1-aPlayer,
+					moves: len(game.Moves),
+				}
+			}
+		}()
+	}
+
+	go func() {
+		for i := 0; i < n; i++ {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	result := BatchResult{Games: n}
+	totalMoves := 0
+	var firstErr error
+	for i := 0; i < n; i++ {
+		o := <-outcomes
+		switch {
+		case o.err != nil:
+			if firstErr == nil {
+				firstErr = o.err
+			}
+		case o.aWon:
+			result.AWins++
+		case o.bWon:
+			result.BWins++
+		default:
+			result.Draws++
+		}
+		totalMoves += o.moves
+	}
+
+	if firstErr != nil {
+		return BatchResult{}, firstErr
+	}
+	if n > 0 {
+		result.AvgMoves = float64(totalMoves) / float64(n)
+	}
+	return result, nil
+}
+
+// Fait jouer l'IA pour le joueur dont c'est le tour
+// L'appelant doit détenir g.mu en écriture ; l'annulation de ctx écourte la réflexion (voir decideMove),
+// ce qui borne aussi le temps pendant lequel la partie reste verrouillée
+// Retourne false si aucun coup n'a pu être joué
+func (g *GameState) aiMakeMove(ctx context.Context) bool {
+	start := time.Now()
+
+	// L'IA ne sait que placer : sur un plateau plein en Pop Out, elle retire son premier jeton disponible
+	if g.Board.isBoardFull() && g.canPop(g.CurrentPlayer) {
+		for col := 0; col < g.Cols; col++ {
+			player := g.CurrentPlayer
+			if g.popPiece(col, player) == nil {
+				// Retrait sans raisonnement à expliquer
+				g.LastAIDecision = nil
+				g.logEvent(EVENT_AI, "joueur %d, retrait colonne %d (plateau plein)", player, col+1)
+				metrics.observeAIMove(time.Since(start))
+				return true
+			}
+		}
+	}
+
+	var decision AIDecision
+	if g.Personality == PERSONALITY_STALL {
+		decision = decideStallMove(g.Board, g.WinLength, g.AllowOverline, g.CurrentPlayer, g.moveRand())
+	} else if g.Personality == PERSONALITY_MIRROR {
+		decision = decideMirrorMove(ctx, g.Board, g.WinLength, g.AllowOverline, g.Difficulty, g.CurrentPlayer, g.LastMove, g.moveRand())
+	} else if col, ok := g.openingBookMove(); ok {
+		decision = AIDecision{Col: col, Reason: AI_REASON_BOOK}
+	} else {
+		decision = decideMove(ctx, g.Board, g.WinLength, g.AllowOverline, g.Difficulty, g.CurrentPlayer, g.moveRand())
+	}
+	if decision.Search == nil {
+		// Coup choisi sans recherche (personnalité, répertoire d'ouvertures)
+		decision.Search = &SearchStats{TimeMs: time.Since(start).Milliseconds()}
+	}
+	col := decision.Col
+	decision.Player = g.CurrentPlayer
+	g.logEvent(EVENT_AI, "joueur %d, niveau %s : colonne %d (%s, écart %d)", g.CurrentPlayer, g.Difficulty, col+1, decision.Reason, decision.Confidence)
+	row := g.placePiece(col, g.CurrentPlayer)
+
+	if row == -1 {
+		return false
+	}
+	decision.MoveNumber = len(g.Moves)
+	g.LastAIDecision = &decision
+	metrics.observeAIMove(time.Since(start))
+
+	g.checkGameEnd(row, col)
+	return true
+}
+
+// Cherche le coup de l'IA dans le répertoire d'ouvertures
+// Le répertoire ne vaut que pour le plateau classique, partie commencée sur un plateau vide, et ne sert
+// pas au niveau facile, dont les bévues font partie du jeu
+func (g *GameState) openingBookMove() (int, bool) {
+	if !g.UseOpeningBook || g.Difficulty == DIFFICULTY_EASY || g.Variant != VARIANT_STANDARD || !g.AllowOverline || g.StartBoard != nil ||
+		g.Rows != BOARD_ROWS || g.Cols != BOARD_COLS || g.WinLength != WINNING_COUNT {
+		return 0, false
+	}
+
+	key := encodeMoves(g.Moves)
+	if col, ok := openingBook[key]; ok {
+		return col, true
+	}
+
+	// Position symétrique : on joue le miroir du coup du répertoire
+	mirror := []byte(key)
+	for i, c := range mirror {
+		mirror[i] = byte('0' + BOARD_COLS - 1 - int(c-'0'))
+	}
+	if col, ok := openingBook[string(mirror)]; ok {
+		return BOARD_COLS - 1 - col, true
+	}
+	return 0, false
+}
+
+// Générateur aléatoire de l'IA pour le prochain coup, dérivé de la graine et du nombre de coups
+// Même graine et mêmes coups donnent la même réponse, y compris après une annulation ou un redémarrage
+func (g *GameState) moveRand() *rand.Rand {
+	return rand.New(rand.NewSource(g.Seed ^ int64(len(g.Moves))<<32))
+}
+
+// Joueur incarné par l'IA en mode IA
+func (g *GameState) aiPlayer() int {
+	return PLAYER_2 + PLAYER_1 - g.HumanPlayer
+}
+
+// Vérifie si c'est à l'IA de jouer dans une partie en cours
+func (g *GameState) isAITurn() bool {
+	return !g.GameOver && g.Mode == GAME_MODE_AI && g.CurrentPlayer == g.aiPlayer()
+}
+
+// Valide le mode de jeu demandé pour une nouvelle partie ; vide, il vaut deux joueurs
+// Le mode IA contre IA est réservé aux simulations : personne ne ferait jouer ses coups
+func parseMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return GAME_MODE_TWO_PLAYER, nil
+	case GAME_MODE_TWO_PLAYER, GAME_MODE_AI:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("mode de jeu inconnu : %q (%s ou %s)", mode, GAME_MODE_TWO_PLAYER, GAME_MODE_AI)
+	}
+}
+
+// Vérifie si la difficulté demandée est connue
+func isValidDifficulty(difficulty string) bool {
+	switch difficulty {
+	case DIFFICULTY_EASY, DIFFICULTY_MEDIUM, DIFFICULTY_HARD:
+		return true
+	default:
+		return false
+	}
+}
+
+// Vérifie si la personnalité demandée est connue
+func isValidPersonality(personality string) bool {
+	switch personality {
+	case PERSONALITY_STANDARD, PERSONALITY_STALL, PERSONALITY_MIRROR:
+		return true
+	default:
+		return false
+	}
+}
+
+// Choisit le coup de la personnalité mirror : la colonne symétrique (Cols - 1 - col) du dernier coup adverse,
+// ou celle du centre si l'IA ouvre la partie
+// Quand cette colonne est pleine, l'IA joue le coup de la personnalité standard à son niveau (voir decideMove)
+func decideMirrorMove(ctx context.Context, board Board, winLength int, allowOverline bool, difficulty string, player int, lastMove *[2]int, rng *rand.Rand) AIDecision {
+	col := board.cols() / 2
+	if lastMove != nil {
+		col = board.cols() - 1 - lastMove[1]
+	}
+	if board.isValidMove(col) {
+		return AIDecision{Col: col, Reason: AI_REASON_MIRROR}
+	}
+	return decideMove(ctx, board, winLength, allowOverline, difficulty, player, rng)
+}
+
+// Choisit le coup de la personnalité stall, qui fait durer la partie
+// Une victoire immédiate de l'adversaire est toujours bloquée ; sinon l'IA écarte d'abord les coups qui
+// lui offrent un gain au coup suivant, puis ceux qui gagnent, et garde parmi les autres celui qui laisse
+// le moins de menaces sur le plateau (les égalités sont départagées par rng)
+func decideStallMove(board Board, winLength int, allowOverline bool, player int, rng *rand.Rand) AIDecision {
+	opponent := PLAYER_2 + PLAYER_1 - player
+	moves := board.getValidMoves()
+	switch {
+	case len(moves) == 1:
+		return AIDecision{Col: moves[0], Reason: AI_REASON_ONLY_MOVE}
+	case len(moves) == 0:
+		return AIDecision{Reason: AI_REASON_ONLY_MOVE}
+	}
+	if block := findWinningMove(board, opponent, winLength, allowOverline); block != -1 {
+		return AIDecision{Col: block, Reason: AI_REASON_BLOCKS}
+	}
+
+	// Coût d'un coup : le rang de son issue d'abord, puis le nombre de menaces qu'il laisse
+	const (
+		stallSafe = iota
+		stallWins
+		stallLoses
+	)
+	type stallCost struct{ rank, threats int }
+	costs := make(map[int]stallCost, len(moves))
+	best := stallCost{stallLoses + 1, 0}
+	for _, col := range moves {
+		child, _ := board.Place(col, player)
+		cost := stallCost{stallSafe, countThreats(child, winLength)}
+		switch {
+		case findWinningMove(child, opponent, winLength, allowOverline) != -1:
+			cost.rank = stallLoses
+		case wouldWin(board, col, player, winLength, allowOverline):
+			cost.rank = stallWins
+		}
+		costs[col] = cost
+		if cost.rank < best.rank || (cost.rank == best.rank && cost.threats < best.threats) {
+			best = cost
+		}
+	}
+
+	// Parmi les coups du meilleur rang, l'écart de menaces avec le suivant mesure la netteté du choix
+	var candidates []int
+	second := -1
+	for _, col := range moves {
+		switch cost := costs[col]; {
+		case cost == best:
+			candidates = append(candidates, col)
+		case cost.rank == best.rank && (second == -1 || cost.threats < second):
+			second = cost.threats
+		}
+	}
+
+	decision := AIDecision{Col: candidates[rng.Intn(len(candidates))], Reason: AI_REASON_STALL}
+	if len(candidates) == 1 && second != -1 {
+		decision.Confidence = second - best.threats
+	}
+	switch best.rank {
+	case stallWins:
+		decision.Reason = AI_REASON_WINS
+	case stallLoses:
+		decision.Reason = AI_REASON_LOSING
+	}
+	return decision
+}
+
+// Compte les fenêtres du plateau à qui il ne manque qu'un jeton pour aligner, pour les deux joueurs
+func countThreats(board Board, winLength int) int {
+	threats := 0
+	board.eachWindow(winLength, func(counts [3]int) bool {
+		if counts[CELL_EMPTY] == 1 && (counts[PLAYER_1] == winLength-1 || counts[PLAYER_2] == winLength-1) {
+			threats++
+		}
+		return true
+	})
+	return threats
+}
+
+// Calcule le meilleur mouvement pour le joueur donné selon la difficulté
+// Ne modifie jamais le plateau reçu : peut être appelée depuis n'importe quelle goroutine,
+// avec un générateur rng propre à l'appel (voir moveRand)
+// L'annulation de ctx écourte la recherche (voir decideMove)
+func getBestMove(ctx context.Context, board Board, winLength int, allowOverline bool, difficulty string, player int, rng *rand.Rand) int {
+	return decideMove(ctx, board, winLength, allowOverline, difficulty, player, rng).Col
+}
+
+// Choisit le coup de l'IA selon la difficulté et explique le choix
+// L'écart de score vient de la recherche elle-même (minimaxRoot) ; au niveau facile, qui ne cherche pas,
+// d'une évaluation à un coup de chaque colonne
+// Si ctx est annulé pendant la recherche, l'IA se contente du meilleur coup trouvé jusque-là
+// (au pire celui de fallbackMove) : un coup est toujours choisi
+func decideMove(ctx context.Context, board Board, winLength int, allowOverline bool, difficulty string, player int, rng *rand.Rand) AIDecision {
+	start := time.Now()
+	// minimax maximise pour PLAYER_2 et minimise pour PLAYER_1
+	maximizing := player == PLAYER_2
+	stats := &SearchStats{}
+
+	var col, best, otherCol, second int
+	switch difficulty {
+	case DIFFICULTY_MEDIUM:
+		depth := searchDepth(MINIMAX_DEPTH_MEDIUM, board.cols())
+		var done bool
+		if col, best, otherCol, second, done = minimaxRoot(ctx, board, winLength, allowOverline, depth, maximizing, stats); done {
+			stats.DepthReached = depth
+		} else {
+			col, best, otherCol, second = fallbackMove(board, winLength, allowOverline, player, rng)
+		}
+	case DIFFICULTY_HARD:
+		col, best, otherCol, second = getBestMoveTimed(ctx, board, winLength, allowOverline, player, HARD_TIME_BUDGET, rng, stats)
+	default:
+		col = getSimpleMove(board, winLength, allowOverline, player, EASY_BLUNDER_RATE, rng)
+		best, otherCol, second = onePlyScores(board, winLength, allowOverline, player, col)
+	}
+
+	// Scores ramenés au point de vue de l'IA
+	if !maximizing {
+		best, second = -best, -second
+	}
+	decision := explainMove(board, winLength, allowOverline, player, col, best, otherCol, second)
+	stats.TimeMs = time.Since(start).Milliseconds()
+	decision.Search = stats
+	return decision
+}
+
+// Coup de repli quand la recherche n'a pas pu aboutir : le coup simple sans bévue (gain, blocage ou
+// centre), avec ses scores à un coup et la meilleure autre colonne pour expliquer le choix
+func fallbackMove(board Board, winLength int, allowOverline bool, player int, rng *rand.Rand) (col, best, otherCol, second int) {
+	col = getSimpleMove(board, winLength, allowOverline, player, 0, rng)
+	best, otherCol, second = onePlyScores(board, winLength, allowOverline, player, col)
+	return col, best, otherCol, second
+}
+
+// Évalue à un coup la colonne choisie et la meilleure des autres, du point de vue de PLAYER_2 comme minimax
+// Sans autre colonne jouable, otherCol vaut -1 et les deux scores sont égaux
+func onePlyScores(board Board, winLength int, allowOverline bool, player, chosen int) (score, otherCol, other int) {
+	otherCol = -1
+	first := true
+	for _, col := range board.getValidMoves() {
+		child, row := board.Place(col, player)
+		value := evaluateBoard(child, winLength, PLAYER_2)
+		if winner, _, _ := child.checkForWin(row, col, winLength, allowOverline); winner == player {
+			value = MINIMAX_WIN_SCORE
+			if player == PLAYER_1 {
+				value = -MINIMAX_WIN_SCORE
+			}
+		}
+
+		if col == chosen {
+			score = value
+			continue
+		}
+		better := value > other
+		if player == PLAYER_1 {
+			better = value < other
+		}
+		if first || better {
+			otherCol, other, first = col, value, false
+		}
+	}
+	if first {
+		other = score
+	}
+	return score, otherCol, other
+}
+
+// Décrit le coup choisi à partir de son score et de celui de la meilleure autre colonne otherCol
+// (point de vue de l'IA, -1 sans autre colonne jouable)
+func explainMove(board Board, winLength int, allowOverline bool, player, col, score, otherCol, other int) AIDecision {
+	decision := AIDecision{Col: col, Confidence: score - other, Score: score}
+	if otherCol >= 0 {
+		decision.Alternative = &ColumnScore{Col: otherCol, Score: other}
+	}
+	opponent := PLAYER_2 + PLAYER_1 - player
+
+	switch {
+	case len(board.getValidMoves()) == 1:
+		decision.Reason = AI_REASON_ONLY_MOVE
+		decision.Confidence = 0
+	case wouldWin(board, col, player, winLength, allowOverline):
+		decision.Reason = AI_REASON_WINS
+	case findWinningMove(board, player, winLength, allowOverline) != -1:
+		decision.Reason = AI_REASON_BLUNDER
+	case wouldWin(board, col, opponent, winLength, allowOverline):
+		decision.Reason = AI_REASON_BLOCKS
+	case score <= -MINIMAX_WIN_SCORE:
+		// Avant le blocage manqué : face à deux menaces, ne pas bloquer n'est pas une bévue
+		decision.Reason = AI_REASON_LOSING
+	case findWinningMove(board, opponent, winLength, allowOverline) != -1:
+		decision.Reason = AI_REASON_BLUNDER
+	case score >= MINIMAX_WIN_SCORE:
+		decision.Reason = AI_REASON_FORCED
+	case decision.Confidence >= AI_CLEAR_CHOICE_GAP:
+		decision.Reason = AI_REASON_CLEAR
+	default:
+		decision.Reason = AI_REASON_MARGINAL
+	}
+	return decision
+}
+
+// Approfondissement itératif : minimax à profondeur 1, 2, 3... jusqu'à épuisement du budget
+// Retourne le meilleur coup de la dernière profondeur explorée entièrement, avec son score et celui
+// de la meilleure autre colonne, pour un temps de réponse stable quelle que soit la complexité de la position
+// L'annulation de ctx arrête aussi la recherche avant la fin du budget
+// stats, s'il n'est pas nil, cumule les positions de toutes les profondeurs et retient la dernière achevée
+func getBestMoveTimed(ctx context.Context, board Board, winLength int, allowOverline bool, player int, budget time.Duration, rng *rand.Rand, stats *SearchStats) (col, best, otherCol, second int) {
+	ctx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+	maximizing := player == PLAYER_2
+
+	// Repli si même la profondeur 1 n'a pas pu être explorée dans le budget
+	col, best, otherCol, second = fallbackMove(board, winLength, allowOverline, player, rng)
+
+	empty := 0
+	for _, row := range board {
+		for _, cell := range row {
+			if cell == CELL_EMPTY {
+				empty++
+			}
+		}
+	}
+
+	for depth := 1; depth <= empty; depth++ {
+		bestCol, score, nextCol, other, done := minimaxRoot(ctx, board, winLength, allowOverline, depth, maximizing, stats)
+		if !done {
+			// Recherche interrompue : ses scores partiels ne sont pas fiables
+			break
+		}
+		col, best, otherCol, second = bestCol, score, nextCol, other
+		if stats != nil {
+			stats.DepthReached = depth
+		}
+
+		// Issue forcée trouvée : chercher plus loin ne changera pas le résultat
+		if score >= MINIMAX_WIN_SCORE || score <= -MINIMAX_WIN_SCORE {
+			break
+		}
+	}
+
+	return col, best, otherCol, second
+}
+
+// Colonnes jouables, du centre vers les bords (3, 2, 4, 1, 5, 0, 6 sur le plateau classique)
+// Les meilleurs coups étant souvent centraux, l'élagage alpha-bêta coupe plus tôt : à profondeur 7,
+// minimax explore environ six fois moins de positions qu'en parcourant les colonnes de gauche à droite
+// (voir BenchmarkMinimaxOrdered)
+func (b Board) orderedMoves() []int {
+	moves := b.getValidMoves()
+	center := b.cols() / 2
+	sort.SliceStable(moves, func(i, j int) bool {
+		return abs(moves[i]-center) < abs(moves[j]-center)
+	})
+	return moves
+}
+
+// Valeur absolue d'un entier
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Réduit la profondeur de recherche sur les plateaux plus larges que le classique
+// pour garder un temps de réponse comparable (un demi-coup de moins par 2 colonnes)
+func searchDepth(depth, cols int) int {
+	if cols > BOARD_COLS {
+		depth -= (cols - BOARD_COLS + 1) / 2
+	}
+	return max(depth, 2)
+}
+
+// Stratégie simple à un coup : gagner, bloquer, centre, sinon aléatoire
+// Avec une probabilité blunderRate, l'IA passe à côté du gain ou du blocage et joue au hasard
+// (pondéré vers le centre) ; le tirage vient de rng, donc de la graine de la partie
+func getSimpleMove(board Board, winLength int, allowOverline bool, player int, blunderRate float64, rng *rand.Rand) int {
+	win := findWinningMove(board, player, winLength, allowOverline)
+	block := findWinningMove(board, PLAYER_2+PLAYER_1-player, winLength, allowOverline)
+	if (win != -1 || block != -1) && blunderRate > 0 && rng.Float64() < blunderRate {
+		return findWeightedRandomMove(board, rng)
+	}
+
+	// Priorité 1: L'IA peut-elle gagner ?
+	if win != -1 {
+		return win
+	}
+
+	// Priorité 2: Bloquer l'adversaire s'il peut gagner
+	if block != -1 {
+		return block
+	}
+
+	// Priorité 3: Jouer au centre (stratégique)
+	centerCol := board.cols() / 2
+	if board.isValidMove(centerCol) {
+		return centerCol
+	}
+
+	// Sinon: mouvement aléatoire valide
+	return findRandomValidMove(board, rng)
+}
+
+// Trouve un mouvement gagnant pour le joueur spécifié, en essayant d'abord les colonnes centrales
+func findWinningMove(board Board, player, winLength int, allowOverline bool) int {
+	for _, col := range board.orderedMoves() {
+		if wouldWin(board, col, player, winLength, allowOverline) {
+			return col
+		}
+	}
+	return -1
+}
+
+// Choisit un mouvement aléatoire parmi les mouvements valides
+func findRandomValidMove(board Board, rng *rand.Rand) int {
+	moves := board.getValidMoves()
+	if len(moves) == 0 {
+		return 0
+	}
+	return moves[rng.Intn(len(moves))]
+}
+
+// Choisit un mouvement valide au hasard, les colonnes centrales étant plus probables
+// Le poids d'une colonne diminue d'un par colonne d'écart avec le centre
+func findWeightedRandomMove(board Board, rng *rand.Rand) int {
+	moves := board.getValidMoves()
+	if len(moves) == 0 {
+		return 0
+	}
+
+	center := board.cols() / 2
+	weights := make([]int, len(moves))
+	total := 0
+	for i, col := range moves {
+		weights[i] = center + 1 - abs(col-center)
+		total += weights[i]
+	}
+
+	pick := rng.Intn(total)
+	for i, weight := range weights {
+		if pick < weight {
+			return moves[i]
+		}
+		pick -= weight
+	}
+	return moves[len(moves)-1]
+}
+
+// Simule un mouvement sur une copie du plateau et vérifie s'il serait gagnant
+// Sans allowOverline, un alignement plus long que winLength ne gagne pas (voir checkForWin)
+func wouldWin(board Board, col, player, winLength int, allowOverline bool) bool {
+	row := board.dropRow(col)
+	if row == -1 {
+		return false
+	}
+	next := board.Clone()
+	next[row][col] = player
+	winner, _, _ := next.checkForWin(row, col, winLength, allowOverline)
+	return winner == player
+}
+
+// Minimax avec élagage alpha-bêta, du point de vue de PLAYER_2
+// Le plateau est passé par copie : la simulation ne modifie jamais la partie en cours
+// Retourne le score de la position et la meilleure colonne (-1 si aucune)
+func minimax(board Board, winLength int, allowOverline bool, depth, alpha, beta int, maximizing bool) (score int, col int) {
+	score, col, _ = minimaxUntil(context.Background(), board, winLength, allowOverline, depth, alpha, beta, maximizing)
+	return score, col
+}
+
+// Minimax interrompu dès que ctx est annulé ou arrive à échéance
+// done vaut false si la recherche a été interrompue : score et col sont alors inutilisables
+func minimaxUntil(ctx context.Context, board Board, winLength int, allowOverline bool, depth, alpha, beta int, maximizing bool) (score int, col int, done bool) {
+	return minimaxCached(ctx, board, winLength, allowOverline, depth, alpha, beta, maximizing, make(map[string]int), nil)
+}
+
+// Minimax à la racine qui retient, en plus du meilleur coup, le score exact de la meilleure autre colonne
+// Chaque colonne est cherchée avec une fenêtre bornée par le deuxième score connu (et non le premier) :
+// un peu moins d'élagage, mais l'écart entre les deux premiers coups sort de la même recherche
+// S'il n'y a qu'une colonne jouable, second vaut best et secondCol -1
+// stats, s'il n'est pas nil, compte les positions explorées (voir minimaxCached)
+func minimaxRoot(ctx context.Context, board Board, winLength int, allowOverline bool, depth int, maximizing bool, stats *SearchStats) (col, best, secondCol, second int, done bool) {
+	if stats != nil {
+		stats.NodesSearched++
+	}
+	moves := board.orderedMoves()
+	if len(moves) == 0 {
+		return -1, 0, -1, 0, true
+	}
+
+	player := PLAYER_1
+	best, second = math.MaxInt, math.MaxInt
+	if maximizing {
+		player = PLAYER_2
+		best, second = math.MinInt, math.MinInt
+	}
+	cache := make(map[string]int)
+
+	col, secondCol = moves[0], -1
+	for _, c := range moves {
+		child, row := board.Place(c, player)
+
+		childScore, searched := 0, true
+		if winner, _, _ := child.checkForWin(row, c, winLength, allowOverline); winner == player {
+			childScore = MINIMAX_WIN_SCORE + depth
+			if !maximizing {
+				childScore = -childScore
+			}
+		} else if maximizing {
+			childScore, _, searched = minimaxCached(ctx, child, winLength, allowOverline, depth-1, second, math.MaxInt, false, cache, stats)
+		} else {
+			childScore, _, searched = minimaxCached(ctx, child, winLength, allowOverline, depth-1, math.MinInt, second, true, cache, stats)
+		}
+		if !searched {
+			return -1, 0, -1, 0, false
+		}
+
+		// Hors fenêtre, le score n'est qu'une borne : la colonne n'est pas parmi les deux premières
+		switch {
+		case maximizing && childScore > best, !maximizing && childScore < best:
+			if c != moves[0] {
+				secondCol = col
+			}
+			col, best, second = c, childScore, best
+		case maximizing && childScore > second, !maximizing && childScore < second:
+			secondCol, second = c, childScore
+		}
+	}
+
+	if len(moves) == 1 {
+		second = best
+	}
+	return col, best, secondCol, second, true
+}
+
+// Clé d'une position dans la table de transposition : plateau canonique, profondeur restante et camp au trait
+// La profondeur en fait partie car elle change l'horizon et le bonus des victoires rapides
+func transpositionKey(board Board, depth int, maximizing bool) string {
+	side := "1"
+	if maximizing {
+		side = "2"
+	}
+	return canonicalBoard(board) + strconv.Itoa(depth) + side
+}
+
+// Corps de minimaxUntil, avec une table de transposition propre à la recherche (nil pour s'en passer)
+// Seuls les scores exacts y sont retenus : un score hors de la fenêtre alpha-bêta n'est qu'une borne
+// Les feuilles n'y entrent pas : les évaluer coûte à peine plus que calculer leur clé
+// stats, s'il n'est pas nil, compte chaque position explorée ; une réponse de la table n'en est pas une
+func minimaxCached(ctx context.Context, board Board, winLength int, allowOverline bool, depth, alpha, beta int, maximizing bool, cache map[string]int, stats *SearchStats) (score int, col int, done bool) {
+	if ctx.Err() != nil {
+		return 0, -1, false
+	}
+	if stats != nil {
+		stats.NodesSearched++
+	}
+
+	moves := board.orderedMoves()
+
+	// Plateau plein : match nul
+	if len(moves) == 0 {
+		return 0, -1, true
+	}
+
+	// Profondeur atteinte : évaluation heuristique
+	if depth == 0 {
+		return evaluateBoard(board, winLength, PLAYER_2), -1, true
+	}
+
+	player := PLAYER_1
+	if maximizing {
+		player = PLAYER_2
+	}
+
+	bestCol := moves[0]
+	if maximizing {
+		score = math.MinInt
+	} else {
+		score = math.MaxInt
+	}
+
+	for _, c := range moves {
+		child, row := board.Place(c, player)
+
+		// Une victoire rapide vaut plus qu'une victoire lointaine
+		var childScore int
+		if winner, _, _ := child.checkForWin(row, c, winLength, allowOverline); winner == player {
+			childScore = MINIMAX_WIN_SCORE + depth
+			if !maximizing {
+				childScore = -childScore
+			}
+		} else {
+			var key string
+			cached := false
+			if cache != nil && depth > 1 {
+				key = transpositionKey(child, depth-1, !maximizing)
+				childScore, cached = cache[key]
+			}
+			if !cached {
+				childScore, _, done = minimaxCached(ctx, child, winLength, allowOverline, depth-1, alpha, beta, !maximizing, cache, stats)
+				if !done {
+					return 0, -1, false
+				}
+				if key != "" && childScore > alpha && childScore < beta {
+					cache[key] = childScore
+				}
+			}
+		}
+
+		if maximizing {
+			if childScore > score {
+				score, bestCol = childScore, c
+			}
+			alpha = max(alpha, score)
+		} else {
+			if childScore < score {
+				score, bestCol = childScore, c
+			}
+			beta = min(beta, score)
+		}
+
+		if alpha >= beta {
+			break
+		}
+	}
+
+	return score, bestCol, true
+}
+
+// Résout la position pour le joueur au trait, en posant des jetons uniquement (pas de retrait Pop Out)
+// Approfondissement itératif : une issue courte est prouvée sans explorer toute la profondeur demandée
+// Si ctx est annulé ou arrive à échéance, le résultat est celui de la dernière profondeur explorée entièrement
+func solvePosition(ctx context.Context, board Board, winLength int, allowOverline bool, player, depth int) SolveResult {
+	result := SolveResult{Result: SOLVE_RESULT_UNKNOWN, BestCol: -1}
+	moves := board.orderedMoves()
+	if len(moves) == 0 {
+		result.Result = SOLVE_RESULT_DRAW
+		return result
+	}
+	result.BestCol = moves[0]
+
+	for d := 1; d <= depth; d++ {
+		next, done := solveAtDepth(ctx, board, winLength, allowOverline, player, d)
+		if !done {
+			break
+		}
+		result = next
+		if result.Result != SOLVE_RESULT_UNKNOWN {
+			break
+		}
+	}
+	return result
+}
+
+// Résout la position à la profondeur donnée ; done vaut false si l'annulation de ctx a interrompu la recherche
+// Deux recherches encadrent la valeur réelle : l'une compte l'horizon comme perdu pour le joueur, l'autre
+// comme gagné ; l'issue n'est prouvée que si l'horizon ne change rien
+func solveAtDepth(ctx context.Context, board Board, winLength int, allowOverline bool, player, depth int) (result SolveResult, done bool) {
+	result = SolveResult{Result: SOLVE_RESULT_UNKNOWN, Depth: depth}
+
+	lower, col, done := negamax(ctx, board, winLength, allowOverline, player, depth, 0, -SOLVE_WIN_SCORE-1, SOLVE_WIN_SCORE+1, -SOLVE_WIN_SCORE)
+	if !done {
+		return result, false
+	}
+	result.BestCol = col
+	if lower > 0 {
+		result.Result = SOLVE_RESULT_WIN
+		result.InMoves = (SOLVE_WIN_SCORE - lower + 1) / 2
+		return result, true
+	}
+
+	upper, col, done := negamax(ctx, board, winLength, allowOverline, player, depth, 0, -SOLVE_WIN_SCORE-1, SOLVE_WIN_SCORE+1, SOLVE_WIN_SCORE)
+	switch {
+	case !done:
+		return result, false
+	case upper < 0:
+		// Toutes les lignes sont perdues : on retient la résistance la plus longue
+		result.Result = SOLVE_RESULT_LOSS
+		result.InMoves = (SOLVE_WIN_SCORE + upper + 1) / 2
+		result.BestCol = col
+	case lower == 0 && upper == 0:
+		result.Result = SOLVE_RESULT_DRAW
+	}
+	return result, true
+}
+
+// Négamax alpha-bêta sans heuristique, du point de vue du joueur au trait
+// Un gain au demi-coup p vaut SOLVE_WIN_SCORE-p, pour préférer les gains rapides et les défaites lentes
+// Une position à l'horizon vaut horizon, exprimé pour le joueur à la racine (ply 0)
+func negamax(ctx context.Context, board Board, winLength int, allowOverline bool, player, depth, ply, alpha, beta, horizon int) (score int, col int, done bool) {
+	if ctx.Err() != nil {
+		return 0, -1, false
+	}
+
+	moves := board.orderedMoves()
+	if len(moves) == 0 {
+		return 0, -1, true
+	}
+	if depth == 0 {
+		if ply%2 == 1 {
+			return -horizon, -1, true
+		}
+		return horizon, -1, true
+	}
+
+	score, col = math.MinInt, moves[0]
+	for _, c := range moves {
+		child, row := board.Place(c, player)
+
+		var childScore int
+		if winner, _, _ := child.checkForWin(row, c, winLength, allowOverline); winner == player {
+			childScore = SOLVE_WIN_SCORE - (ply + 1)
+		} else {
+			childScore, _, done = negamax(ctx, child, winLength, allowOverline, PLAYER_2+PLAYER_1-player, depth-1, ply+1, -beta, -alpha, horizon)
+			if !done {
+				return 0, -1, false
+			}
+			childScore = -childScore
+		}
+
+		if childScore > score {
+			score, col = childScore, c
+		}
+		alpha = max(alpha, score)
+		if alpha >= beta {
+			break
+		}
+	}
+
+	return score, col, true
+}
+
+// Évalue chaque colonne jouable du point de vue du joueur donné, sans modifier le plateau
+func analyzeMoves(board Board, winLength int, allowOverline bool, player int) []ColumnAnalysis {
+	opponent := PLAYER_2 + PLAYER_1 - player
+	analysis := []ColumnAnalysis{}
+
+	for _, col := range board.getValidMoves() {
+		entry := ColumnAnalysis{
+			Col:        col,
+			WouldWin:   wouldWin(board, col, player, winLength, allowOverline),
+			WouldBlock: wouldWin(board, col, opponent, winLength, allowOverline),
+		}
+
+		if entry.WouldWin {
+			entry.Score = MINIMAX_WIN_SCORE
+		} else {
+			// Après le coup, c'est à l'adversaire : minimax maximise toujours pour PLAYER_2
+			child, _ := board.Place(col, player)
+			score, _ := minimax(child, winLength, allowOverline, ANALYSIS_DEPTH-1, math.MinInt, math.MaxInt, opponent == PLAYER_2)
+			if player == PLAYER_1 {
+				score = -score
+			}
+			entry.Score = score
+		}
+
+		analysis = append(analysis, entry)
+	}
+
+	return analysis
+}
+
+// Prévisualise chaque colonne jouable pour le joueur donné, sans modifier le plateau
+func previewMoves(board Board, winLength int, allowOverline bool, player int) []MovePreview {
+	opponent := PLAYER_2 + PLAYER_1 - player
+	previews := []MovePreview{}
+
+	for _, col := range board.getValidMoves() {
+		row := board.dropRow(col)
+		preview := MovePreview{
+			Col:      col,
+			LandsRow: row,
+			Wins:     wouldWin(board, col, player, winLength, allowOverline),
+		}
+
+		next, _ := board.Place(col, player)
+		preview.FillsBoard = next.isBoardFull()
+		if !preview.Wins {
+			preview.OpponentCanWinAfter = findWinningMove(next, opponent, winLength, allowOverline) != -1
+		}
+
+		previews = append(previews, preview)
+	}
+
+	return previews
+}
+
+// Recense les colonnes gagnantes de chaque joueur, quel que soit le joueur dont c'est le tour
+func findThreats(board Board, winLength int, allowOverline bool) Threats {
+	threats := Threats{Player1: []int{}, Player2: []int{}}
+	for _, col := range board.getValidMoves() {
+		if wouldWin(board, col, PLAYER_1, winLength, allowOverline) {
+			threats.Player1 = append(threats.Player1, col)
+		}
+		if wouldWin(board, col, PLAYER_2, winLength, allowOverline) {
+			threats.Player2 = append(threats.Player2, col)
+		}
+	}
+	return threats
+}
+
+// Explique pourquoi la colonne conseillée est intéressante pour le joueur
+func hintReason(board Board, col, player, winLength int, allowOverline bool) string {
+	switch {
+	case wouldWin(board, col, player, winLength, allowOverline):
+		return "wins"
+	case wouldWin(board, col, PLAYER_2+PLAYER_1-player, winLength, allowOverline):
+		return "blocks opponent"
+	case col == board.cols()/2:
+		return "center"
+	default:
+		return "neutral"
+	}
+}
+
+// Évalue une position non terminale pour le joueur donné (feuilles du minimax)
+// Favorise le contrôle de la colonne centrale et les alignements ouverts, pénalise les menaces adverses
+func evaluateBoard(board Board, winLength, player int) int {
+	opponent := PLAYER_2 + PLAYER_1 - player
+	score := 0
+
+	// Contrôle de la colonne centrale
+	center := board.cols() / 2
+	for row := 0; row < board.rows(); row++ {
+		if board[row][center] == player {
+			score += EVAL_CENTER_WEIGHT
+		}
+	}
+
+	// Évaluation de toutes les fenêtres de winLength cases
+	board.eachWindow(winLength, func(counts [3]int) bool {
+		score += scoreWindow(counts[player], counts[opponent], winLength)
+		return true
+	})
+
+	return score
+}
+
+// Attribue un score à une fenêtre selon le nombre de jetons de chaque joueur
+func scoreWindow(mine, theirs, winLength int) int {
+	empty := winLength - mine - theirs
+	switch {
+	case mine == winLength-1 && empty == 1:
+		return EVAL_OPEN_THREE_WEIGHT
+	case mine == winLength-2 && empty == 2:
+		return EVAL_OPEN_TWO_WEIGHT
+	case theirs == winLength-1 && empty == 1:
+		return -EVAL_THREAT_PENALTY
+	default:
+		return 0
+	}
+}
+
+// ============================================================================
+// API HANDLERS - JSON ENDPOINTS
+// ============================================================================
+
+// Retourne l'état actuel du jeu en JSON
+func (s *Server) getGameStateAPI(w http.ResponseWriter, r *http.Request) {
+	game := s.games.get(getSessionID(w, r))
+	columnBase := requestColumnBase(w, r)
+
+	game.mu.RLock()
+	defer game.mu.RUnlock()
+
+	// Un client qui interroge la partie à intervalle régulier ne la retélécharge que si elle a changé
+	// Les deux numérotations des colonnes sont deux représentations distinctes de la même version
+	etag := game.etag()
+	if columnBase != 0 {
+		etag = strings.TrimSuffix(etag, `"`) + "-c" + strconv.Itoa(columnBase) + `"`
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	data, err := game.marshalColumns(columnBase)
+	if err != nil {
+		log.Printf("❌ Erreur d'encodage JSON: %v", err)
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
+		return
+	}
+	w.Write(append(data, '\n'))
+}
+
+// ETag de l'état de la partie : sa version, préfixée du début de la partie pour qu'une nouvelle partie,
+// dont la version repart de zéro après l'expiration de la session, ne reprenne pas l'ETag de la précédente
+// Faible (W/) car la réponse peut être compressée ; les champs calculés (Duration, TimeRemaining)
+// n'en font pas partie : ils sont ceux de la dernière réponse complète
+// L'appelant doit détenir g.mu en lecture
+func (g *GameState) etag() string {
+	return fmt.Sprintf(`W/"%x-%d"`, g.StartedAt.UnixNano(), g.Version)
+}
+
+// Indique si l'en-tête If-None-Match (liste d'ETags séparés par des virgules, ou "*") désigne etag
+// La comparaison est faible : le préfixe W/ est ignoré des deux côtés
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Crée une nouvelle partie via l'API
+func (s *Server) newGameAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	var req struct {
+		Mode        string    `json:"mode"`
+		Difficulty  string    `json:"difficulty"`
+		Personality string    `json:"personality"` // Objectif de l'IA (PERSONALITY_*), standard si absent
+		Rows        int       `json:"rows"`
+		Cols        int       `json:"cols"`
+		Win         int       `json:"win"`
+		Human       int       `json:"humanPlayer"`
+		First       int       `json:"firstPlayer"` // Joueur qui commence (1 ou 2), le joueur 1 si absent
+		Variant     string    `json:"variant"`
+		Overline    *bool     `json:"allowOverline"`  // Une ligne plus longue que win gagne aussi, par défaut
+		DoubleWin   string    `json:"doubleWin"`      // Issue d'un retrait qui aligne les deux joueurs (DOUBLE_WIN_*), mover si absente
+		GravityOff  bool      `json:"gravityOff"`     // Pose sur une case précise permise (/api/place), à deux joueurs seulement
+		MaxMoves    int       `json:"maxMoves"`       // Nul au-delà de ce nombre de coups, 0 sans limite
+		Repetition  bool      `json:"repetitionDraw"` // Nul à la troisième occurrence d'une même position (Pop Out)
+		Book        *bool     `json:"openingBook"`    // Répertoire d'ouvertures de l'IA, activé par défaut
+		AllowUndo   *bool     `json:"allowUndo"`      // Annulations permises, par défaut
+		MaxUndos    int       `json:"maxUndos"`       // Nombre d'annulations permises, 0 sans limite
+		Seed        *int64    `json:"seed"`
+		Players     [2]string `json:"players"`       // Noms des joueurs 1 et 2, pour le classement Elo
+		Color1      string    `json:"player1Color"`  // Couleur des jetons du joueur 1 (COLOR_*), rouge si absente
+		Color2      string    `json:"player2Color"`  // Couleur des jetons du joueur 2 (COLOR_*), jaune si absente
+		Rated       bool      `json:"rated"`         // Contre l'IA, la partie compte pour le classement
+		MoveTime    *float64  `json:"moveTimeLimit"` // Secondes par coup humain, 0 sans pendule
+	}
+	if err := decodeJSONBody(w, r, &req); err != nil && err != io.EOF {
+		writeBodyError(w, err, ERROR_INVALID_REQUEST, "Requête illisible")
+		return
+	}
+
+	// Dimensions du Puissance 4 classique si non précisées
+	if req.Rows == 0 {
+		req.Rows = BOARD_ROWS
+	}
+	if req.Cols == 0 {
+		req.Cols = BOARD_COLS
+	}
+	if req.Win == 0 {
+		req.Win = WINNING_COUNT
+	}
+	if err := validateDimensions(req.Rows, req.Cols, req.Win); err != nil {
+		writeGameResponse(w, http.StatusBadRequest, GameResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	mode, err := parseMode(req.Mode)
+	if err != nil {
+		writeGameResponse(w, http.StatusBadRequest, GameResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	if req.MaxMoves < 0 {
+		writeGameResponse(w, http.StatusBadRequest, GameResponse{
+			Success: false,
+			Message: "nombre maximal de coups invalide",
+		})
+		return
+	}
+	if req.MaxUndos < 0 {
+		writeGameResponse(w, http.StatusBadRequest, GameResponse{
+			Success: false,
+			Message: "nombre d'annulations invalide",
+		})
+		return
+	}
+	if req.First != 0 && req.First != PLAYER_1 && req.First != PLAYER_2 {
+		writeGameResponse(w, http.StatusBadRequest, GameResponse{
+			Success: false,
+			Message: "premier joueur invalide (1 ou 2)",
+		})
+		return
+	}
+	if req.Personality == "" {
+		req.Personality = PERSONALITY_STANDARD
+	}
+	if !isValidPersonality(req.Personality) {
+		writeGameResponse(w, http.StatusBadRequest, GameResponse{
+			Success: false,
+			Message: fmt.Sprintf("personnalité de l'IA inconnue : %q (%s, %s ou %s)", req.Personality, PERSONALITY_STANDARD, PERSONALITY_STALL, PERSONALITY_MIRROR),
+		})
+		return
+	}
+	if req.DoubleWin == "" {
+		req.DoubleWin = DOUBLE_WIN_MOVER
+	}
+	if !isValidDoubleWin(req.DoubleWin) {
+		writeGameResponse(w, http.StatusBadRequest, GameResponse{
+			Success: false,
+			Message: fmt.Sprintf("règle de double alignement inconnue : %q (%s ou %s)", req.DoubleWin, DOUBLE_WIN_MOVER, DOUBLE_WIN_DRAW),
+		})
+		return
+	}
+	if req.Color1 == "" {
+		req.Color1 = COLOR_RED
+	}
+	if req.Color2 == "" {
+		req.Color2 = COLOR_YELLOW
+	}
+	if err := validateColors(req.Color1, req.Color2); err != nil {
+		writeGameResponse(w, http.StatusBadRequest, GameResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	sessionID := getSessionID(w, r)
+	game := newGameState(mode, req.Difficulty, req.Variant, requestLang(r), req.Rows, req.Cols, req.Win, req.Human)
+	if err := checkGravityOff(req.GravityOff, game.Mode, game.Variant); err != nil {
+		writeGameResponse(w, http.StatusBadRequest, GameResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	game.setFirstPlayer(req.First)
+	game.Personality = req.Personality
+	if req.Overline != nil {
+		game.AllowOverline = *req.Overline
+	}
+	game.DoubleWin = req.DoubleWin
+	game.GravityOff = req.GravityOff
+	game.Player1Color, game.Player2Color = req.Color1, req.Color2
+	game.MaxMoves = req.MaxMoves
+	game.RepetitionDraw = req.Repetition
+	game.MaxUndos = req.MaxUndos
+	if req.AllowUndo != nil {
+		game.AllowUndo = *req.AllowUndo
+	}
+	if req.Book != nil {
+		game.UseOpeningBook = *req.Book
+	}
+	if req.Seed != nil {
+		game.Seed = *req.Seed
+	}
+	game.MoveTimeLimit = config.MoveTimeLimit
+	if req.MoveTime != nil {
+		if *req.MoveTime < 0 {
+			writeGameResponse(w, http.StatusBadRequest, GameResponse{
+				Success: false,
+				Message: "temps par coup invalide",
+			})
+			return
+		}
+		game.MoveTimeLimit = time.Duration(*req.MoveTime * float64(time.Second))
+	}
+	if err := game.setPlayers(req.Players, req.Rated); err != nil {
+		writeGameResponse(w, http.StatusBadRequest, GameResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	game.playOpening()
+	game = s.games.reset(sessionID, game)
+	s.onGameUpdated(sessionID, game)
+
+	writeGameResponse(w, http.StatusOK, GameResponse{
+		Success:   true,
+		GameState: game,
+	})
+}
+
+// Gère un mouvement via l'API
+func (s *Server) handleMoveAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	sessionID := getSessionID(w, r)
+	game := s.games.get(sessionID)
+
+	var req struct {
+		Col int `json:"col"`
+	}
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		writeBodyError(w, err, ERROR_INVALID_REQUEST, "Requête illisible")
+		return
+	}
+	req.Col -= requestColumnBase(w, r)
+
+	game.mu.Lock()
+	// Les bornes de req.Col sont vérifiées par playMove (0..Cols-1, selon la taille de la partie) :
+	// hors du plateau, la réponse est 400 COLUMN_OUT_OF_RANGE
+	err := game.playMove(req.Col)
+	logAttrs(r, slog.Int("col", req.Col), slog.String("outcome", game.moveOutcome(err)))
+	if err != nil {
+		game.mu.Unlock()
+		if err.Code == ERROR_TIMEOUT {
+			s.onGameUpdated(sessionID, game)
+		}
+		writeAPIError(w, err.Status, err.Code, err.Message, game)
+		return
+	}
+
+	response := GameResponse{
+		Success:    true,
+		GameState:  game,
+		AlmostFull: game.Board.almostFullColumns(),
+	}
+	if game.GameOver {
+		response.Message = game.StatusMessage
+		response.Winner = game.Winner
+	}
+	game.mu.Unlock()
+
+	s.onGameUpdated(sessionID, game)
+	writeGameResponse(w, http.StatusOK, response)
+}
+
+// Fait jouer deux IA l'une contre l'autre et retourne la partie complète
+// La partie de la session n'est pas modifiée
+func (s *Server) simulateAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	var req struct {
+		Difficulty1  string `json:"difficulty1"`
+		Difficulty2  string `json:"difficulty2"`
+		Personality1 string `json:"personality1"`
+		Personality2 string `json:"personality2"`
+		Variant      string `json:"variant"`
+		Rows         int    `json:"rows"`
+		Cols         int    `json:"cols"`
+		Win          int    `json:"win"`
+	}
+	if err := decodeJSONBody(w, r, &req); err != nil && err != io.EOF {
+		writeBodyError(w, err, ERROR_INVALID_REQUEST, "Requête illisible")
+		return
+	}
+
+	// Dimensions du Puissance 4 classique si non précisées
+	if req.Rows == 0 {
+		req.Rows = BOARD_ROWS
+	}
+	if req.Cols == 0 {
+		req.Cols = BOARD_COLS
+	}
+	if req.Win == 0 {
+		req.Win = WINNING_COUNT
+	}
+	if err := validateDimensions(req.Rows, req.Cols, req.Win); err != nil {
+		writeGameResponse(w, http.StatusBadRequest, GameResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	difficulties := [2]string{req.Difficulty1, req.Difficulty2}
+	for i, difficulty := range difficulties {
+		if !isValidDifficulty(difficulty) {
+			difficulties[i] = DIFFICULTY_EASY
+		}
+	}
+	personalities := [2]string{req.Personality1, req.Personality2}
+	for i, personality := range personalities {
+		if !isValidPersonality(personality) {
+			personalities[i] = PERSONALITY_STANDARD
+		}
+	}
+
+	game, err := simulateGame(r.Context(), difficulties, personalities, req.Variant, req.Rows, req.Cols, req.Win)
+	if err != nil {
+		logAttrs(r, slog.String("error", err.Error()))
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Simulation{
+		Difficulty1:  difficulties[0],
+		Difficulty2:  difficulties[1],
+		Personality1: personalities[0],
+		Personality2: personalities[1],
+		Moves:        encodeMoves(game.Moves),
+		Winner:       game.Winner,
+		GameState:    game,
+	})
+}
+
+// Fait jouer une série de parties entre deux IA et retourne les statistiques agrégées
+func (s *Server) simulateBatchAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	var req struct {
+		N           int    `json:"n"`
+		DifficultyA string `json:"difficultyA"`
+		DifficultyB string `json:"difficultyB"`
+		Variant     string `json:"variant"`
+		Rows        int    `json:"rows"`
+		Cols        int    `json:"cols"`
+		Win         int    `json:"win"`
+	}
+	if err := decodeJSONBody(w, r, &req); err != nil && err != io.EOF {
+		writeBodyError(w, err, ERROR_INVALID_REQUEST, "Requête illisible")
+		return
+	}
+
+	if req.N < 1 || req.N > MAX_SIMULATION_BATCH {
+		writeGameResponse(w, http.StatusBadRequest, GameResponse{
+			Success: false,
+			Message: fmt.Sprintf("n doit être compris entre 1 et %d", MAX_SIMULATION_BATCH),
+		})
+		return
+	}
+
+	// Dimensions du Puissance 4 classique si non précisées
+	if req.Rows == 0 {
+		req.Rows = BOARD_ROWS
+	}
+	if req.Cols == 0 {
+		req.Cols = BOARD_COLS
+	}
+	if req.Win == 0 {
+		req.Win = WINNING_COUNT
+	}
+	if err := validateDimensions(req.Rows, req.Cols, req.Win); err != nil {
+		writeGameResponse(w, http.StatusBadRequest, GameResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if !isValidDifficulty(req.DifficultyA) {
+		req.DifficultyA = DIFFICULTY_EASY
+	}
+	if !isValidDifficulty(req.DifficultyB) {
+		req.DifficultyB = DIFFICULTY_EASY
+	}
+
+	result, err := simulateBatch(r.Context(), req.N, req.DifficultyA, req.DifficultyB, req.Variant, req.Rows, req.Cols, req.Win)
+	if err != nil {
+		logAttrs(r, slog.String("error", err.Error()))
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// Retire le jeton du joueur au bas d'une colonne via l'API (variante Pop Out)
+func (s *Server) popAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	sessionID := getSessionID(w, r)
+	game := s.games.get(sessionID)
+
+	var req struct {
+		Col int `json:"col"`
+	}
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		writeBodyError(w, err, ERROR_INVALID_REQUEST, "Requête illisible")
+		return
+	}
+	req.Col -= requestColumnBase(w, r)
+
+	game.mu.Lock()
+	if game.isAITurn() {
+		game.mu.Unlock()
+		writeAPIError(w, http.StatusConflict, ERROR_NOT_YOUR_TURN, "C'est au tour de l'ordinateur", game)
+		return
+	}
+	err := game.popPiece(req.Col, game.CurrentPlayer)
+	logAttrs(r, slog.Int("col", req.Col), slog.Bool("pop", true), slog.String("outcome", game.moveOutcome(err)))
+	if err != nil {
+		game.mu.Unlock()
+		if err.Code == ERROR_TIMEOUT {
+			s.onGameUpdated(sessionID, game)
+		}
+		writeAPIError(w, err.Status, err.Code, err.Message, game)
+		return
+	}
+
+	response := GameResponse{
+		Success:    true,
+		GameState:  game,
+		AlmostFull: game.Board.almostFullColumns(),
+	}
+	if game.GameOver {
+		response.Message = game.StatusMessage
+		response.Winner = game.Winner
+	}
+	game.mu.Unlock()
+
+	s.onGameUpdated(sessionID, game)
+	writeGameResponse(w, http.StatusOK, response)
+}
+
+// Pose un jeton sur une case précise, dans une partie sans gravité
+func (s *Server) placeAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	sessionID := getSessionID(w, r)
+	game := s.games.get(sessionID)
+
+	var req struct {
+		Row int `json:"row"` // Numérotée à partir de 0 depuis le haut, comme GameState.Board
+		Col int `json:"col"` // Numérotée comme le demande requestColumnBase
+	}
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		writeBodyError(w, err, ERROR_INVALID_REQUEST, "Requête illisible")
+		return
+	}
+	req.Col -= requestColumnBase(w, r)
+
+	game.mu.Lock()
+	err := game.placeCell(req.Row, req.Col)
+	logAttrs(r, slog.Int("row", req.Row), slog.Int("col", req.Col), slog.String("outcome", game.moveOutcome(err)))
+	if err != nil {
+		game.mu.Unlock()
+		if err.Code == ERROR_TIMEOUT {
+			s.onGameUpdated(sessionID, game)
+		}
+		writeAPIError(w, err.Status, err.Code, err.Message, game)
+		return
+	}
+
+	response := GameResponse{
+		Success:    true,
+		GameState:  game,
+		AlmostFull: game.Board.almostFullColumns(),
+	}
+	if game.GameOver {
+		response.Message = game.StatusMessage
+		response.Winner = game.Winner
+	}
+	game.mu.Unlock()
+
+	s.onGameUpdated(sessionID, game)
+	writeGameResponse(w, http.StatusOK, response)
+}
+
+// Fait jouer l'IA via l'API
+func (s *Server) aiMoveAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	sessionID := getSessionID(w, r)
+	game := s.games.get(sessionID)
+	// Sans colonne à lire : la numérotation ne vaut que pour la réponse (voir writeGameResponse)
+	requestColumnBase(w, r)
+
+	game.mu.Lock()
+	if game.GameOver {
+		game.mu.Unlock()
+		writeAPIError(w, http.StatusConflict, ERROR_GAME_OVER, "La partie est terminée", game)
+		return
+	}
+
+	// La réponse de l'IA est déjà en préparation par le formulaire
+	if err := game.checkAIThinking(); err != nil {
+		game.mu.Unlock()
+		writeAPIError(w, err.Status, err.Code, err.Message, game)
+		return
+	}
+
+	// En mode IA, l'ordinateur ne joue pas à la place de l'humain
+	if game.Mode == GAME_MODE_AI && !game.isAITurn() {
+		game.mu.Unlock()
+		writeAPIError(w, http.StatusConflict, ERROR_NOT_YOUR_TURN, "C'est à vous de jouer", game)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), AI_REQUEST_TIMEOUT)
+	defer cancel()
+	if !game.aiMakeMove(ctx) {
+		game.mu.Unlock()
+		http.Error(w, "L'IA ne peut pas jouer", http.StatusInternalServerError)
+		return
+	}
+	if last := game.LastMove; last != nil {
+		logAttrs(r, slog.Int("col", last[1]))
+	}
+	logAttrs(r, slog.String("outcome", game.moveOutcome(nil)))
+
+	response := GameResponse{
+		Success:    true,
+		Message:    game.StatusMessage,
+		GameState:  game,
+		Winner:     game.Winner,
+		AlmostFull: game.Board.almostFullColumns(),
+	}
+	if game.LastAIDecision != nil {
+		decision := *game.LastAIDecision
+		logAttrs(r, slog.String("reason", decision.Reason))
+		if !debugRequested(r) {
+			decision.Search = nil
+		}
+		response.AIMove = &decision
+	}
+	game.mu.Unlock()
+
+	s.onGameUpdated(sessionID, game)
+	writeGameResponse(w, http.StatusOK, response)
+}
+
+// Explique le dernier coup de l'IA tel qu'elle l'a décidé, sans refaire la recherche
+func (s *Server) aiExplanationAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	game := s.games.get(getSessionID(w, r))
+
+	game.mu.RLock()
+	if game.LastAIDecision == nil {
+		game.mu.RUnlock()
+		http.Error(w, "Aucun coup de l'IA à expliquer", http.StatusNotFound)
+		return
+	}
+	explanation := AIExplanation{
+		AIDecision:  game.LastAIDecision.shifted(requestColumnBase(w, r)),
+		Explanation: explainDecision(game.Lang, *game.LastAIDecision),
+	}
+	game.mu.RUnlock()
+
+	if !debugRequested(r) {
+		explanation.Search = nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(explanation)
+}
+
+// Annule le dernier coup via l'API
+func (s *Server) undoAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	sessionID := getSessionID(w, r)
+	game := s.games.get(sessionID)
+
+	game.mu.Lock()
+	if err := game.checkUndoAllowed(); err != nil {
+		game.mu.Unlock()
+		writeAPIError(w, err.Status, err.Code, err.Message, game)
+		return
+	}
+	if !game.undoLastMove() {
+		game.mu.Unlock()
+		writeGameResponse(w, http.StatusOK, GameResponse{
+			Success:   false,
+			Message:   "Aucun coup à annuler",
+			GameState: game,
+		})
+		return
+	}
+	message := game.StatusMessage
+	game.mu.Unlock()
+
+	s.onGameUpdated(sessionID, game)
+	writeGameResponse(w, http.StatusOK, GameResponse{
+		Success:   true,
+		Message:   message,
+		GameState: game,
+	})
+}
+
+// Abandonne la partie en cours : contre l'IA c'est l'humain qui abandonne,
+// à deux joueurs sur le même écran c'est le joueur dont c'est le tour
+func (s *Server) resignAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	sessionID := getSessionID(w, r)
+	game := s.games.get(sessionID)
+
+	game.mu.Lock()
+	player := game.CurrentPlayer
+	if game.Mode == GAME_MODE_AI {
+		player = game.HumanPlayer
+	}
+	if err := game.resign(player); err != nil {
+		game.mu.Unlock()
+		writeAPIError(w, err.Status, err.Code, err.Message, game)
+		return
+	}
+	response := GameResponse{
+		Success:   true,
+		Message:   game.StatusMessage,
+		GameState: game,
+		Winner:    game.Winner,
+	}
+	game.mu.Unlock()
+
+	s.onGameUpdated(sessionID, game)
+	writeGameResponse(w, http.StatusOK, response)
+}
+
+// Propose le nul dans la partie à deux joueurs de la session, au nom du joueur dont c'est le tour
+func (s *Server) drawOfferAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	sessionID := getSessionID(w, r)
+	game := s.games.get(sessionID)
+
+	game.mu.Lock()
+	if err := game.offerDraw(game.CurrentPlayer); err != nil {
+		game.mu.Unlock()
+		writeAPIError(w, err.Status, err.Code, err.Message, game)
+		return
+	}
+	response := GameResponse{
+		Success:   true,
+		Message:   "Nul proposé à votre adversaire",
+		GameState: game,
+	}
+	game.mu.Unlock()
+
+	s.onGameUpdated(sessionID, game)
+	writeGameResponse(w, http.StatusOK, response)
+}
+
+// Répond à la proposition de nul en attente avec {"accept": true|false}, au nom de l'adversaire de son auteur
+// Accepter termine la partie sur un nul, compté comme tel
+func (s *Server) drawRespondAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	sessionID := getSessionID(w, r)
+	game := s.games.get(sessionID)
+
+	var req struct {
+		Accept *bool `json:"accept"`
+	}
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		writeBodyError(w, err, ERROR_INVALID_REQUEST, "Requête illisible")
+		return
+	}
+	if req.Accept == nil {
+		writeAPIError(w, http.StatusBadRequest, ERROR_INVALID_REQUEST, "Champ accept manquant", nil)
+		return
+	}
+
+	game.mu.Lock()
+	// Sur un même écran, la réponse vient forcément de l'adversaire de celui qui a proposé
+	if err := game.answerDraw(PLAYER_2+PLAYER_1-game.DrawOffer, *req.Accept); err != nil {
+		game.mu.Unlock()
+		writeAPIError(w, err.Status, err.Code, err.Message, game)
+		return
+	}
+	logAttrs(r, slog.Bool("accept", *req.Accept))
+	response := GameResponse{
+		Success:    true,
+		Message:    "Proposition de nul refusée",
+		GameState:  game,
+		DrawAnswer: DRAW_DECLINED,
+	}
+	if *req.Accept {
+		response.Message, response.Winner, response.DrawAnswer = game.StatusMessage, game.Winner, DRAW_ACCEPTED
+	}
+	game.mu.Unlock()
+
+	s.onGameUpdated(sessionID, game)
+	writeGameResponse(w, http.StatusOK, response)
+}
+
+// Applique la règle du gâteau à la partie de la session (voir swapSides)
+func (s *Server) swapAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	sessionID := getSessionID(w, r)
+	game := s.games.get(sessionID)
+
+	game.mu.Lock()
+	if err := game.swapSides(); err != nil {
+		game.mu.Unlock()
+		if err.Code == ERROR_TIMEOUT {
+			s.onGameUpdated(sessionID, game)
+		}
+		writeAPIError(w, err.Status, err.Code, err.Message, game)
+		return
+	}
+	response := GameResponse{
+		Success:   true,
+		Message:   game.StatusMessage,
+		GameState: game,
+		Winner:    game.Winner,
+	}
+	game.mu.Unlock()
+
+	s.onGameUpdated(sessionID, game)
+	writeGameResponse(w, http.StatusOK, response)
+}
+
+// Analyse chaque colonne jouable sans jouer de coup
+// Corps optionnel : {"player": 1|2}, par défaut le joueur dont c'est le tour
+func (s *Server) analyzeAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	game := s.games.get(getSessionID(w, r))
+
+	var req struct {
+		Player int `json:"player"`
+	}
+	if err := decodeJSONBody(w, r, &req); err != nil && err != io.EOF {
+		writeBodyError(w, err, ERROR_INVALID_REQUEST, "Requête illisible")
+		return
+	}
+
+	// Copie du plateau sous verrou : l'analyse se fait ensuite sans bloquer la partie
+	game.mu.RLock()
+	board := game.Board.Clone()
+	winLength := game.WinLength
+	allowOverline := game.AllowOverline
+	gameOver := game.GameOver
+	if req.Player == 0 {
+		req.Player = game.CurrentPlayer
+	}
+	game.mu.RUnlock()
+
+	if req.Player != PLAYER_1 && req.Player != PLAYER_2 {
+		http.Error(w, "Joueur invalide", http.StatusBadRequest)
+		return
+	}
+
+	analysis := []ColumnAnalysis{}
+	if !gameOver {
+		analysis = analyzeMoves(board, winLength, allowOverline, req.Player)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(analysis)
+}
+
+// Conseille un coup au joueur dont c'est le tour, sans le jouer
+func (s *Server) hintAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	game := s.games.get(getSessionID(w, r))
+
+	// Copie du plateau sous verrou : la recherche se fait ensuite sans bloquer la partie
+	game.mu.RLock()
+	board := game.Board.Clone()
+	winLength := game.WinLength
+	allowOverline := game.AllowOverline
+	player := game.CurrentPlayer
+	gameOver := game.GameOver
+	rng := game.moveRand()
+	game.mu.RUnlock()
+
+	if gameOver {
+		writeAPIError(w, http.StatusConflict, ERROR_GAME_OVER, "La partie est terminée", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), AI_REQUEST_TIMEOUT)
+	defer cancel()
+	decision := decideMove(ctx, board, winLength, allowOverline, HINT_DIFFICULTY, player, rng)
+	hint := Hint{Col: decision.Col + requestColumnBase(w, r), Reason: hintReason(board, decision.Col, player, winLength, allowOverline)}
+	if debugRequested(r) {
+		hint.Search = decision.Search
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hint)
+}
+
+// Indique si la requête demande les statistiques de recherche de l'IA (?debug=1)
+func debugRequested(r *http.Request) bool {
+	debug, _ := strconv.ParseBool(r.URL.Query().Get("debug"))
+	return debug
+}
+
+// Numérotation des colonnes demandée par le client : 1 si l'en-tête COLUMN_BASE_HEADER (prioritaire) ou
+// le paramètre COLUMN_BASE_PARAM vaut "1", 0 sinon ; elle est rappelée dans l'en-tête de la réponse,
+// où writeGameResponse la retrouve (voir responseColumnBase)
+func requestColumnBase(w http.ResponseWriter, r *http.Request) int {
+	value := r.Header.Get(COLUMN_BASE_HEADER)
+	if value == "" {
+		value = r.URL.Query().Get(COLUMN_BASE_PARAM)
+	}
+	base := 0
+	if value == "1" {
+		base = 1
+	}
+	w.Header().Set(COLUMN_BASE_HEADER, strconv.Itoa(base))
+	return base
+}
+
+// Numérotation des colonnes déjà choisie pour la réponse par requestColumnBase, 0 si le handler ne la gère pas
+func responseColumnBase(w http.ResponseWriter) int {
+	if w.Header().Get(COLUMN_BASE_HEADER) == "1" {
+		return 1
+	}
+	return 0
+}
+
+// Décale des numéros de colonne de base ; retourne une copie, la partie n'est jamais modifiée
+func shiftColumns(cols []int, base int) []int {
+	if base == 0 || cols == nil {
+		return cols
+	}
+	shifted := make([]int, len(cols))
+	for i, col := range cols {
+		shifted[i] = col + base
+	}
+	return shifted
+}
+
+// Copie de la décision avec ses colonnes décalées de base
+func (d AIDecision) shifted(base int) AIDecision {
+	d.Col += base
+	if d.Alternative != nil {
+		alternative := *d.Alternative
+		alternative.Col += base
+		d.Alternative = &alternative
+	}
+	return d
+}
+
+// Résout la position courante pour le joueur au trait, jusqu'à une profondeur bornée
+// Corps optionnel : {"depth": 12} en demi-coups, SOLVE_DEFAULT_DEPTH par défaut, plafonné à SOLVE_MAX_DEPTH
+func (s *Server) solveAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	game := s.games.get(getSessionID(w, r))
+
+	var req struct {
+		Depth int `json:"depth"`
+	}
+	if err := decodeJSONBody(w, r, &req); err != nil && err != io.EOF {
+		writeBodyError(w, err, ERROR_INVALID_REQUEST, "Requête illisible")
+		return
+	}
+
+	if req.Depth < 0 {
+		http.Error(w, "Profondeur invalide", http.StatusBadRequest)
+		return
+	}
+	if req.Depth == 0 {
+		req.Depth = SOLVE_DEFAULT_DEPTH
+	}
+	req.Depth = min(req.Depth, SOLVE_MAX_DEPTH)
+
+	// Copie du plateau sous verrou : la résolution se fait ensuite sans bloquer la partie
+	game.mu.RLock()
+	board := game.Board.Clone()
+	winLength := game.WinLength
+	allowOverline := game.AllowOverline
+	player := game.CurrentPlayer
+	gameOver := game.GameOver
+	game.mu.RUnlock()
+
+	if gameOver {
+		writeAPIError(w, http.StatusConflict, ERROR_GAME_OVER, "La partie est terminée", nil)
+		return
+	}
+
+	// La recherche s'arrête aussi si le client n'attend plus la réponse
+	ctx, cancel := context.WithTimeout(r.Context(), SOLVE_TIME_BUDGET)
+	defer cancel()
+	result := solvePosition(ctx, board, winLength, allowOverline, player, req.Depth)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// Prévisualise les conséquences de chaque coup jouable pour le joueur dont c'est le tour
+func (s *Server) previewMovesAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	game := s.games.get(getSessionID(w, r))
+
+	// Copie du plateau sous verrou : la simulation se fait ensuite sans bloquer la partie
+	game.mu.RLock()
+	board := game.Board.Clone()
+	winLength := game.WinLength
+	allowOverline := game.AllowOverline
+	player := game.CurrentPlayer
+	gameOver := game.GameOver
+	game.mu.RUnlock()
+
+	previews := []MovePreview{}
+	if !gameOver {
+		previews = previewMoves(board, winLength, allowOverline, player)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(previews)
+}
+
+// Indique si la colonne ?col=N peut être jouée, sans rien placer
+func (s *Server) moveLegalAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	col, err := strconv.Atoi(r.URL.Query().Get("col"))
+	if err != nil {
+		http.Error(w, "Paramètre col invalide", http.StatusBadRequest)
+		return
+	}
+	col -= requestColumnBase(w, r)
+
+	game := s.games.get(getSessionID(w, r))
+
+	game.mu.RLock()
+	var legality MoveLegality
+	switch {
+	case game.GameOver:
+		legality.Reason = "game over"
+	case col < 0 || col >= game.Cols:
+		legality.Reason = "out of range"
+	case !game.Board.isValidMove(col):
+		legality.Reason = "column full"
+	default:
+		legality.Legal = true
+	}
+	game.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(legality)
+}
+
+// Liste les menaces de victoire immédiate des deux joueurs
+func (s *Server) threatsAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	game := s.games.get(getSessionID(w, r))
+
+	game.mu.RLock()
+	board := game.Board.Clone()
+	winLength := game.WinLength
+	allowOverline := game.AllowOverline
+	gameOver := game.GameOver
+	game.mu.RUnlock()
+
+	threats := Threats{Player1: []int{}, Player2: []int{}}
+	if !gameOver {
+		threats = findThreats(board, winLength, allowOverline)
+	}
+	columnBase := requestColumnBase(w, r)
+	threats.Player1, threats.Player2 = shiftColumns(threats.Player1, columnBase), shiftColumns(threats.Player2, columnBase)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(threats)
+}
+
+// Tous les alignements complets du plateau, pas seulement celui du dernier coup
+func (s *Server) linesAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	game := s.games.get(getSessionID(w, r))
+
+	game.mu.RLock()
+	board := game.Board.Clone()
+	winLength := game.WinLength
+	allowOverline := game.AllowOverline
+	game.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(board.completedLines(winLength, allowOverline))
+}
+
+// Sert le contrat OpenAPI tel quel, pour générer des clients
+func (s *Server) openAPIAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+// Sonde de vie : le serveur répond
+func (s *Server) healthzAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	writeHealth(w, http.StatusOK, HealthStatus{Status: "ok"})
+}
+
+// Sonde de disponibilité : le template est chargé et la sauvegarde peut être écrite
+func (s *Server) readyzAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	if s.tmpl == nil {
+		writeHealth(w, http.StatusServiceUnavailable, HealthStatus{Status: "unavailable", Error: "template non chargé"})
+		return
+	}
+	if err := checkWritable(SAVE_FILE); err != nil {
+		writeHealth(w, http.StatusServiceUnavailable, HealthStatus{Status: "unavailable", Error: err.Error()})
+		return
+	}
+
+	writeHealth(w, http.StatusOK, HealthStatus{Status: "ok"})
+}
+
+// Mesures du serveur au format texte de Prometheus, depuis son démarrage
+func (s *Server) metricsAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	w.Header().Set("Content-Type", METRICS_CONTENT_TYPE)
+	metrics.writeTo(w)
+}
+
+// Vérifie qu'un fichier temporaire peut être créé à côté du fichier indiqué, comme le fait SaveGames
+func checkWritable(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".probe-*")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	return os.Remove(tmp.Name())
+}
+
+// Écrit la réponse d'une sonde avec son code HTTP
+func writeHealth(w http.ResponseWriter, status int, health HealthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(health)
+}
+
+// Retourne la partie en cours en texte brut, pour curl et les clients en terminal
+func (s *Server) asciiGameAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	game := s.games.get(getSessionID(w, r))
+
+	game.mu.RLock()
+	text := game.String()
+	game.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, text)
+}
+
+// Retourne le plateau de la partie en cours en image PNG, pour la partager
+func (s *Server) imageGameAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	game := s.games.get(getSessionID(w, r))
+
+	game.mu.RLock()
+	img := game.image()
+	game.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-cache")
+	png.Encode(w, img)
+}
+
+// Retourne les changements de la partie depuis la version ?since=N, pour les clients qui rejouent les coups localement
+// Les coups pas encore publiés par onGameUpdated attendent la version suivante
+func (s *Server) gameDeltaAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	since, err := strconv.Atoi(r.URL.Query().Get("since"))
+	if err != nil || since < 0 {
+		http.Error(w, "Paramètre since invalide", http.StatusBadRequest)
+		return
+	}
+
+	game := s.games.get(getSessionID(w, r))
+
+	game.mu.RLock()
+	defer game.mu.RUnlock()
+
+	delta := GameDelta{
+		Since:         since,
+		Version:       game.Version,
+		CurrentPlayer: game.CurrentPlayer,
+		GameOver:      game.GameOver,
+		Winner:        game.Winner,
+		WinningCells:  game.WinningCells,
+		WinDirection:  game.WinDirection,
+		StatusMessage: game.StatusMessage,
+	}
+	if uint64(since) < game.deltaFrom || uint64(since) > game.Version {
+		delta.Full = true
+		delta.GameState = game
+	} else {
+		for _, move := range game.Moves {
+			if move.Version > uint64(since) {
+				delta.Moves = append(delta.Moves, DeltaMove{Row: move.Row, Col: move.Col, Player: move.Player, Pop: move.Pop})
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delta)
+}
+
+// Diffuse l'état de la partie en Server-Sent Events : un événement "state" par version, d'identifiant Version
+// L'état courant est envoyé dès la connexion, sauf si Last-Event-ID montre que le client l'a déjà
+// Le flux se termine quand le client se déconnecte ou à l'arrêt du serveur
+func (s *Server) gameStreamAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Flux non supporté", http.StatusInternalServerError)
+		return
+	}
+
+	sessionID := getSessionID(w, r)
+
+	// Abonnement avant la lecture de l'état courant, pour ne manquer aucune version
+	ch := s.hub.subscribeStream(sessionID)
+	defer s.hub.unsubscribeStream(sessionID, ch)
+
+	game := s.games.get(sessionID)
+	game.mu.RLock()
+	current := streamEvent{version: game.Version}
+	data, err := json.Marshal(game)
+	game.mu.RUnlock()
+	if err != nil {
+		log.Printf("❌ Erreur d'encodage JSON: %v", err)
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
+		return
+	}
+	current.data = data
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprintf(w, "retry: %d\n\n", SSE_RETRY.Milliseconds())
+
+	sent := r.Header.Get("Last-Event-ID")
+	if sent != strconv.FormatUint(current.version, 10) {
+		if err := writeStreamEvent(w, current); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(SSE_KEEPALIVE_INTERVAL)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.hub.stop:
+			return
+		case event := <-ch:
+			// Version déjà envoyée avec l'état initial
+			if event.version <= current.version {
+				continue
+			}
+			current = event
+			if err := writeStreamEvent(w, event); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// Écrit un état de partie au format SSE ; le JSON encodé tient sur une seule ligne data:
+func writeStreamEvent(w io.Writer, event streamEvent) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: state\ndata: %s\n\n", event.version, event.data)
+	return err
+}
+
+// Retourne la feuille de match de la partie en cours, pour l'affichage
+func (s *Server) moveLogAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	game := s.games.get(getSessionID(w, r))
+
+	game.mu.RLock()
+	entries := append([]string{}, game.MoveLog...)
+	game.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MoveLogResponse{Log: entries, Transcript: moveTranscript(entries)})
+}
+
+// Retourne toutes les parties terminées de la session en NDJSON : un GameState par ligne, de la plus
+// ancienne à la plus récente, partie en cours comprise si elle est terminée
+// ?since= (RFC 3339 ou AAAA-MM-JJ) ne garde que les parties finies à partir de cette date, ?limit= borne leur nombre
+// Les lignes sont écrites au fil de la lecture de HISTORY_FILE, sans charger l'historique en mémoire
+func (s *Server) historyAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	query := r.URL.Query()
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			http.Error(w, "Paramètre limit invalide", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	var since time.Time
+	if value := query.Get("since"); value != "" {
+		date, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			date, err = time.Parse(time.DateOnly, value)
+		}
+		if err != nil {
+			http.Error(w, "Paramètre since invalide", http.StatusBadRequest)
+			return
+		}
+		since = date
+	}
+
+	sessionID := getSessionID(w, r)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	send := func(game json.RawMessage) error {
+		if _, err := w.Write(append(game, '\n')); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	sent, err := s.games.streamHistory(sessionID, since, limit, send)
+	if err != nil {
+		// Les premières lignes sont peut-être déjà parties : le statut ne peut plus changer
+		log.Printf("❌ Erreur de lecture de l'historique: %v", err)
+		return
+	}
+
+	// La partie en cours n'est archivée qu'à son remplacement : terminée, elle fait déjà partie de l'historique
+	if limit > 0 && sent >= limit {
+		return
+	}
+	game := s.games.get(sessionID)
+	game.mu.RLock()
+	var data []byte
+	if game.GameOver && !game.EndedAt.Before(since) {
+		data, err = json.Marshal(game)
+	}
+	game.mu.RUnlock()
+	if err != nil {
+		log.Printf("❌ Erreur d'encodage JSON: %v", err)
+		return
+	}
+	if data != nil {
+		send(data)
+	}
+}
+
+// Retourne le journal des événements de la partie en cours, du plus ancien au plus récent
+func (s *Server) gameEventsAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	game := s.games.get(getSessionID(w, r))
+
+	game.mu.RLock()
+	events := append([]Event{}, game.Events...)
+	game.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// Exporte la partie en cours en notation compacte
+func (s *Server) exportGameAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	game := s.games.get(getSessionID(w, r))
+
+	game.mu.RLock()
+	exp := game.export()
+	game.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(exp)
+}
+
+// Exporte la partie en cours en notation PGN (GET), ou la remplace par une partie en notation PGN (POST)
+func (s *Server) pgnGameAPI(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		game := s.games.get(getSessionID(w, r))
+
+		game.mu.RLock()
+		text := game.pgn()
+		game.mu.RUnlock()
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, text)
+
+	case http.MethodPost:
+		body, err := io.ReadAll(io.LimitReader(r.Body, MAX_PGN_SIZE+1))
+		if err != nil || len(body) > MAX_PGN_SIZE {
+			writeAPIError(w, http.StatusBadRequest, ERROR_INVALID_IMPORT, "Partie PGN illisible", nil)
+			return
+		}
+
+		imported, err := importPGN(string(body), requestLang(r))
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, ERROR_INVALID_IMPORT, err.Error(), nil)
+			return
+		}
+
+		sessionID := getSessionID(w, r)
+		game := s.games.reset(sessionID, imported)
+		s.onGameUpdated(sessionID, game)
+
+		writeGameResponse(w, http.StatusOK, GameResponse{
+			Success:   true,
+			GameState: game,
+			Winner:    game.Winner,
+		})
+
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// Remplace la partie en cours par une partie importée, après avoir rejoué et validé ses coups
+func (s *Server) importGameAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	var exp GameExport
+	if err := decodeJSONBody(w, r, &exp); err != nil {
+		writeBodyError(w, err, ERROR_INVALID_IMPORT, "Export illisible")
+		return
+	}
+	if exp.Lang == "" {
+		exp.Lang = requestLang(r)
+	}
+
+	imported, err := importGame(exp)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, ERROR_INVALID_IMPORT, err.Error(), nil)
+		return
+	}
+
+	sessionID := getSessionID(w, r)
+	game := s.games.reset(sessionID, imported)
+	s.onGameUpdated(sessionID, game)
+
+	writeGameResponse(w, http.StatusOK, GameResponse{
+		Success:   true,
+		GameState: game,
+		Winner:    game.Winner,
+	})
+}
+
+// Remplace la partie en cours par une position donnée (problèmes du type « les rouges jouent et gagnent »)
+// La nouvelle partie garde les options de la précédente ; son historique part de cette position
+func (s *Server) positionAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	var req struct {
+		Board         Board `json:"board"`         // Lignes du haut vers le bas, comme GameState.Board
+		CurrentPlayer int   `json:"currentPlayer"` // Joueur au trait, 0 pour le déduire des jetons
+	}
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		writeBodyError(w, err, ERROR_INVALID_POSITION, "Position illisible")
+		return
+	}
+	if len(req.Board) == 0 {
+		writeAPIError(w, http.StatusBadRequest, ERROR_INVALID_POSITION, "Position illisible : plateau vide", nil)
+		return
+	}
+
+	sessionID := getSessionID(w, r)
+	current := s.games.get(sessionID)
+
+	current.mu.RLock()
+	game := newGameState(current.Mode, current.Difficulty, current.Variant, current.Lang, req.Board.rows(), req.Board.cols(), current.WinLength, current.HumanPlayer)
+	game.setFirstPlayer(current.FirstPlayer)
+	game.Personality = current.Personality
+	game.AllowOverline = current.AllowOverline
+	game.DoubleWin = current.DoubleWin
+	game.GravityOff = current.GravityOff
+	game.Player1Color, game.Player2Color = current.Player1Color, current.Player2Color
+	game.MaxMoves = current.MaxMoves
+	game.RepetitionDraw = current.RepetitionDraw
+	game.AllowUndo = current.AllowUndo
+	game.MaxUndos = current.MaxUndos
+	game.MoveTimeLimit = current.MoveTimeLimit
+	current.mu.RUnlock()
+
+	err := validateDimensions(game.Rows, game.Cols, game.WinLength)
+	if err == nil {
+		err = game.setPosition(req.Board, req.CurrentPlayer)
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, ERROR_INVALID_POSITION, err.Error(), nil)
+		return
+	}
+	// Contre l'IA, elle joue aussitôt si la position lui donne le trait
+	game.playOpening()
+
+	game = s.games.reset(sessionID, game)
+	s.onGameUpdated(sessionID, game)
+
+	writeGameResponse(w, http.StatusOK, GameResponse{
+		Success:   true,
+		GameState: game,
+		Winner:    game.Winner,
+	})
+}
+
+// Repart de la partie en cours après ses N premiers coups, contre l'IA : {"move": N}
+// Par défaut l'IA prend le camp au trait, et joue donc aussitôt le coup N+1 à sa façon ; "humanPlayer"
+// choisit l'autre camp, "difficulty" le niveau (celui de la partie si absent)
+// La partie d'origine n'est pas modifiée : son export est gardé dans BranchedFrom de la nouvelle partie
+func (s *Server) branchAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	var req struct {
+		Move       *int   `json:"move"`        // Nombre de coups conservés, retraits et poses compris
+		Human      int    `json:"humanPlayer"` // Camp de l'humain (1 ou 2), l'adversaire du joueur au trait si absent
+		Difficulty string `json:"difficulty"`
+	}
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		writeBodyError(w, err, ERROR_INVALID_REQUEST, "Requête illisible")
+		return
+	}
+	if req.Human != 0 && req.Human != PLAYER_1 && req.Human != PLAYER_2 {
+		writeAPIError(w, http.StatusBadRequest, ERROR_INVALID_REQUEST, "Camp de l'humain invalide (1 ou 2)", nil)
+		return
+	}
+	if req.Difficulty != "" && !isValidDifficulty(req.Difficulty) {
+		writeAPIError(w, http.StatusBadRequest, ERROR_INVALID_REQUEST, fmt.Sprintf("Difficulté inconnue : %q", req.Difficulty), nil)
+		return
+	}
+
+	sessionID := getSessionID(w, r)
+	current := s.games.get(sessionID)
+
+	// L'export est une copie : la branche se construit sans toucher à la partie d'origine
+	current.mu.RLock()
+	played := len(current.Moves)
+	if req.Move == nil || *req.Move < 0 || *req.Move > played {
+		current.mu.RUnlock()
+		writeAPIError(w, http.StatusBadRequest, ERROR_INVALID_REQUEST, fmt.Sprintf("Numéro de coup invalide : de 0 à %d", played), nil)
+		return
+	}
+	original := current.export()
+	branch := original
+	branch.Moves = encodeMoves(current.Moves[:*req.Move])
+	moveTimeLimit := current.MoveTimeLimit
+	current.mu.RUnlock()
+
+	branch.Mode = GAME_MODE_AI
+	branch.HumanPlayer = req.Human
+	// L'import rejoue l'échange à partir des camps d'avant l'échange : req.Human est le camp d'après
+	branch.Swapped = original.Swapped && *req.Move > 0
+	if branch.Swapped && req.Human != 0 {
+		branch.HumanPlayer = PLAYER_2 + PLAYER_1 - req.Human
+	}
+	if req.Difficulty != "" {
+		branch.Difficulty = req.Difficulty
+	}
+	branch.Winner = 0
+	branch.Termination = ""
+
+	game, err := importGame(branch)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, ERROR_INVALID_REQUEST, err.Error(), nil)
+		return
+	}
+	if game.GameOver {
+		writeAPIError(w, http.StatusConflict, ERROR_GAME_OVER, "La partie est déjà terminée à ce coup", nil)
+		return
+	}
+	if req.Human == 0 {
+		game.HumanPlayer = PLAYER_2 + PLAYER_1 - game.CurrentPlayer
+	}
+	game.BranchedFrom = &original
+	game.MoveTimeLimit = moveTimeLimit
+	game.logEvent(EVENT_BRANCH, "branche après %d coup(s) sur %d, humain joueur %d", *req.Move, played, game.HumanPlayer)
+	game.playOpening()
+
+	game = s.games.reset(sessionID, game)
+	s.onGameUpdated(sessionID, game)
+
+	writeGameResponse(w, http.StatusOK, GameResponse{
+		Success:   true,
+		GameState: game,
+		Winner:    game.Winner,
+	})
+}
+
+// Charge un export pour le relire coup par coup, sans toucher à la partie en cours
+func (s *Server) startReplayAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	var exp GameExport
+	if err := decodeJSONBody(w, r, &exp); err != nil {
+		writeBodyError(w, err, ERROR_INVALID_IMPORT, "Export illisible")
 		return
 	}
+	if exp.Lang == "" {
+		exp.Lang = requestLang(r)
+	}
 
-	if err := tmpl.Execute(w, currentGame); err != nil {
-		log.Printf("❌ Erreur d'affichage: %v", err)
-		http.Error(w, "Erreur interne", http.StatusInternalServerError)
+	// L'import valide toute la séquence : chaque étape pourra ensuite être rejouée sans erreur
+	game, err := importGame(exp)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, ERROR_INVALID_IMPORT, err.Error(), nil)
+		return
 	}
+
+	replay := s.games.startReplay(getSessionID(w, r), game)
+	writeReplayFrame(w, replay)
 }
 
-// Gère le changement de mode de jeu (2 joueurs / IA)
-func handleModeChange(w http.ResponseWriter, r *http.Request) {
+// Avance ou recule d'un coup dans la relecture de la session
+func (s *Server) stepReplayAPI(w http.ResponseWriter, r *http.Request, delta int) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, http.MethodPost)
 		return
 	}
 
-	mode := r.FormValue("mode")
-	startNewGame(mode)
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	replay, ok := s.games.stepReplay(getSessionID(w, r), delta)
+	if !ok {
+		http.Error(w, "Aucune relecture en cours", http.StatusNotFound)
+		return
+	}
+	writeReplayFrame(w, replay)
 }
 
-// Gère le placement d'un jeton
-func handleMove(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+// Écrit l'étape courante d'une relecture en JSON
+func writeReplayFrame(w http.ResponseWriter, replay Replay) {
+	frame, err := replay.frame()
+	if err != nil {
+		log.Printf("❌ Relecture impossible: %v", err)
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
 		return
 	}
 
-	// Récupération et validation de la colonne
-	colStr := r.FormValue("col")
-	col, err := strconv.Atoi(colStr)
-	if err != nil || col < 0 || col >= BOARD_COLS {
-		currentGame.StatusMessage = "❌ Colonne invalide"
-		tmpl.Execute(w, currentGame)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(frame)
+}
+
+// Retourne le bilan de la session contre l'IA
+func (s *Server) statsAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
 		return
 	}
 
-	// Placement du jeton
-	row := placePiece(col, currentGame.CurrentPlayer)
-	if row == -1 {
-		currentGame.StatusMessage = "❌ Colonne pleine !"
-		tmpl.Execute(w, currentGame)
+	game := s.games.get(getSessionID(w, r))
+
+	game.mu.RLock()
+	stats := game.Stats
+	game.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// Retourne le classement Elo des joueurs nommés, du meilleur au moins bon
+func (s *Server) leaderboardAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
 		return
 	}
 
-	// Vérification de la victoire ou du match nul
-	checkGameEnd(row, col)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.players.leaderboard())
+}
 
-	// Gestion du tour de l'IA si nécessaire
-	if !currentGame.GameOver && currentGame.Mode == GAME_MODE_AI && currentGame.CurrentPlayer == PLAYER_2 {
-		time.Sleep(600 * time.Millisecond) // Petite pause pour l'effet visuel
-		aiMakeMove()
+// Remet à zéro le bilan de la session, sans toucher à la partie en cours
+func (s *Server) resetStatsAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
 	}
 
-	tmpl.Execute(w, currentGame)
+	sessionID := getSessionID(w, r)
+	game := s.games.get(sessionID)
+
+	game.mu.Lock()
+	game.Stats = Stats{}
+	game.mu.Unlock()
+
+	s.onGameUpdated(sessionID, game)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Stats{})
 }
 
-// Commence une nouvelle partie
-func handleNewGame(w http.ResponseWriter, r *http.Request) {
+// Crée un salon en ligne ; la session qui le crée joue les rouges (joueur 1)
+func (s *Server) createRoomAPI(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, http.MethodPost)
 		return
 	}
 
-	mode := r.FormValue("mode")
-	if mode == "" {
-		mode = currentGame.Mode
-	}
+	room := s.rooms.create(getSessionID(w, r), requestLang(r))
+	logAttrs(r, slog.String("room", room.Code))
 
-	startNewGame(mode)
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	writeGameResponse(w, http.StatusCreated, GameResponse{
+		Success:   true,
+		Message:   "Salon créé : partagez le code " + room.Code,
+		GameState: room.game,
+		RoomCode:  room.Code,
+		Player:    PLAYER_1,
+	})
 }
 
-// ============================================================================
-// GAME LOGIC - CORE FUNCTIONS
-// ============================================================================
+// Aiguille les requêtes /api/room/{code}[/action] vers le salon concerné
+func (s *Server) roomAPI(w http.ResponseWriter, r *http.Request) {
+	code, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/room/"), "/")
+	code = strings.ToUpper(code)
+	sessionID := getSessionID(w, r)
 
-// Place un jeton dans la colonne spécifiée
-// Retourne la ligne où le jeton a été placé, ou -1 si la colonne est pleine
-func placePiece(col, player int) int {
-	for row := BOARD_ROWS - 1; row >= 0; row-- {
-		if currentGame.Board[row][col] == CELL_EMPTY {
-			currentGame.Board[row][col] = player
-			return row
+	method := http.MethodPost
+	if action == "" || action == "ws" {
+		method = http.MethodGet
+	}
+	if r.Method != method {
+		methodNotAllowed(w, method)
+		return
+	}
+
+	switch action {
+	case "":
+		room, player, err := s.rooms.lookup(code, sessionID)
+		if err != nil {
+			writeRoomError(w, err)
+			return
 		}
+		writeGameResponse(w, http.StatusOK, GameResponse{
+			Success:    true,
+			GameState:  room.game,
+			RoomCode:   code,
+			Player:     player,
+			Spectators: s.rooms.spectatorCount(room),
+			Takeback:   room.pendingTakeback(),
+		})
+
+	case "join":
+		room, player, err := s.rooms.join(code, sessionID)
+		if err != nil {
+			writeRoomError(w, err)
+			return
+		}
+		message := "Salon complet : vous regardez la partie"
+		switch player {
+		case PLAYER_1:
+			message = "Vous jouez les rouges"
+		case PLAYER_2:
+			message = "Vous jouez les jaunes"
+		}
+		// Le créateur apprend l'arrivée de son adversaire, les spectateurs celle d'un des leurs
+		s.broadcastRoom(room)
+		writeGameResponse(w, http.StatusOK, GameResponse{
+			Success:    true,
+			Message:    message,
+			GameState:  room.game,
+			RoomCode:   code,
+			Player:     player,
+			Spectators: s.rooms.spectatorCount(room),
+		})
+
+	case "move":
+		s.roomMoveAPI(w, r, code, sessionID)
+
+	case "resign":
+		s.roomResignAPI(w, code, sessionID)
+
+	case "takeback":
+		s.roomTakebackAPI(w, code, sessionID)
+
+	case "takeback/respond":
+		s.roomTakebackRespondAPI(w, r, code, sessionID)
+
+	case "draw/offer":
+		s.roomDrawOfferAPI(w, r, code, sessionID)
+
+	case "draw/respond":
+		s.roomDrawRespondAPI(w, r, code, sessionID)
+
+	case "ws":
+		s.roomWebSocket(w, r, code, sessionID)
+
+	case "leave":
+		room, _, err := s.rooms.lookup(code, sessionID)
+		if err == nil {
+			err = s.rooms.leave(code, sessionID)
+		}
+		if err != nil {
+			writeRoomError(w, err)
+			return
+		}
+		s.broadcastRoom(room)
+		writeGameResponse(w, http.StatusOK, GameResponse{Success: true, Message: "Vous avez quitté le salon", RoomCode: code})
+
+	default:
+		http.NotFound(w, r)
 	}
-	return -1
 }
 
-// Vérifie s'il y a un gagnant après un mouvement
-func checkForWin(row, col int) int {
-	player := currentGame.Board[row][col]
+// Retourne le salon et le joueur de la session, qui doit y avoir une place pour agir sur la partie
+func (s *Server) roomPlayer(code, sessionID string) (*Room, int, error) {
+	room, player, err := s.rooms.lookup(code, sessionID)
+	switch {
+	case err != nil:
+		return nil, 0, err
+	case player == 0 && s.rooms.spectating(room, sessionID):
+		return nil, 0, errNotAPlayer
+	case player == 0:
+		return nil, 0, errNotInRoom
+	}
+	return room, player, nil
+}
 
-	// Vérification horizontale
-	if count := checkDirection(row, col, 0, 1, player); count >= WINNING_COUNT {
-		return player
+// Joue un coup dans un salon, uniquement pour le joueur dont c'est le tour
+func (s *Server) roomMoveAPI(w http.ResponseWriter, r *http.Request, code, sessionID string) {
+	room, player, err := s.roomPlayer(code, sessionID)
+	if err != nil {
+		writeRoomError(w, err)
+		return
 	}
 
-	// Vérification verticale
-	if count := checkDirection(row, col, 1, 0, player); count >= WINNING_COUNT {
-		return player
+	var req struct {
+		Col int `json:"col"`
+	}
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		writeBodyError(w, err, ERROR_INVALID_REQUEST, "Requête illisible")
+		return
 	}
+	req.Col -= requestColumnBase(w, r)
 
-	// Vérification diagonale (haut-gauche vers bas-droite)
-	if count := checkDirection(row, col, 1, 1, player); count >= WINNING_COUNT {
-		return player
+	game := room.game
+	game.mu.Lock()
+	moveErr := game.playRoomMove(player, req.Col)
+	if moveErr == nil {
+		// Un coup joué périme la demande de reprise en attente
+		room.takeback = 0
 	}
+	winner := game.Winner
+	almostFull := game.Board.almostFullColumns()
+	logAttrs(r, slog.String("room", code), slog.Int("col", req.Col), slog.String("outcome", game.moveOutcome(moveErr)))
+	game.mu.Unlock()
 
-	// Vérification diagonale (bas-gauche vers haut-droite)
-	if count := checkDirection(row, col, -1, 1, player); count >= WINNING_COUNT {
-		return player
+	if moveErr != nil {
+		writeAPIError(w, moveErr.Status, moveErr.Code, moveErr.Message, game)
+		return
 	}
+	s.broadcastRoom(room)
 
-	return 0
+	writeGameResponse(w, http.StatusOK, GameResponse{
+		Success:    true,
+		GameState:  game,
+		Winner:     winner,
+		RoomCode:   code,
+		Player:     player,
+		Spectators: s.rooms.spectatorCount(room),
+		AlmostFull: almostFull,
+	})
 }
 
-// Compte les jetons dans une direction
-func checkDirection(row, col, dRow, dCol, player int) int {
-	count := 1
+// Joue le coup d'un joueur du salon, refusé si ce n'est pas son tour
+// L'appelant doit détenir g.mu en écriture
+func (g *GameState) playRoomMove(player, col int) *MoveError {
+	if !g.GameOver && g.CurrentPlayer != player {
+		return &MoveError{http.StatusConflict, ERROR_NOT_YOUR_TURN, "C'est au tour de votre adversaire"}
+	}
+	return g.playMove(col)
+}
 
-	// Comptage dans un sens
-	for i, j := row+dRow, col+dCol; i >= 0 && i < BOARD_ROWS && j >= 0 && j < BOARD_COLS && currentGame.Board[i][j] == player; i, j = i+dRow, j+dCol {
-		count++
+// Ouvre une connexion WebSocket sur la partie d'un salon
+// Les joueurs envoient {"col": n} pour jouer ; les autres sessions regardent la partie en spectatrices,
+// et chaque changement est diffusé à tous
+func (s *Server) roomWebSocket(w http.ResponseWriter, r *http.Request, code, sessionID string) {
+	room, player, err := s.rooms.watch(code, sessionID)
+	if err != nil {
+		writeRoomError(w, err)
+		return
 	}
+	defer func() {
+		s.rooms.unwatch(room, sessionID)
+		s.broadcastRoom(room)
+	}()
 
-	// Comptage dans l'autre sens
-	for i, j := row-dRow, col-dCol; i >= 0 && i < BOARD_ROWS && j >= 0 && j < BOARD_COLS && currentGame.Board[i][j] == player; i, j = i-dRow, j-dCol {
-		count++
+	columnBase := requestColumnBase(w, r)
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade a déjà répondu au client avec une erreur HTTP
+		return
 	}
 
-	return count
-}
+	key := ROOM_HUB_PREFIX + code
+	client := &wsClient{conn: conn, columnBase: columnBase}
+	s.hub.subscribe(key, client)
+	defer s.hub.unsubscribe(key, client)
 
-// Vérifie si le plateau est plein (match nul possible)
-func isBoardFull() bool {
-	for col := 0; col < BOARD_COLS; col++ {
-		if currentGame.Board[0][col] == CELL_EMPTY {
-			return false
-		}
+	// Le nouveau venu reçoit l'état avec sa place ; les autres apprennent le nombre de spectateurs
+	game := room.game
+	game.mu.RLock()
+	winner := game.Winner
+	game.mu.RUnlock()
+	response := GameResponse{Success: true, GameState: game, Winner: winner, RoomCode: code, Player: player, Spectators: s.rooms.spectatorCount(room), Takeback: room.pendingTakeback()}
+	if err := client.sendResponse(response); err != nil {
+		return
+	}
+	if player == 0 {
+		s.broadcastRoom(room)
 	}
-	return true
-}
 
-// Vérifie la fin de partie (victoire ou match nul)
-func checkGameEnd(row, col int) {
-	winner := checkForWin(row, col)
+	for {
+		var req struct {
+			Col int `json:"col"`
+		}
+		if err := conn.ReadJSON(&req); err != nil {
+			// Déconnexion du client ou message illisible
+			return
+		}
+		req.Col -= client.columnBase
 
-	if winner > 0 {
-		currentGame.GameOver = true
-		currentGame.Winner = winner
-		currentGame.StatusMessage = getWinnerMessage(winner)
-	} else if isBoardFull() {
-		currentGame.GameOver = true
-		currentGame.Winner = PLAYER_DRAW
-		currentGame.StatusMessage = "🤝 Match nul !"
-	} else {
-		// Changement de joueur
-		if currentGame.Mode == GAME_MODE_TWO_PLAYER || (currentGame.Mode == GAME_MODE_AI && currentGame.CurrentPlayer == PLAYER_1) {
-			currentGame.CurrentPlayer = PLAYER_2 + PLAYER_1 - currentGame.CurrentPlayer
+		// La place est relue à chaque message : un spectateur a pu rejoindre une place libérée
+		_, player, err := s.roomPlayer(code, sessionID)
+		var moveErr *MoveError
+		if err != nil {
+			moveErr = roomError(err)
+		} else if moveErr = s.limitMessage(r); moveErr == nil {
+			game.mu.Lock()
+			moveErr = game.playRoomMove(player, req.Col)
+			if moveErr == nil {
+				room.takeback = 0
+			}
+			game.mu.Unlock()
 		}
-		currentGame.StatusMessage = ""
+
+		if moveErr != nil {
+			client.sendResponse(GameResponse{
+				Success:   false,
+				Message:   moveErr.Message,
+				ErrorCode: moveErr.Code,
+				GameState: game,
+				RoomCode:  code,
+				Player:    player,
+			})
+			continue
+		}
+		s.broadcastRoom(room)
 	}
 }
 
-// Retourne le message de victoire approprié
-func getWinnerMessage(winner int) string {
-	switch winner {
-	case PLAYER_1:
-		return "🎉 Le Joueur Rouge (Joueur 1) gagne ! 🎉"
-	case PLAYER_2:
-		return "🎉 Le Joueur Jaune (Joueur 2) gagne ! 🎉"
-	case PLAYER_DRAW:
-		return "🤝 Match nul ! Égalité parfaite ! 🤝"
-	default:
-		return ""
+// Abandonne la partie d'un salon au nom du joueur de la session
+func (s *Server) roomResignAPI(w http.ResponseWriter, code, sessionID string) {
+	room, player, err := s.roomPlayer(code, sessionID)
+	if err != nil {
+		writeRoomError(w, err)
+		return
 	}
-}
 
-// Initialise une nouvelle partie avec le mode spécifié
-func startNewGame(mode string) {
-	currentGame = &GameState{
-		Board:         [BOARD_ROWS][BOARD_COLS]int{},
-		CurrentPlayer: PLAYER_1,
-		Mode:          mode,
-		GameOver:      false,
-		Winner:        0,
-		StatusMessage: "",
+	game := room.game
+	game.mu.Lock()
+	resignErr := game.resign(player)
+	if resignErr == nil {
+		room.takeback = 0
+	}
+	message, winner := game.StatusMessage, game.Winner
+	game.mu.Unlock()
+
+	if resignErr != nil {
+		writeAPIError(w, resignErr.Status, resignErr.Code, resignErr.Message, game)
+		return
 	}
+	s.broadcastRoom(room)
+
+	writeGameResponse(w, http.StatusOK, GameResponse{
+		Success:    true,
+		Message:    message,
+		GameState:  game,
+		Winner:     winner,
+		RoomCode:   code,
+		Player:     player,
+		Spectators: s.rooms.spectatorCount(room),
+	})
 }
 
-// ============================================================================
-// AI FUNCTIONS
-// ============================================================================
+// Demande à l'adversaire la permission de reprendre le dernier coup, qui doit être celui du joueur de la session
+// Le coup reste en place tant que l'adversaire n'a pas accepté ; un coup joué ou un abandon annule la demande
+func (s *Server) roomTakebackAPI(w http.ResponseWriter, code, sessionID string) {
+	room, player, err := s.roomPlayer(code, sessionID)
+	if err != nil {
+		writeRoomError(w, err)
+		return
+	}
 
-// Fait jouer l'IA automatiquement
-func aiMakeMove() {
-	col := getBestMove()
-	row := placePiece(col, PLAYER_2)
+	game := room.game
+	game.mu.Lock()
+	takebackErr := room.requestTakeback(player)
+	game.mu.Unlock()
 
-	if row == -1 {
+	if takebackErr != nil {
+		writeAPIError(w, takebackErr.Status, takebackErr.Code, takebackErr.Message, game)
 		return
 	}
+	s.broadcastRoom(room)
 
-	checkGameEnd(row, col)
+	writeGameResponse(w, http.StatusOK, GameResponse{
+		Success:    true,
+		Message:    "Demande de reprise envoyée à votre adversaire",
+		GameState:  game,
+		RoomCode:   code,
+		Player:     player,
+		Spectators: s.rooms.spectatorCount(room),
+		Takeback:   player,
+	})
+}
 
-	if !currentGame.GameOver {
-		currentGame.CurrentPlayer = PLAYER_1
-		currentGame.StatusMessage = ""
+// Enregistre la demande de reprise du joueur
+// L'appelant doit détenir room.game.mu en écriture
+func (room *Room) requestTakeback(player int) *MoveError {
+	g := room.game
+	switch {
+	case room.takeback != 0:
+		return &MoveError{http.StatusConflict, ERROR_TAKEBACK_PENDING, "Une demande de reprise attend déjà sa réponse"}
+	case g.GameOver:
+		return &MoveError{http.StatusConflict, ERROR_TAKEBACK_NOT_ALLOWED, "La partie est terminée"}
+	case len(g.Moves) == 0 || g.Moves[len(g.Moves)-1].Player != player:
+		return &MoveError{http.StatusConflict, ERROR_TAKEBACK_NOT_ALLOWED, "Le dernier coup n'est pas le vôtre"}
+	}
+	if err := g.checkUndoAllowed(); err != nil {
+		return err
 	}
+	room.takeback = player
+	return nil
 }
 
-// Calcule le meilleur mouvement pour l'IA
-func getBestMove() int {
-	// Priorité 1: L'IA peut-elle gagner ?
-	if col := findWinningMove(PLAYER_2); col != -1 {
-		return col
+// Répond à la demande de reprise de l'adversaire avec {"accept": true|false}
+// Le dernier coup n'est annulé qu'en cas d'acceptation ; la réponse est diffusée dans takebackAnswer
+func (s *Server) roomTakebackRespondAPI(w http.ResponseWriter, r *http.Request, code, sessionID string) {
+	room, player, err := s.roomPlayer(code, sessionID)
+	if err != nil {
+		writeRoomError(w, err)
+		return
 	}
 
-	// Priorité 2: Bloquer l'adversaire s'il peut gagner
-	if col := findWinningMove(PLAYER_1); col != -1 {
-		return col
+	var req struct {
+		Accept *bool `json:"accept"`
+	}
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		writeBodyError(w, err, ERROR_INVALID_REQUEST, "Requête illisible")
+		return
+	}
+	if req.Accept == nil {
+		writeAPIError(w, http.StatusBadRequest, ERROR_INVALID_REQUEST, "Champ accept manquant", nil)
+		return
 	}
 
-	// Priorité 3: Jouer au centre (stratégique)
-	centerCol := 3
-	if isValidMove(centerCol) {
-		return centerCol
+	game := room.game
+	game.mu.Lock()
+	takebackErr := room.answerTakeback(player, *req.Accept)
+	logAttrs(r, slog.String("room", code), slog.Bool("accept", *req.Accept))
+	game.mu.Unlock()
+
+	if takebackErr != nil {
+		writeAPIError(w, takebackErr.Status, takebackErr.Code, takebackErr.Message, game)
+		return
 	}
 
-	// Sinon: mouvement aléatoire valide
-	return findRandomValidMove()
+	answer, message := TAKEBACK_DECLINED, "Demande de reprise refusée"
+	if *req.Accept {
+		answer, message = TAKEBACK_ACCEPTED, "Demande de reprise acceptée : le dernier coup est annulé"
+	}
+	s.publishRoom(room, GameResponse{TakebackAnswer: answer})
+
+	writeGameResponse(w, http.StatusOK, GameResponse{
+		Success:        true,
+		Message:        message,
+		GameState:      game,
+		RoomCode:       code,
+		Player:         player,
+		Spectators:     s.rooms.spectatorCount(room),
+		TakebackAnswer: answer,
+	})
 }
 
-// Trouve un mouvement gagnant pour le joueur spécifié
-func findWinningMove(player int) int {
-	for _, col := range getValidMoves() {
-		if wouldWin(col, player) {
-			return col
+// Accepte ou refuse la demande de reprise de l'adversaire du joueur, puis l'efface
+// L'appelant doit détenir room.game.mu en écriture
+func (room *Room) answerTakeback(player int, accept bool) *MoveError {
+	if room.takeback == 0 || room.takeback == player {
+		return &MoveError{http.StatusConflict, ERROR_NO_TAKEBACK, "Votre adversaire n'a demandé aucune reprise"}
+	}
+	if accept {
+		if err := room.game.checkUndoAllowed(); err != nil {
+			return err
 		}
+		room.game.undoLastMove()
 	}
-	return -1
+	room.takeback = 0
+	return nil
 }
 
-// Retourne toutes les colonnes jouables
-func getValidMoves() []int {
-	var moves []int
-	for col := 0; col < BOARD_COLS; col++ {
-		if isValidMove(col) {
-			moves = append(moves, col)
-		}
+// Propose le nul à l'adversaire au nom du joueur de la session ; la proposition est diffusée au salon
+// dans l'état (DrawOffer) et tombe quand un coup est joué
+func (s *Server) roomDrawOfferAPI(w http.ResponseWriter, r *http.Request, code, sessionID string) {
+	room, player, err := s.roomPlayer(code, sessionID)
+	if err != nil {
+		writeRoomError(w, err)
+		return
 	}
-	return moves
-}
 
-// Choisit un mouvement aléatoire parmi les mouvements valides
-func findRandomValidMove() int {
-	moves := getValidMoves()
-	if len(moves) == 0 {
-		return 0
+	game := room.game
+	game.mu.Lock()
+	drawErr := game.offerDraw(player)
+	logAttrs(r, slog.String("room", code), slog.Int("player", player))
+	game.mu.Unlock()
+
+	if drawErr != nil {
+		writeAPIError(w, drawErr.Status, drawErr.Code, drawErr.Message, game)
+		return
 	}
-	return moves[rand.Intn(len(moves))]
-}
+	s.broadcastRoom(room)
 
-// Vérifie si un mouvement est valide (la colonne n'est pas pleine)
-func isValidMove(col int) bool {
-	return col >= 0 && col < BOARD_COLS && currentGame.Board[0][col] == CELL_EMPTY
+	writeGameResponse(w, http.StatusOK, GameResponse{
+		Success:    true,
+		Message:    "Nul proposé à votre adversaire",
+		GameState:  game,
+		RoomCode:   code,
+		Player:     player,
+		Spectators: s.rooms.spectatorCount(room),
+	})
 }
 
-// Simule un mouvement et vérifie s'il serait gagnant
-func wouldWin(col, player int) bool {
-	// Trouve la ligne où le jeton sera placé
-	row := -1
-	for r := BOARD_ROWS - 1; r >= 0; r-- {
-		if currentGame.Board[r][col] == CELL_EMPTY {
-			row = r
-			break
-		}
+// Répond à la proposition de nul de l'adversaire avec {"accept": true|false} ; la réponse est diffusée dans drawAnswer
+func (s *Server) roomDrawRespondAPI(w http.ResponseWriter, r *http.Request, code, sessionID string) {
+	room, player, err := s.roomPlayer(code, sessionID)
+	if err != nil {
+		writeRoomError(w, err)
+		return
 	}
 
-	if row == -1 {
-		return false
+	var req struct {
+		Accept *bool `json:"accept"`
+	}
+	if err := decodeJSONBody(w, r, &req); err != nil {
+		writeBodyError(w, err, ERROR_INVALID_REQUEST, "Requête illisible")
+		return
+	}
+	if req.Accept == nil {
+		writeAPIError(w, http.StatusBadRequest, ERROR_INVALID_REQUEST, "Champ accept manquant", nil)
+		return
 	}
 
-	// Simulation temporaire du mouvement
-	currentGame.Board[row][col] = player
-	winner := checkForWin(row, col)
-	currentGame.Board[row][col] = CELL_EMPTY
+	game := room.game
+	game.mu.Lock()
+	drawErr := game.answerDraw(player, *req.Accept)
+	if drawErr == nil && *req.Accept {
+		// La partie est finie : la demande de reprise en attente n'a plus d'objet
+		room.takeback = 0
+	}
+	message, winner := game.StatusMessage, game.Winner
+	logAttrs(r, slog.String("room", code), slog.Bool("accept", *req.Accept))
+	game.mu.Unlock()
 
-	return winner == player
+	if drawErr != nil {
+		writeAPIError(w, drawErr.Status, drawErr.Code, drawErr.Message, game)
+		return
+	}
+
+	answer := DRAW_DECLINED
+	if *req.Accept {
+		answer = DRAW_ACCEPTED
+	} else {
+		message, winner = "Proposition de nul refusée", 0
+	}
+	s.publishRoom(room, GameResponse{DrawAnswer: answer})
+
+	writeGameResponse(w, http.StatusOK, GameResponse{
+		Success:    true,
+		Message:    message,
+		GameState:  game,
+		Winner:     winner,
+		RoomCode:   code,
+		Player:     player,
+		Spectators: s.rooms.spectatorCount(room),
+		DrawAnswer: answer,
+	})
 }
 
-// ============================================================================
-// API HANDLERS - JSON ENDPOINTS
-// ============================================================================
+// Joueur dont la demande de reprise attend une réponse, 0 sinon
+func (room *Room) pendingTakeback() int {
+	room.game.mu.RLock()
+	defer room.game.mu.RUnlock()
+	return room.takeback
+}
+
+// Retourne le tableau du tournoi
+func (s *Server) tournamentAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
 
-// Retourne l'état actuel du jeu en JSON
-func getGameStateAPI(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(currentGame)
+	json.NewEncoder(w).Encode(s.tournament.snapshot())
 }
 
-// Crée une nouvelle partie via l'API
-func newGameAPI(w http.ResponseWriter, r *http.Request) {
+// Aiguille les requêtes POST /api/tournament/{action} : inscription, tirage, match, résultat, remise à zéro
+// Chaque réponse réussie porte le tableau à jour
+func (s *Server) tournamentActionAPI(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, http.MethodPost)
 		return
 	}
 
 	var req struct {
-		Mode string `json:"mode"`
+		Name   string `json:"name"`   // Joueur à inscrire (register)
+		Match  int    `json:"match"`  // Numéro du match (play, report)
+		Winner string `json:"winner"` // Vainqueur du match (report)
+	}
+	if err := decodeJSONBody(w, r, &req); err != nil && err != io.EOF {
+		writeBodyError(w, err, ERROR_INVALID_REQUEST, "Requête illisible")
+		return
 	}
-	json.NewDecoder(r.Body).Decode(&req)
 
-	startNewGame(req.Mode)
+	var err *MoveError
+	switch action := strings.TrimPrefix(r.URL.Path, "/api/tournament/"); action {
+	case "register":
+		err = s.tournament.register(req.Name)
+	case "start":
+		err = s.tournament.start(s.players.snapshot())
+	case "play":
+		s.tournamentPlayAPI(w, r, req.Match)
+		return
+	case "report":
+		err = s.tournament.report(req.Match, strings.TrimSpace(req.Winner))
+	case "reset":
+		s.tournament.reset()
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeAPIError(w, err.Status, err.Code, err.Message, nil)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(GameResponse{
-		Success:   true,
-		GameState: currentGame,
-	})
+	json.NewEncoder(w).Encode(s.tournament.snapshot())
 }
 
-// Gère un mouvement via l'API
-func handleMoveAPI(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+// Commence dans la session une partie à deux entre les joueurs du match, classée au Elo
+// Sa fin qualifie le vainqueur pour le tour suivant ; un nul laisse le match à rejouer
+func (s *Server) tournamentPlayAPI(w http.ResponseWriter, r *http.Request, id int) {
+	players, err := s.tournament.matchPlayers(id)
+	if err != nil {
+		writeAPIError(w, err.Status, err.Code, err.Message, nil)
 		return
 	}
 
-	var req struct {
-		Col int `json:"col"`
+	sessionID := getSessionID(w, r)
+	game := startNewGame(defaultGameOptions(requestLang(r)))
+	if err := game.setPlayers(players, true); err != nil {
+		writeAPIError(w, http.StatusConflict, ERROR_MATCH_NOT_PLAYABLE, err.Error(), nil)
+		return
 	}
-	json.NewDecoder(r.Body).Decode(&req)
-
-	row := placePiece(req.Col, currentGame.CurrentPlayer)
-	if row == -1 {
-		json.NewEncoder(w).Encode(GameResponse{
-			Success: false,
-			Message: "Colonne pleine",
-		})
+	if err := s.tournament.link(id, players, sessionID, game.StartedAt); err != nil {
+		writeAPIError(w, err.Status, err.Code, err.Message, nil)
 		return
 	}
+	logAttrs(r, slog.Int("match", id))
 
-	checkGameEnd(row, req.Col)
+	game = s.games.reset(sessionID, game)
+	s.onGameUpdated(sessionID, game)
 
-	var response GameResponse
-	if currentGame.GameOver {
-		response = GameResponse{
-			Success:   true,
-			Message:   currentGame.StatusMessage,
-			GameState: currentGame,
-			Winner:    currentGame.Winner,
-		}
-	} else {
-		response = GameResponse{
-			Success:   true,
-			GameState: currentGame,
-		}
+	writeGameResponse(w, http.StatusOK, GameResponse{
+		Success:   true,
+		Message:   fmt.Sprintf("Match n°%d : %s contre %s", id, players[0], players[1]),
+		GameState: game,
+	})
+}
+
+// Traduit une erreur de salon en réponse JSON avec son code ERROR_*
+func writeRoomError(w http.ResponseWriter, err error) {
+	roomErr := roomError(err)
+	writeAPIError(w, roomErr.Status, roomErr.Code, roomErr.Message, nil)
+}
+
+// Statut HTTP, code ERROR_* et message d'une erreur de salon
+func roomError(err error) *MoveError {
+	switch {
+	case errors.Is(err, errRoomNotFound):
+		return &MoveError{http.StatusNotFound, ERROR_ROOM_NOT_FOUND, "Salon introuvable"}
+	case errors.Is(err, errRoomFull):
+		return &MoveError{http.StatusConflict, ERROR_ROOM_FULL, "Le salon est complet"}
+	case errors.Is(err, errNotAPlayer):
+		return &MoveError{http.StatusForbidden, ERROR_NOT_A_PLAYER, "Vous regardez ce salon sans y jouer"}
+	default:
+		return &MoveError{http.StatusForbidden, ERROR_NOT_IN_ROOM, "Vous n'avez pas rejoint ce salon"}
 	}
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+// Décode le corps JSON de la requête dans v, strictement : au plus MAX_JSON_BODY_SIZE octets,
+// un seul objet, sans champ inconnu ni valeur mal typée
+// Un corps vide retourne io.EOF, que les routes dont le corps est facultatif ignorent
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	r.Body = http.MaxBytesReader(w, r.Body, MAX_JSON_BODY_SIZE)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("données en trop après l'objet JSON")
+	}
+	return nil
 }
 
-// Fait jouer l'IA via l'API
-func aiMoveAPI(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
-		return
+// Refuse une requête dont le corps n'a pas pu être décodé : 413 s'il est trop gros, 400 avec code sinon
+func writeBodyError(w http.ResponseWriter, err error, code, message string) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeAPIError(w, http.StatusRequestEntityTooLarge, ERROR_REQUEST_TOO_LARGE, fmt.Sprintf("Requête trop volumineuse (%d octets au plus)", MAX_JSON_BODY_SIZE), nil)
+	case err == io.EOF:
+		writeAPIError(w, http.StatusBadRequest, code, message+" : corps vide", nil)
+	default:
+		writeAPIError(w, http.StatusBadRequest, code, message+" : "+err.Error(), nil)
 	}
+}
 
-	col := getBestMove()
-	row := placePiece(col, PLAYER_2)
+// Refuse la requête avec 405, l'en-tête Allow listant les méthodes acceptées par la route
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+}
 
-	if row == -1 {
-		http.Error(w, "L'IA ne peut pas jouer", http.StatusInternalServerError)
+// Écrit une réponse d'erreur JSON avec son code HTTP et son code ERROR_*
+func writeAPIError(w http.ResponseWriter, status int, code, message string, game *GameState) {
+	writeGameResponse(w, status, GameResponse{
+		Success:   false,
+		Message:   message,
+		ErrorCode: code,
+		GameState: game,
+	})
+}
+
+// Écrit une réponse JSON avec le code HTTP donné
+// L'appelant ne doit pas détenir le verrou de la partie contenue dans la réponse
+func writeGameResponse(w http.ResponseWriter, status int, response GameResponse) {
+	data, err := marshalResponse(response, responseColumnBase(w))
+	if err != nil {
+		log.Printf("❌ Erreur d'encodage JSON: %v", err)
+		http.Error(w, "Erreur interne", http.StatusInternalServerError)
 		return
 	}
 
-	checkGameEnd(row, col)
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(GameResponse{
-		Success:   true,
-		Message:   currentGame.StatusMessage,
-		GameState: currentGame,
-		Winner:    currentGame.Winner,
-	})
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// Encode une réponse en JSON en détenant le verrou de lecture de sa partie
+// Avec columnBase à 1, les colonnes de la réponse et de son état sont numérotées à partir de 1
+func marshalResponse(response GameResponse, columnBase int) ([]byte, error) {
+	if response.GameState != nil {
+		response.GameState.mu.RLock()
+		defer response.GameState.mu.RUnlock()
+	}
+	return encodeResponse(response, columnBase)
+}
+
+// Corps de marshalResponse ; l'appelant détient le verrou de lecture de la partie de la réponse
+func encodeResponse(response GameResponse, columnBase int) ([]byte, error) {
+	if columnBase == 0 {
+		return json.Marshal(response)
+	}
+
+	// Type sans méthodes ; les champs de même nom JSON ci-dessous le masquent
+	type plainResponse GameResponse
+	var state json.RawMessage
+	if response.GameState != nil {
+		data, err := response.GameState.marshalColumns(columnBase)
+		if err != nil {
+			return nil, err
+		}
+		state = data
+	}
+	var aiMove *AIDecision
+	if response.AIMove != nil {
+		decision := response.AIMove.shifted(columnBase)
+		aiMove = &decision
+	}
+
+	return json.Marshal(struct {
+		plainResponse
+		GameState  json.RawMessage `json:"gameState,omitempty"`
+		AIMove     *AIDecision     `json:"aiMove,omitempty"`
+		AlmostFull []int           `json:"almostFull,omitempty"`
+	}{plainResponse(response), state, aiMove, shiftColumns(response.AlmostFull, columnBase)})
 }
@@ -0,0 +1,1791 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Dossier des sources, avant que TestMain ne passe dans le dossier temporaire
+var sourceDir string
+
+// Les tests tournent dans un dossier temporaire, pour que les sauvegardes (games.json, history.ndjson...)
+// n'écrasent pas celles du dépôt, sans limitation de débit ni journal des requêtes
+func TestMain(m *testing.M) {
+	var err error
+	if sourceDir, err = os.Getwd(); err != nil {
+		log.Fatal(err)
+	}
+	dir, err := os.MkdirTemp("", "puissance4-test-")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		log.Fatal(err)
+	}
+	config.RateLimit = 0
+	log.SetOutput(io.Discard)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// ============================================================================
+// OUTILS DE TEST
+// ============================================================================
+
+// Partie à deux joueurs sur le plateau classique
+func newTestGame() *GameState {
+	return newGameState(GAME_MODE_TWO_PLAYER, DIFFICULTY_EASY, VARIANT_STANDARD, DEFAULT_LANG, BOARD_ROWS, BOARD_COLS, WINNING_COUNT, PLAYER_1)
+}
+
+// Plateau décrit ligne par ligne, de haut en bas : '.' vide, 'R' joueur 1, 'J' joueur 2
+func parseTestBoard(t *testing.T, rows ...string) Board {
+	t.Helper()
+	board := newBoard(len(rows), len(rows[0]))
+	for row, line := range rows {
+		for col, cell := range line {
+			switch cell {
+			case 'R':
+				board[row][col] = PLAYER_1
+			case 'J':
+				board[row][col] = PLAYER_2
+			case '.':
+			default:
+				t.Fatalf("case %q inconnue", cell)
+			}
+		}
+	}
+	return board
+}
+
+// Serveur HTTP complet (routes de setupServer), sans parties, arrêté à la fin du test
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(newServer(nil).setupServer(DEFAULT_STATIC_DIR, nil, ""))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// Client HTTP qui garde son cookie de session, comme un navigateur
+func newTestClient(t *testing.T) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{Jar: jar}
+}
+
+// Envoie un corps JSON en POST et décode la GameResponse
+func postJSON(t *testing.T, client *http.Client, url, body string) (int, GameResponse) {
+	t.Helper()
+	resp, err := client.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var response GameResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("POST %s : réponse illisible : %v", url, err)
+	}
+	return resp.StatusCode, response
+}
+
+// Joue les colonnes dans l'ordre, chaque joueur à son tour, en échouant sur une colonne pleine
+func playColumns(t *testing.T, game *GameState, cols ...int) {
+	t.Helper()
+	for _, col := range cols {
+		row := game.placePiece(col, game.CurrentPlayer)
+		if row == -1 {
+			t.Fatalf("coup en colonne %d refusé : colonne pleine", col)
+		}
+		game.checkGameEnd(row, col)
+	}
+}
+
+// ============================================================================
+// PERSISTENCE
+// ============================================================================
+
+// Écrit une sauvegarde d'une seule partie, après avoir modifié son encodage JSON
+func writeSave(t *testing.T, game *GameState, edit func(map[string]any)) string {
+	t.Helper()
+	data, err := json.Marshal(game)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	edit(fields)
+
+	data, err = json.Marshal(map[string]any{"session": fields})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), SAVE_FILE)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Une sauvegarde antérieure à FirstPlayer se relit avec les Rouges au premier coup
+func TestLoadGamesLegacyFirstPlayer(t *testing.T) {
+	game := newTestGame()
+	playColumns(t, game, 3, 3, 4)
+	path := writeSave(t, game, func(fields map[string]any) { delete(fields, "FirstPlayer") })
+
+	saved, err := LoadGames(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := saved["session"]
+	if loaded == nil {
+		t.Fatal("partie ignorée au chargement")
+	}
+	if loaded.FirstPlayer != PLAYER_1 {
+		t.Errorf("FirstPlayer = %d, attendu %d", loaded.FirstPlayer, PLAYER_1)
+	}
+	if loaded.CurrentPlayer != PLAYER_2 {
+		t.Errorf("CurrentPlayer = %d, attendu %d", loaded.CurrentPlayer, PLAYER_2)
+	}
+}
+
+// Un premier joueur inconnu écarte la partie sans faire planter le chargement
+func TestLoadGamesInvalidFirstPlayer(t *testing.T) {
+	game := newTestGame()
+	playColumns(t, game, 3)
+	path := writeSave(t, game, func(fields map[string]any) { fields["FirstPlayer"] = 5 })
+
+	saved, err := LoadGames(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := saved["session"]; ok {
+		t.Error("partie au premier joueur 5 chargée")
+	}
+}
+
+// ============================================================================
+// RÈGLES DU JEU
+// ============================================================================
+
+// Avec setFirstPlayer(PLAYER_2), les Jaunes jouent le premier coup et les Rouges répondent
+func TestFirstPlayerTwoOpens(t *testing.T) {
+	game := newTestGame()
+	game.setFirstPlayer(PLAYER_2)
+
+	playColumns(t, game, 3)
+	if got := game.Board[BOARD_ROWS-1][3]; got != PLAYER_2 {
+		t.Fatalf("case du premier coup = %d, attendu %d", got, PLAYER_2)
+	}
+	if game.CurrentPlayer != PLAYER_1 {
+		t.Errorf("CurrentPlayer = %d après le premier coup, attendu %d", game.CurrentPlayer, PLAYER_1)
+	}
+	if err := validateBoard(game.Board, game.Variant, game.GravityOff, game.FirstPlayer); err != nil {
+		t.Errorf("plateau jugé invalide : %v", err)
+	}
+}
+
+// Une fois la partie gagnée, tout nouveau coup est refusé avec GAME_OVER et le plateau reste figé
+func TestMoveAfterWin(t *testing.T) {
+	s := newServer(nil)
+	game := newTestGame()
+	playColumns(t, game, 0, 1, 0, 1, 0, 1, 0)
+	if game.Winner != PLAYER_1 {
+		t.Fatalf("gagnant %d, attendu %d", game.Winner, PLAYER_1)
+	}
+	s.games.reset("session-test", game)
+
+	before := game.Board.Clone()
+	moves := len(game.Moves)
+	req := httptest.NewRequest(http.MethodPost, "/api/move", strings.NewReader(`{"col": 2}`))
+	req.AddCookie(&http.Cookie{Name: SESSION_COOKIE_NAME, Value: "session-test"})
+	rec := httptest.NewRecorder()
+	s.handleMoveAPI(rec, req)
+
+	var response GameResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("réponse illisible : %v", err)
+	}
+	if rec.Code != http.StatusConflict || response.ErrorCode != ERROR_GAME_OVER {
+		t.Fatalf("coup après la victoire : statut %d, code %q, attendu %d %s", rec.Code, response.ErrorCode, http.StatusConflict, ERROR_GAME_OVER)
+	}
+	if !reflect.DeepEqual(game.Board, before) || len(game.Moves) != moves {
+		t.Error("le coup refusé a modifié la partie")
+	}
+}
+
+// Avec MaxMoves, la partie est nulle au coup limite, bien avant que le plateau soit plein,
+// sauf si ce coup gagne
+func TestMaxMovesDraw(t *testing.T) {
+	game := newTestGame()
+	game.MaxMoves = 6
+	playColumns(t, game, 0, 1, 2, 3, 4)
+	if game.GameOver {
+		t.Fatal("partie terminée avant le coup limite")
+	}
+	playColumns(t, game, 5)
+	if !game.GameOver || game.Winner != PLAYER_DRAW {
+		t.Fatalf("après %d coups : terminée %v, gagnant %d, attendu un nul", game.MaxMoves, game.GameOver, game.Winner)
+	}
+	if game.Board.isBoardFull() {
+		t.Error("le plateau est plein : le nul ne vient pas de MaxMoves")
+	}
+	if err := game.playMove(6); err == nil || err.Code != ERROR_GAME_OVER {
+		t.Errorf("coup après le nul : %v, attendu %s", err, ERROR_GAME_OVER)
+	}
+
+	// Un alignement au coup limite l'emporte sur le nul
+	game = newTestGame()
+	game.MaxMoves = 7
+	playColumns(t, game, 0, 1, 0, 1, 0, 1, 0)
+	if game.Winner != PLAYER_1 {
+		t.Errorf("victoire au coup limite : gagnant %d, attendu %d", game.Winner, PLAYER_1)
+	}
+}
+
+// Un plateau où chaque fenêtre de quatre mélange les deux couleurs est bloqué avant d'être plein
+func TestCanAnyoneStillWinBlocked(t *testing.T) {
+	blocked := parseTestBoard(t,
+		".JR.RJ.",
+		"RJRJRJR",
+		"JRJRJRJ",
+		"JRJRJRJ",
+		"RJRJRJR",
+		"RJRJRJR",
+	)
+	if blocked.isBoardFull() {
+		t.Fatal("le plateau bloqué ne devrait pas être plein")
+	}
+	if blocked.canAnyoneStillWin(WINNING_COUNT) {
+		t.Error("plateau bloqué : un alignement est encore jugé possible")
+	}
+
+	// Sans la ligne du haut, les fenêtres horizontales de cette ligne redeviennent jouables
+	open := blocked.Clone()
+	for col := range open[0] {
+		open[0][col] = CELL_EMPTY
+	}
+	if !open.canAnyoneStillWin(WINNING_COUNT) {
+		t.Error("ligne du haut vide : aucun alignement jugé possible")
+	}
+	if !newBoard(BOARD_ROWS, BOARD_COLS).canAnyoneStillWin(WINNING_COUNT) {
+		t.Error("plateau vide : aucun alignement jugé possible")
+	}
+}
+
+// checkForWin reconnaît l'alignement dans chacune des quatre directions, même quand le dernier coup
+// est au milieu de la ligne, et retourne ses cases et sa direction
+func TestCheckForWinDirections(t *testing.T) {
+	tests := []struct {
+		direction string
+		board     Board
+		last      [2]int
+		cells     [][2]int
+	}{
+		{WIN_HORIZONTAL, parseTestBoard(t,
+			".......",
+			".......",
+			".......",
+			".......",
+			"JJJ....",
+			".RRRR..",
+		), [2]int{5, 2}, [][2]int{{5, 1}, {5, 2}, {5, 3}, {5, 4}}},
+		{WIN_VERTICAL, parseTestBoard(t,
+			".......",
+			".......",
+			"....J..",
+			"....J..",
+			"....J..",
+			"RRR.J..",
+		), [2]int{2, 4}, [][2]int{{2, 4}, {3, 4}, {4, 4}, {5, 4}}},
+		{WIN_DIAGONAL_DOWN, parseTestBoard(t,
+			".......",
+			".......",
+			"..R....",
+			"..JR...",
+			"..RJR..",
+			"..JJJR.",
+		), [2]int{3, 3}, [][2]int{{2, 2}, {3, 3}, {4, 4}, {5, 5}}},
+		{WIN_DIAGONAL_UP, parseTestBoard(t,
+			".......",
+			".......",
+			".....J.",
+			"....JR.",
+			"...JRR.",
+			"..JRRJ.",
+		), [2]int{4, 3}, [][2]int{{2, 5}, {3, 4}, {4, 3}, {5, 2}}},
+	}
+	for _, tt := range tests {
+		player := tt.board[tt.last[0]][tt.last[1]]
+		winner, cells, direction := tt.board.checkForWin(tt.last[0], tt.last[1], WINNING_COUNT, true)
+		if winner != player || direction != tt.direction {
+			t.Errorf("%s : gagnant %d en %q, attendu %d", tt.direction, winner, direction, player)
+			continue
+		}
+		sort.Slice(cells, func(i, j int) bool {
+			return cells[i][0] < cells[j][0] || cells[i][0] == cells[j][0] && cells[i][1] < cells[j][1]
+		})
+		if !reflect.DeepEqual(cells, tt.cells) {
+			t.Errorf("%s : cases %v, attendu %v", tt.direction, cells, tt.cells)
+		}
+	}
+}
+
+// Sans surlongueur, une ligne de cinq ne gagne pas pour un alignement de 4, sauf alignement exact dans une autre direction
+func TestCheckForWinOverline(t *testing.T) {
+	board := parseTestBoard(t,
+		".......",
+		".......",
+		".......",
+		".......",
+		"...R...",
+		"RRRRR..",
+	)
+	if winner, _, _ := board.checkForWin(5, 2, WINNING_COUNT, true); winner != PLAYER_1 {
+		t.Errorf("ligne de cinq avec surlongueur : gagnant %d, attendu %d", winner, PLAYER_1)
+	}
+	if winner, _, _ := board.checkForWin(5, 2, WINNING_COUNT, false); winner != 0 {
+		t.Errorf("ligne de cinq sans surlongueur : gagnant %d, attendu aucun", winner)
+	}
+
+	// La même ligne de cinq, traversée par une diagonale d'exactement quatre
+	board = parseTestBoard(t,
+		".......",
+		".......",
+		"......R",
+		".....RJ",
+		"....RJJ",
+		"RRRRRJJ",
+	)
+	winner, cells, direction := board.checkForWin(5, 3, WINNING_COUNT, false)
+	if winner != PLAYER_1 || direction != WIN_DIAGONAL_UP || len(cells) != WINNING_COUNT {
+		t.Errorf("diagonale exacte : gagnant %d en %q sur %d cases, attendu %d en %q sur %d", winner, direction, len(cells), PLAYER_1, WIN_DIAGONAL_UP, WINNING_COUNT)
+	}
+}
+
+// Puissance 5 sur 9 colonnes : une ligne de six gagne avec la surlongueur permise (par défaut), pas sans
+func TestConnectFiveOverline(t *testing.T) {
+	for _, allowOverline := range []bool{true, false} {
+		game := newGameState(GAME_MODE_TWO_PLAYER, DIFFICULTY_EASY, VARIANT_STANDARD, DEFAULT_LANG, BOARD_ROWS, 9, 5, PLAYER_1)
+		game.AllowOverline = allowOverline
+
+		// Les Rouges remplissent le bas des colonnes 0 à 5, le trou en 3 en dernier ; les Jaunes jouent au-dessus
+		playColumns(t, game, 0, 0, 1, 1, 2, 2, 4, 4, 5, 5, 3)
+		if allowOverline && game.Winner != PLAYER_1 {
+			t.Errorf("surlongueur permise : gagnant %d, attendu %d", game.Winner, PLAYER_1)
+		}
+		if !allowOverline && game.GameOver {
+			t.Errorf("surlongueur interdite : partie terminée, gagnant %d", game.Winner)
+		}
+	}
+
+	// Un alignement d'exactement cinq gagne dans les deux cas
+	for _, allowOverline := range []bool{true, false} {
+		board := parseTestBoard(t,
+			".........",
+			".........",
+			".........",
+			".........",
+			".JJJJ....",
+			".RRRRR...",
+		)
+		if winner, _, _ := board.checkForWin(5, 3, 5, allowOverline); winner != PLAYER_1 {
+			t.Errorf("allowOverline=%v : alignement de cinq, gagnant %d, attendu %d", allowOverline, winner, PLAYER_1)
+		}
+	}
+}
+
+// L'IA et l'analyse ne prennent pas une ligne trop longue pour un gain quand la surlongueur est interdite
+func TestAIRespectsNoOverline(t *testing.T) {
+	board := parseTestBoard(t,
+		".......",
+		".......",
+		".......",
+		".......",
+		"R.R....",
+		"JJJ.J.R",
+	)
+	if !wouldWin(board, 3, PLAYER_2, WINNING_COUNT, true) {
+		t.Fatal("la colonne 3 devrait gagner avec surlongueur")
+	}
+	if wouldWin(board, 3, PLAYER_2, WINNING_COUNT, false) {
+		t.Error("la colonne 3 gagne sans surlongueur malgré la ligne de cinq")
+	}
+	if threats := findThreats(board, WINNING_COUNT, false); len(threats.Player2) != 0 {
+		t.Errorf("menaces des Jaunes sans surlongueur = %v, attendu aucune", threats.Player2)
+	}
+
+	for _, allowOverline := range []bool{true, false} {
+		decision := decideMove(context.Background(), board, WINNING_COUNT, allowOverline, DIFFICULTY_MEDIUM, PLAYER_2, rand.New(rand.NewSource(1)))
+		if won := decision.Reason == AI_REASON_WINS; won != allowOverline {
+			t.Errorf("allowOverline=%v : coup %d pour la raison %q", allowOverline, decision.Col, decision.Reason)
+		}
+	}
+}
+
+// Sans surlongueur, la règle survit à l'export JSON et au PGN ; un export ou une sauvegarde antérieurs
+// à allowOverline, qui la notaient exactWin, la gardent aussi
+func TestOverlineRoundTrip(t *testing.T) {
+	game := newGameState(GAME_MODE_TWO_PLAYER, DIFFICULTY_EASY, VARIANT_STANDARD, DEFAULT_LANG, BOARD_ROWS, 9, 5, PLAYER_1)
+	game.AllowOverline = false
+	playColumns(t, game, 0, 1)
+
+	legacyExport := game.export()
+	legacyExport.Overline, legacyExport.ExactWin = nil, true
+	var legacySave GameState
+	if err := json.Unmarshal([]byte(`{"ExactWin": true}`), &legacySave); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := map[string]*GameState{"sauvegarde antérieure": &legacySave}
+	var err error
+	if restored["export"], err = importGame(game.export()); err != nil {
+		t.Fatalf("export refusé : %v", err)
+	}
+	if restored["PGN"], err = importPGN(game.pgn(), DEFAULT_LANG); err != nil {
+		t.Fatalf("PGN refusé : %v", err)
+	}
+	if restored["export antérieur"], err = importGame(legacyExport); err != nil {
+		t.Fatalf("export antérieur refusé : %v", err)
+	}
+	for name, g := range restored {
+		if g.AllowOverline {
+			t.Errorf("%s : surlongueur permise, attendu interdite", name)
+		}
+	}
+
+	var fresh GameState
+	if err := json.Unmarshal([]byte(`{}`), &fresh); err != nil || !fresh.AllowOverline {
+		t.Errorf("sauvegarde sans la règle : surlongueur %v (%v), attendu permise", fresh.AllowOverline, err)
+	}
+}
+
+// dropRow donne la ligne d'arrivée d'un jeton sans le poser : le bas d'une colonne vide,
+// la case au-dessus de la pile d'une colonne entamée, -1 pour une colonne pleine ou hors du plateau
+func TestDropRow(t *testing.T) {
+	board := parseTestBoard(t,
+		"..R....",
+		"..J....",
+		"..R....",
+		"..J....",
+		"..RJ...",
+		"..JRR..",
+	)
+	before := board.Clone()
+	tests := []struct {
+		name string
+		col  int
+		row  int
+	}{
+		{"colonne vide", 0, BOARD_ROWS - 1},
+		{"colonne pleine", 2, -1},
+		{"colonne de deux jetons", 3, BOARD_ROWS - 3},
+		{"colonne d'un jeton", 4, BOARD_ROWS - 2},
+		{"colonne négative", -1, -1},
+		{"colonne trop grande", BOARD_COLS, -1},
+	}
+	for _, tt := range tests {
+		if row := board.dropRow(tt.col); row != tt.row {
+			t.Errorf("%s : dropRow(%d) = %d, attendu %d", tt.name, tt.col, row, tt.row)
+		}
+	}
+	if !reflect.DeepEqual(board, before) {
+		t.Error("dropRow a modifié le plateau")
+	}
+}
+
+// columnHeights compte les jetons de chaque colonne d'un plateau en dents de scie,
+// et ne compte pas les cases vides sous un jeton posé sans gravité
+func TestColumnHeights(t *testing.T) {
+	jagged := parseTestBoard(t,
+		"......J",
+		"...R..R",
+		"...J..J",
+		".J.R..R",
+		".R.J.RJ",
+		"RJ.RJJR",
+	)
+	if got, want := jagged.columnHeights(), []int{1, 3, 0, 5, 1, 2, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("plateau en dents de scie : hauteurs %v, attendu %v", got, want)
+	}
+	for col, height := range jagged.columnHeights() {
+		if row := jagged.dropRow(col); row != BOARD_ROWS-1-height {
+			t.Errorf("colonne %d de hauteur %d : dropRow = %d, attendu %d", col, height, row, BOARD_ROWS-1-height)
+		}
+	}
+
+	floating := parseTestBoard(t,
+		".......",
+		".......",
+		"..R....",
+		".......",
+		".......",
+		"..J....",
+	)
+	if got, want := floating.columnHeights(), []int{0, 0, 2, 0, 0, 0, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sans gravité : hauteurs %v, attendu %v", got, want)
+	}
+}
+
+// scanBoardForWinner parcourt tout le plateau : il trouve une diagonale que checkForWin
+// ne voit pas depuis le dernier coup, et signale les positions où les deux joueurs sont alignés
+func TestScanBoardForWinner(t *testing.T) {
+	noWinner := parseTestBoard(t,
+		".......",
+		".......",
+		".......",
+		"..JR...",
+		"..RJR..",
+		".JRJRJ.",
+	)
+	if winner := noWinner.scanBoardForWinner(WINNING_COUNT, true); winner != 0 {
+		t.Errorf("plateau sans alignement : gagnant %d, attendu aucun", winner)
+	}
+
+	// Diagonale jaune de (2, 3) à (5, 0) ; le dernier coup, rouge en (5, 6), n'y touche pas
+	diagonal := parseTestBoard(t,
+		".......",
+		".......",
+		"...J...",
+		"..JR...",
+		".JRR...",
+		"JRRJ..R",
+	)
+	if winner, _, _ := diagonal.checkForWin(5, 6, WINNING_COUNT, true); winner != 0 {
+		t.Fatalf("checkForWin depuis le dernier coup : gagnant %d, attendu aucun", winner)
+	}
+	if winner := diagonal.scanBoardForWinner(WINNING_COUNT, true); winner != PLAYER_2 {
+		t.Errorf("diagonale loin du dernier coup : gagnant %d, attendu %d", winner, PLAYER_2)
+	}
+
+	both := parseTestBoard(t,
+		".......",
+		".......",
+		"J.....R",
+		"J.....R",
+		"J.....R",
+		"J.....R",
+	)
+	if winner := both.scanBoardForWinner(WINNING_COUNT, true); winner != PLAYER_BOTH {
+		t.Errorf("deux alignements : gagnant %d, attendu %d", winner, PLAYER_BOTH)
+	}
+}
+
+// À nombre de jetons égal et hors de la colonne centrale, un trois ouvert vaut plus que des jetons épars,
+// et le même trois compte contre son adversaire
+func TestEvaluateBoardOpenThree(t *testing.T) {
+	openThree := parseTestBoard(t,
+		".......",
+		".......",
+		".......",
+		".......",
+		".......",
+		"RRR..J.",
+	)
+	scattered := parseTestBoard(t,
+		".......",
+		".......",
+		".......",
+		".......",
+		".......",
+		"RR..J.R",
+	)
+	withThree := evaluateBoard(openThree, WINNING_COUNT, PLAYER_1)
+	without := evaluateBoard(scattered, WINNING_COUNT, PLAYER_1)
+	if withThree <= without {
+		t.Errorf("trois ouvert : %d, jetons épars : %d, attendu un score plus élevé pour le trois ouvert", withThree, without)
+	}
+	if threatened, calm := evaluateBoard(openThree, WINNING_COUNT, PLAYER_2), evaluateBoard(scattered, WINNING_COUNT, PLAYER_2); threatened >= calm {
+		t.Errorf("pour les Jaunes : %d face au trois ouvert, %d sinon, attendu un score plus bas face au trois", threatened, calm)
+	}
+}
+
+// L'IA travaille sur des copies : le plateau qu'on lui passe ressort intact, quelle que soit la difficulté
+func TestGetBestMoveKeepsBoard(t *testing.T) {
+	board := parseTestBoard(t,
+		".......",
+		".......",
+		".......",
+		"...J...",
+		"..RR...",
+		".JRRJ..",
+	)
+	before := board.Clone()
+	for _, difficulty := range []string{DIFFICULTY_EASY, DIFFICULTY_MEDIUM, DIFFICULTY_HARD} {
+		col := getBestMove(context.Background(), board, WINNING_COUNT, true, difficulty, PLAYER_2, rand.New(rand.NewSource(1)))
+		if col < 0 || col >= BOARD_COLS {
+			t.Errorf("%s : colonne %d hors du plateau", difficulty, col)
+		}
+		if !reflect.DeepEqual(board, before) {
+			t.Fatalf("%s : le plateau a été modifié", difficulty)
+		}
+	}
+}
+
+// Avec un contexte déjà annulé (client parti), l'IA rend aussitôt un coup jouable au lieu de chercher
+func TestDecideMoveCanceledContext(t *testing.T) {
+	board := parseTestBoard(t,
+		".......",
+		".......",
+		"...R...",
+		"...J...",
+		"..RRJ..",
+		".JJRRJ.",
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, difficulty := range []string{DIFFICULTY_EASY, DIFFICULTY_MEDIUM, DIFFICULTY_HARD} {
+		start := time.Now()
+		decision := decideMove(ctx, board, WINNING_COUNT, true, difficulty, PLAYER_1, rand.New(rand.NewSource(1)))
+		if elapsed := time.Since(start); elapsed >= HARD_TIME_BUDGET {
+			t.Errorf("%s : %v de recherche malgré l'annulation", difficulty, elapsed)
+		}
+		if board.dropRow(decision.Col) == -1 {
+			t.Errorf("%s : colonne %d injouable", difficulty, decision.Col)
+		}
+	}
+}
+
+// La personnalité mirror répond dans la colonne symétrique du coup humain, ouvre au centre,
+// et retombe sur le coup standard quand la colonne symétrique est pleine
+func TestMirrorPersonality(t *testing.T) {
+	game := newGameState(GAME_MODE_AI, DIFFICULTY_MEDIUM, VARIANT_STANDARD, DEFAULT_LANG, BOARD_ROWS, BOARD_COLS, WINNING_COUNT, PLAYER_1)
+	game.Personality = PERSONALITY_MIRROR
+	for _, col := range []int{0, 2, 3, 5} {
+		playColumns(t, game, col)
+		if !game.aiMakeMove(context.Background()) {
+			t.Fatalf("l'IA n'a pas répondu au coup en colonne %d", col)
+		}
+		if got, want := game.LastMove[1], BOARD_COLS-1-col; got != want || game.LastAIDecision.Reason != AI_REASON_MIRROR {
+			t.Errorf("réponse au coup en colonne %d : colonne %d (%s), attendu %d (%s)", col, got, game.LastAIDecision.Reason, want, AI_REASON_MIRROR)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	empty := newBoard(BOARD_ROWS, BOARD_COLS)
+	if decision := decideMirrorMove(context.Background(), empty, WINNING_COUNT, true, DIFFICULTY_MEDIUM, PLAYER_1, nil, rng); decision.Col != BOARD_COLS/2 {
+		t.Errorf("ouverture : colonne %d, attendu le centre %d", decision.Col, BOARD_COLS/2)
+	}
+
+	// La colonne 5, symétrique de la colonne 1, est pleine
+	full := parseTestBoard(t,
+		".....J.",
+		".....R.",
+		".....J.",
+		".....R.",
+		".....J.",
+		".R...R.",
+	)
+	decision := decideMirrorMove(context.Background(), full, WINNING_COUNT, true, DIFFICULTY_MEDIUM, PLAYER_2, &[2]int{5, 1}, rng)
+	if decision.Reason == AI_REASON_MIRROR || full.dropRow(decision.Col) == -1 {
+		t.Errorf("colonne symétrique pleine : colonne %d (%s), attendu un coup standard jouable", decision.Col, decision.Reason)
+	}
+}
+
+// L'échange de la règle du gâteau survit à l'export JSON, au PGN et à la relecture, qui le
+// rejouent après le premier coup ; annuler ce coup annule aussi l'échange
+func TestSwapRoundTrip(t *testing.T) {
+	game := newTestGame()
+	game.Players = [2]string{"Alice", "Bob"}
+	playColumns(t, game, 3)
+	if err := game.swapSides(); err != nil {
+		t.Fatalf("échange refusé : %s", err.Message)
+	}
+	opening := game.export()
+	playColumns(t, game, 2, 4)
+
+	fromPGN, err := importPGN(game.pgn(), DEFAULT_LANG)
+	if err != nil {
+		t.Fatalf("PGN refusé : %v", err)
+	}
+	fromJSON, err := importGame(game.export())
+	if err != nil {
+		t.Fatalf("export refusé : %v", err)
+	}
+	for name, imported := range map[string]*GameState{"export": fromJSON, "PGN": fromPGN} {
+		if !imported.Swapped || !reflect.DeepEqual(imported.Board, game.Board) || imported.CurrentPlayer != game.CurrentPlayer {
+			t.Errorf("%s : échange %v, joueur %d, attendu la partie échangée, joueur %d", name, imported.Swapped, imported.CurrentPlayer, game.CurrentPlayer)
+		}
+	}
+
+	// Juste après l'échange, l'import garde l'échange déjà fait : il n'est pas permis une seconde fois
+	afterSwap, err := importGame(opening)
+	if err != nil {
+		t.Fatalf("export après l'échange refusé : %v", err)
+	}
+	if err := afterSwap.swapSides(); err == nil || err.Code != ERROR_SWAP_NOT_ALLOWED {
+		t.Error("second échange accepté après l'import")
+	}
+
+	for step, want := range [][2]string{{"Alice", "Bob"}, {"Bob", "Alice"}, {"Bob", "Alice"}} {
+		frame, err := Replay{game: game, step: step}.frame()
+		if err != nil {
+			t.Fatalf("étape %d : %v", step, err)
+		}
+		if frame.GameState.Swapped != (step > 0) || frame.GameState.Players != want {
+			t.Errorf("étape %d : échange %v, joueurs %v, attendu %v", step, frame.GameState.Swapped, frame.GameState.Players, want)
+		}
+	}
+
+	for game.undoLastMove() {
+	}
+	if game.Swapped || game.Players != [2]string{"Alice", "Bob"} {
+		t.Errorf("plateau vide après annulation : échange %v, joueurs %v, attendu l'échange annulé", game.Swapped, game.Players)
+	}
+
+	// Contre l'IA qui a ouvert, annuler sa réponse à l'échange rend l'ouverture à l'IA
+	ai := newGameState(GAME_MODE_AI, DIFFICULTY_EASY, VARIANT_STANDARD, DEFAULT_LANG, BOARD_ROWS, BOARD_COLS, WINNING_COUNT, PLAYER_2)
+	ai.playOpening()
+	if err := ai.swapSides(); err != nil {
+		t.Fatalf("échange contre l'IA refusé : %s", err.Message)
+	}
+	imported, err := importGame(ai.export())
+	if err != nil {
+		t.Fatalf("export contre l'IA refusé : %v", err)
+	}
+	if imported.HumanPlayer != ai.HumanPlayer || !imported.Swapped {
+		t.Errorf("import contre l'IA : humain %d, échange %v, attendu l'humain %d après échange", imported.HumanPlayer, imported.Swapped, ai.HumanPlayer)
+	}
+	if !ai.undoLastMove() || len(ai.Moves) != 1 || ai.Swapped || ai.HumanPlayer != PLAYER_2 || ai.CurrentPlayer != PLAYER_2 {
+		t.Errorf("annulation de la réponse à l'échange : %d coup(s), échange %v, humain %d, au trait %d, attendu l'ouverture de l'IA et l'humain 2 au trait", len(ai.Moves), ai.Swapped, ai.HumanPlayer, ai.CurrentPlayer)
+	}
+}
+
+// En Pop Out, deux coups suivis de leurs retraits ramènent le plateau vide, les Rouges au trait :
+// repetitionCount retrouve la position, et RepetitionDraw déclare le nul à sa troisième occurrence
+func TestRepetitionPopOut(t *testing.T) {
+	game := newGameState(GAME_MODE_TWO_PLAYER, DIFFICULTY_EASY, VARIANT_POP_OUT, DEFAULT_LANG, BOARD_ROWS, BOARD_COLS, WINNING_COUNT, PLAYER_1)
+	game.RepetitionDraw = true
+	start := hashBoard(game.Board, PLAYER_1)
+
+	// Chaque cycle repasse aussi par la position intermédiaire, un jeton de chaque couleur en bas
+	cycle := func(seen int) {
+		t.Helper()
+		playColumns(t, game, 0, 6)
+		if count := game.repetitionCount(game.CurrentPlayer); count != seen {
+			t.Errorf("position intermédiaire comptée %d fois, attendu %d", count, seen)
+		}
+		for _, pop := range []struct{ col, player int }{{0, PLAYER_1}, {6, PLAYER_2}} {
+			if err := game.popPiece(pop.col, pop.player); err != nil {
+				t.Fatalf("retrait en colonne %d refusé : %s", pop.col, err.Message)
+			}
+		}
+	}
+
+	cycle(1)
+	if hashBoard(game.Board, game.CurrentPlayer) != start {
+		t.Fatal("les retraits n'ont pas ramené la position de départ")
+	}
+	if count := game.repetitionCount(game.CurrentPlayer); count != 2 || game.GameOver {
+		t.Fatalf("après un cycle : %d occurrence(s), partie terminée %v, attendu 2 sans fin de partie", count, game.GameOver)
+	}
+	if hashBoard(game.Board, PLAYER_2) == start {
+		t.Error("le même plateau avec l'autre joueur au trait a la même empreinte")
+	}
+
+	cycle(2)
+	if !game.GameOver || game.Winner != PLAYER_DRAW || game.StatusMessage != game.message(MSG_DRAW_REPEATED) {
+		t.Errorf("troisième occurrence : terminée %v, gagnant %d, %q, attendu un nul par répétition", game.GameOver, game.Winner, game.StatusMessage)
+	}
+}
+
+// Une colonne hors du plateau renvoie -1 sans paniquer ni toucher à la partie
+func TestPlacePieceOutOfRange(t *testing.T) {
+	game := newTestGame()
+	for _, col := range []int{-1, BOARD_COLS} {
+		if row := game.placePiece(col, PLAYER_1); row != -1 {
+			t.Errorf("placePiece(%d) = %d, attendu -1", col, row)
+		}
+	}
+	if len(game.Moves) != 0 || !reflect.DeepEqual(game.Board, newBoard(BOARD_ROWS, BOARD_COLS)) {
+		t.Error("un coup hors du plateau a été enregistré")
+	}
+}
+
+// validateBoard refuse les jetons flottants, les comptes impossibles et un premier joueur inconnu
+func TestValidateBoard(t *testing.T) {
+	floating := parseTestBoard(t,
+		".......",
+		".......",
+		".......",
+		"...R...",
+		".......",
+		"...J...",
+	)
+	redAhead := parseTestBoard(t,
+		".......",
+		".......",
+		".......",
+		".......",
+		"...R...",
+		"..RRJ..",
+	)
+	oneRed := parseTestBoard(t,
+		".......",
+		".......",
+		".......",
+		".......",
+		".......",
+		"...R...",
+	)
+	tests := []struct {
+		name       string
+		board      Board
+		variant    string
+		gravityOff bool
+		first      int
+		valid      bool
+	}{
+		{"jeton flottant", floating, VARIANT_STANDARD, false, PLAYER_1, false},
+		{"jeton flottant sans gravité", floating, VARIANT_STANDARD, true, PLAYER_1, true},
+		{"deux rouges d'avance", redAhead, VARIANT_STANDARD, false, PLAYER_1, false},
+		{"deux rouges d'avance en Pop Out", redAhead, VARIANT_POP_OUT, false, PLAYER_1, true},
+		{"rouge d'avance, rouges premiers", oneRed, VARIANT_STANDARD, false, PLAYER_1, true},
+		{"rouge d'avance, jaunes premiers", oneRed, VARIANT_STANDARD, false, PLAYER_2, false},
+		{"premier joueur absent", oneRed, VARIANT_STANDARD, false, 0, false},
+		{"premier joueur inconnu", oneRed, VARIANT_STANDARD, false, 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBoard(tt.board, tt.variant, tt.gravityOff, tt.first)
+			if (err == nil) != tt.valid {
+				t.Errorf("validateBoard = %v, valide attendu : %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+// ============================================================================
+// API JSON
+// ============================================================================
+
+// Une colonne hors du plateau est refusée en 400 par chaque route qui joue, sans faire planter le serveur
+func TestAPIColumnOutOfRange(t *testing.T) {
+	srv := newTestServer(t)
+
+	tests := []struct {
+		name    string
+		newGame string
+		path    string
+		body    string
+		code    string
+	}{
+		{"move -1", `{"mode": "twoPlayer"}`, "/api/move", `{"col": -1}`, ERROR_COLUMN_OUT_OF_RANGE},
+		{"move 99", `{"mode": "twoPlayer"}`, "/api/move", `{"col": 99}`, ERROR_COLUMN_OUT_OF_RANGE},
+		{"pop -1", `{"mode": "twoPlayer", "variant": "popout"}`, "/api/pop", `{"col": -1}`, ERROR_COLUMN_OUT_OF_RANGE},
+		{"pop 99", `{"mode": "twoPlayer", "variant": "popout"}`, "/api/pop", `{"col": 99}`, ERROR_COLUMN_OUT_OF_RANGE},
+		{"place -1", `{"mode": "twoPlayer", "gravityOff": true}`, "/api/place", `{"row": 5, "col": -1}`, ERROR_CELL_OUT_OF_RANGE},
+		{"place 99", `{"mode": "twoPlayer", "gravityOff": true}`, "/api/place", `{"row": 5, "col": 99}`, ERROR_CELL_OUT_OF_RANGE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t)
+			if status, _ := postJSON(t, client, srv.URL+"/api/new-game", tt.newGame); status != http.StatusOK {
+				t.Fatalf("nouvelle partie : statut %d", status)
+			}
+
+			status, response := postJSON(t, client, srv.URL+tt.path, tt.body)
+			if status != http.StatusBadRequest || response.ErrorCode != tt.code {
+				t.Errorf("statut %d, code %q ; attendu %d, %q", status, response.ErrorCode, http.StatusBadRequest, tt.code)
+			}
+			if response.GameState == nil || len(response.GameState.Moves) != 0 {
+				t.Error("le coup refusé a modifié la partie")
+			}
+		})
+	}
+}
+
+// allowOverline vaut true par défaut ; l'ancienne option exactWin n'est plus acceptée
+func TestNewGameAllowOverline(t *testing.T) {
+	srv := newTestServer(t)
+
+	tests := []struct {
+		body          string
+		status        int
+		allowOverline bool
+	}{
+		{`{"mode": "twoPlayer", "cols": 9, "win": 5}`, http.StatusOK, true},
+		{`{"mode": "twoPlayer", "cols": 9, "win": 5, "allowOverline": true}`, http.StatusOK, true},
+		{`{"mode": "twoPlayer", "cols": 9, "win": 5, "allowOverline": false}`, http.StatusOK, false},
+		{`{"mode": "twoPlayer", "exactWin": true}`, http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		status, response := postJSON(t, newTestClient(t), srv.URL+"/api/new-game", tt.body)
+		if status != tt.status {
+			t.Errorf("%s : statut %d, attendu %d", tt.body, status, tt.status)
+			continue
+		}
+		if status == http.StatusOK && response.GameState.AllowOverline != tt.allowOverline {
+			t.Errorf("%s : AllowOverline = %v, attendu %v", tt.body, response.GameState.AllowOverline, tt.allowOverline)
+		}
+	}
+}
+
+// Un mode inconnu est refusé par parseMode et par les trois points d'entrée qui créent une partie
+func TestInvalidMode(t *testing.T) {
+	for mode, want := range map[string]string{"": GAME_MODE_TWO_PLAYER, GAME_MODE_TWO_PLAYER: GAME_MODE_TWO_PLAYER, GAME_MODE_AI: GAME_MODE_AI} {
+		if got, err := parseMode(mode); err != nil || got != want {
+			t.Errorf("parseMode(%q) = %q, %v, attendu %q", mode, got, err, want)
+		}
+	}
+	if _, err := parseMode("chess"); err == nil {
+		t.Error("parseMode accepte le mode inconnu \"chess\"")
+	}
+
+	srv := newTestServer(t)
+	if status, _ := postJSON(t, newTestClient(t), srv.URL+"/api/new-game", `{"mode": "chess"}`); status != http.StatusBadRequest {
+		t.Errorf("/api/new-game : statut %d, attendu %d", status, http.StatusBadRequest)
+	}
+	for _, path := range []string{"/game/new", "/game/mode"} {
+		resp, err := newTestClient(t).PostForm(srv.URL+path, url.Values{"mode": {"chess"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s : statut %d, attendu %d", path, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
+
+// Un corps de plus de MAX_JSON_BODY_SIZE octets est refusé avec 413, un corps illisible avec 400,
+// sans jamais toucher à la partie
+func TestRequestBodyErrors(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t)
+
+	oversized := `{"col": 3, "pad": "` + strings.Repeat("x", MAX_JSON_BODY_SIZE) + `"}`
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"corps trop gros", oversized, http.StatusRequestEntityTooLarge, ERROR_REQUEST_TOO_LARGE},
+		{"corps vide", "", http.StatusBadRequest, ERROR_INVALID_REQUEST},
+		{"JSON tronqué", `{"col": `, http.StatusBadRequest, ERROR_INVALID_REQUEST},
+		{"colonne mal typée", `{"col": "trois"}`, http.StatusBadRequest, ERROR_INVALID_REQUEST},
+		{"champ inconnu", `{"col": 3, "colonne": 4}`, http.StatusBadRequest, ERROR_INVALID_REQUEST},
+		{"deux objets", `{"col": 3} {"col": 4}`, http.StatusBadRequest, ERROR_INVALID_REQUEST},
+	}
+	for _, tt := range tests {
+		status, response := postJSON(t, client, srv.URL+"/api/move", tt.body)
+		if status != tt.status || response.ErrorCode != tt.code {
+			t.Errorf("%s : statut %d, code %q, attendu %d %s", tt.name, status, response.ErrorCode, tt.status, tt.code)
+		}
+	}
+
+	resp, err := client.Get(srv.URL + "/api/game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var game GameState
+	if err := json.NewDecoder(resp.Body).Decode(&game); err != nil {
+		t.Fatal(err)
+	}
+	if len(game.Moves) != 0 {
+		t.Errorf("%d coup(s) joué(s) par des requêtes refusées", len(game.Moves))
+	}
+}
+
+// La revanche du formulaire (/game/new) reprend la difficulté « hard » et les autres réglages
+// de la partie précédente ; seuls les champs envoyés les remplacent
+func TestRematchKeepsSettings(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t)
+	// La revanche redirige vers la page, qu'il est inutile de suivre
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	body := `{"mode": "ai", "difficulty": "hard", "cols": 9, "player1Color": "blue"}`
+	if status, response := postJSON(t, client, srv.URL+"/api/new-game", body); status != http.StatusOK {
+		t.Fatalf("nouvelle partie : statut %d : %s", status, response.Message)
+	}
+
+	rematch := func(form url.Values) *GameState {
+		resp, err := client.PostForm(srv.URL+"/game/new", form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("revanche : statut %d", resp.StatusCode)
+		}
+
+		resp, err = client.Get(srv.URL + "/api/game")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var game GameState
+		if err := json.NewDecoder(resp.Body).Decode(&game); err != nil {
+			t.Fatal(err)
+		}
+		return &game
+	}
+
+	game := rematch(url.Values{})
+	if game.Difficulty != DIFFICULTY_HARD || game.Mode != GAME_MODE_AI || game.Cols != 9 || game.Player1Color != COLOR_BLUE {
+		t.Errorf("revanche : %s, %s, %d colonnes, %s, attendu %s, %s, 9 colonnes, %s",
+			game.Difficulty, game.Mode, game.Cols, game.Player1Color, DIFFICULTY_HARD, GAME_MODE_AI, COLOR_BLUE)
+	}
+
+	game = rematch(url.Values{"difficulty": {DIFFICULTY_EASY}})
+	if game.Difficulty != DIFFICULTY_EASY || game.Cols != 9 {
+		t.Errorf("revanche en facile : %s sur %d colonnes, attendu %s sur 9", game.Difficulty, game.Cols, DIFFICULTY_EASY)
+	}
+}
+
+// Contre l'IA avec humanPlayer 2, l'IA (rouge) ouvre la partie dès sa création et rend la main à l'humain
+func TestNewGameAIOpens(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t)
+
+	status, response := postJSON(t, client, srv.URL+"/api/new-game", `{"mode": "ai", "humanPlayer": 2}`)
+	if status != http.StatusOK {
+		t.Fatalf("statut %d : %s", status, response.Message)
+	}
+	game := response.GameState
+	if len(game.Moves) != 1 || game.Moves[0].Player != PLAYER_1 {
+		t.Fatalf("coups après la création = %+v, attendu un seul coup de l'IA (joueur %d)", game.Moves, PLAYER_1)
+	}
+	if game.HumanPlayer != PLAYER_2 || game.CurrentPlayer != PLAYER_2 {
+		t.Errorf("HumanPlayer = %d, CurrentPlayer = %d, attendu %d pour les deux", game.HumanPlayer, game.CurrentPlayer, PLAYER_2)
+	}
+
+	// L'humain répond : son jeton est jaune
+	status, response = postJSON(t, client, srv.URL+"/api/move", `{"col": 0}`)
+	if status != http.StatusOK {
+		t.Fatalf("coup de l'humain : statut %d : %s", status, response.Message)
+	}
+	if moves := response.GameState.Moves; len(moves) < 2 || moves[1].Player != PLAYER_2 {
+		t.Errorf("coups après la réponse de l'humain = %+v, attendu un deuxième coup du joueur %d", moves, PLAYER_2)
+	}
+}
+
+// Deux parties créées avec la même graine et jouées avec les mêmes coups reçoivent les mêmes réponses de l'IA
+func TestSeedReproducesAIMoves(t *testing.T) {
+	srv := newTestServer(t)
+
+	replies := func(seed int64) []int {
+		client := newTestClient(t)
+		body := fmt.Sprintf(`{"mode": "ai", "difficulty": "easy", "seed": %d}`, seed)
+		if status, response := postJSON(t, client, srv.URL+"/api/new-game", body); status != http.StatusOK {
+			t.Fatalf("nouvelle partie : statut %d : %s", status, response.Message)
+		}
+		var cols []int
+		for _, col := range []int{3, 3, 2, 4} {
+			if status, response := postJSON(t, client, srv.URL+"/api/move", fmt.Sprintf(`{"col": %d}`, col)); status != http.StatusOK {
+				t.Fatalf("coup en colonne %d : statut %d : %s", col, status, response.Message)
+			}
+			status, response := postJSON(t, client, srv.URL+"/api/ai-move", "")
+			if status != http.StatusOK || response.AIMove == nil {
+				t.Fatalf("coup de l'IA : statut %d : %s", status, response.Message)
+			}
+			cols = append(cols, response.AIMove.Col)
+		}
+		return cols
+	}
+
+	first, second := replies(42), replies(42)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("même graine : réponses %v puis %v", first, second)
+	}
+}
+
+// 100 coups simultanés sur la même session : chaque coup accepté pose exactement un jeton, et le plateau
+// reste cohérent (à lancer avec go test -race)
+func TestConcurrentMoves(t *testing.T) {
+	srv := newTestServer(t)
+	client := newTestClient(t)
+	if status, _ := postJSON(t, client, srv.URL+"/api/new-game", `{"mode": "twoPlayer"}`); status != http.StatusOK {
+		t.Fatalf("nouvelle partie : statut %d", status)
+	}
+
+	const moves = 100
+	var wg sync.WaitGroup
+	var accepted atomic.Int32
+	for i := 0; i < moves; i++ {
+		wg.Add(1)
+		go func(col int) {
+			defer wg.Done()
+			resp, err := client.Post(srv.URL+"/api/move", "application/json", strings.NewReader(fmt.Sprintf(`{"col": %d}`, col)))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				accepted.Add(1)
+			}
+		}(i % BOARD_COLS)
+	}
+	wg.Wait()
+	if accepted.Load() == 0 {
+		t.Fatal("aucun coup accepté")
+	}
+
+	resp, err := client.Get(srv.URL + "/api/game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var game GameState
+	if err := json.NewDecoder(resp.Body).Decode(&game); err != nil {
+		t.Fatal(err)
+	}
+
+	pieces := 0
+	for _, height := range game.Board.columnHeights() {
+		pieces += height
+	}
+	if int(accepted.Load()) != len(game.Moves) || pieces != len(game.Moves) {
+		t.Errorf("%d coups acceptés, %d dans l'historique, %d jetons sur le plateau", accepted.Load(), len(game.Moves), pieces)
+	}
+	if err := validateBoard(game.Board, game.Variant, game.GravityOff, game.FirstPlayer); err != nil {
+		t.Errorf("plateau incohérent : %v", err)
+	}
+}
+
+// Pendant la pause de l'IA après un coup du formulaire, les coups envoyés en rafale (double clic, autre onglet)
+// sont tous refusés avec AI_THINKING : l'humain ne peut ni jouer deux fois de suite ni devancer l'IA
+func TestAIThinkingGuard(t *testing.T) {
+	delay := config.AIThinkDelay
+	config.AIThinkDelay = 300 * time.Millisecond
+	t.Cleanup(func() { config.AIThinkDelay = delay })
+
+	// Le formulaire répond par la page : un gabarit minimal suffit
+	s := newServer(template.Must(template.New("game").Parse("{{.CurrentPlayer}}")))
+	srv := httptest.NewServer(s.setupServer(DEFAULT_STATIC_DIR, nil, ""))
+	defer srv.Close()
+	client := newTestClient(t)
+	if status, response := postJSON(t, client, srv.URL+"/api/new-game", `{"mode": "ai"}`); status != http.StatusOK {
+		t.Fatalf("nouvelle partie : statut %d : %s", status, response.Message)
+	}
+	serverURL, _ := url.Parse(srv.URL)
+	var game *GameState
+	for _, cookie := range client.Jar.Cookies(serverURL) {
+		if cookie.Name == SESSION_COOKIE_NAME {
+			game = s.games.get(cookie.Value)
+		}
+	}
+	if game == nil {
+		t.Fatal("cookie de session absent")
+	}
+
+	done := make(chan error)
+	go func() {
+		resp, err := client.PostForm(srv.URL+"/game/move", url.Values{"col": {"3"}})
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+
+	// Attend que le coup du formulaire soit joué et que l'IA marque sa pause
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		game.mu.RLock()
+		thinking := game.aiThinking
+		game.mu.RUnlock()
+		if thinking {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("l'IA n'a jamais marqué sa pause")
+		}
+	}
+	var wg sync.WaitGroup
+	for col := 0; col < BOARD_COLS; col++ {
+		wg.Add(1)
+		go func(col int) {
+			defer wg.Done()
+			resp, err := client.Post(srv.URL+"/api/move", "application/json", strings.NewReader(fmt.Sprintf(`{"col": %d}`, col)))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer resp.Body.Close()
+			var response GameResponse
+			json.NewDecoder(resp.Body).Decode(&response)
+			if resp.StatusCode != http.StatusConflict || response.ErrorCode != ERROR_AI_THINKING {
+				t.Errorf("coup en colonne %d pendant la pause : statut %d, code %q, attendu %d %s", col, resp.StatusCode, response.ErrorCode, http.StatusConflict, ERROR_AI_THINKING)
+			}
+		}(col)
+	}
+	wg.Wait()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	game.mu.RLock()
+	defer game.mu.RUnlock()
+	if len(game.Moves) != 2 || game.Moves[0].Player != PLAYER_1 || game.Moves[1].Player != PLAYER_2 {
+		t.Errorf("coups = %+v, attendu le coup de l'humain puis celui de l'IA", game.Moves)
+	}
+}
+
+// POST d'un corps JSON avec l'en-tête X-Column-Base ; retourne aussi la numérotation annoncée par la réponse
+func postWithColumnBase(t *testing.T, client *http.Client, url, body string, base int) (int, GameResponse, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(COLUMN_BASE_HEADER, strconv.Itoa(base))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var response GameResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("POST %s : réponse illisible : %v", url, err)
+	}
+	return resp.StatusCode, response, resp.Header.Get(COLUMN_BASE_HEADER)
+}
+
+// Le même coup, demandé en base 0 puis en base 1, tombe dans la même colonne ; la réponse le renvoie
+// dans la numérotation demandée, l'état lu sans en-tête en base 0
+func TestColumnBaseMove(t *testing.T) {
+	srv := newTestServer(t)
+	for _, base := range []int{0, 1} {
+		client := newTestClient(t)
+		postJSON(t, client, srv.URL+"/api/new-game", `{"mode": "twoPlayer"}`)
+
+		status, response, header := postWithColumnBase(t, client, srv.URL+"/api/move", fmt.Sprintf(`{"col": %d}`, 3+base), base)
+		if status != http.StatusOK || header != strconv.Itoa(base) {
+			t.Fatalf("base %d : statut %d, en-tête %q", base, status, header)
+		}
+		state := response.GameState
+		if state.Board[BOARD_ROWS-1][3] != PLAYER_1 {
+			t.Errorf("base %d : jeton absent de la colonne 3 (interne)", base)
+		}
+		if state.LastMove == nil || state.LastMove[1] != 3+base || state.Moves[0].Col != 3+base {
+			t.Errorf("base %d : LastMove %v, Col %d ; attendu la colonne %d", base, state.LastMove, state.Moves[0].Col, 3+base)
+		}
+
+		resp, err := client.Get(srv.URL + "/api/game")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var game GameState
+		err = json.NewDecoder(resp.Body).Decode(&game)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if game.LastMove == nil || game.LastMove[1] != 3 {
+			t.Errorf("base %d : /api/game sans en-tête donne LastMove %v, attendu la colonne 3", base, game.LastMove)
+		}
+	}
+}
+
+// Dans un salon, le coup REST et la WebSocket (?columnBase=1) lisent aussi les colonnes à partir de 1
+func TestColumnBaseRoom(t *testing.T) {
+	srv := newTestServer(t)
+	host, guest := newTestClient(t), newTestClient(t)
+	_, created := postJSON(t, host, srv.URL+"/api/room", "")
+	room := srv.URL + "/api/room/" + created.RoomCode
+	if status, _ := postJSON(t, guest, room+"/join", ""); status != http.StatusOK {
+		t.Fatalf("join : statut %d", status)
+	}
+
+	status, response, _ := postWithColumnBase(t, host, room+"/move", `{"col": 7}`, 1)
+	if status != http.StatusOK || response.GameState.Board[BOARD_ROWS-1][6] != PLAYER_1 || response.GameState.LastMove[1] != 7 {
+		t.Fatalf("coup en colonne 7 (base 1) : statut %d, réponse %+v", status, response)
+	}
+
+	dialer := websocket.Dialer{Jar: guest.Jar}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(room, "http")+"/ws?"+COLUMN_BASE_PARAM+"=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var message GameResponse
+	if err := conn.ReadJSON(&message); err != nil {
+		t.Fatal(err)
+	}
+	if message.GameState.LastMove[1] != 7 {
+		t.Errorf("état initial de la WebSocket : LastMove %v, attendu la colonne 7", message.GameState.LastMove)
+	}
+
+	if err := conn.WriteJSON(map[string]int{"col": 1}); err != nil {
+		t.Fatal(err)
+	}
+	for len(message.GameState.Moves) < 2 {
+		message = GameResponse{}
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatal(err)
+		}
+		if message.ErrorCode != "" {
+			t.Fatalf("coup WebSocket refusé : %s", message.ErrorCode)
+		}
+	}
+	if message.GameState.Board[BOARD_ROWS-1][0] != PLAYER_2 || message.GameState.LastMove[1] != 1 {
+		t.Errorf("coup WebSocket en colonne 1 (base 1) : plateau %v, LastMove %v", message.GameState.Board, message.GameState.LastMove)
+	}
+}
+
+// Chaque route /api/* enregistrée dans setupServer figure dans le contrat OpenAPI servi par /api/openapi.json
+// Une route qui se termine par "/" (salons, tournoi) doit y avoir au moins un chemin
+func TestOpenAPICoversRoutes(t *testing.T) {
+	source, err := os.ReadFile(filepath.Join(sourceDir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := regexp.MustCompile(`mux\.HandleFunc\("(/api/[^"]*)"`).FindAllStringSubmatch(string(source), -1)
+	if len(routes) == 0 {
+		t.Fatal("aucune route /api/* trouvée dans main.go")
+	}
+
+	var spec struct {
+		Paths map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, route := range routes {
+		pattern := route[1]
+		if !strings.HasSuffix(pattern, "/") {
+			if _, ok := spec.Paths[pattern]; !ok {
+				t.Errorf("route %s absente de openapi.json", pattern)
+			}
+			continue
+		}
+		found := false
+		for path := range spec.Paths {
+			found = found || strings.HasPrefix(path, pattern)
+		}
+		if !found {
+			t.Errorf("aucun chemin de openapi.json sous %s", pattern)
+		}
+	}
+}
+
+// ============================================================================
+// MIDDLEWARES
+// ============================================================================
+
+// Une réponse JSON d'au moins GZIP_MIN_SIZE octets est compressée pour un client qui accepte gzip,
+// une réponse plus courte ou un client sans gzip reçoit le JSON tel quel
+func TestGzipHandler(t *testing.T) {
+	large := `{"data":"` + strings.Repeat("puissance4 ", GZIP_MIN_SIZE/10) + `"}`
+	small := `{"success":true}`
+	handler := gzipHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, r.URL.Query().Get("body"))
+	}))
+
+	tests := []struct {
+		name           string
+		body           string
+		acceptEncoding string
+		compressed     bool
+	}{
+		{"grande réponse, gzip accepté", large, "gzip, deflate", true},
+		{"grande réponse, gzip refusé", large, "gzip;q=0", false},
+		{"grande réponse, sans Accept-Encoding", large, "", false},
+		{"petite réponse, gzip accepté", small, "gzip", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/game?body="+url.QueryEscape(tt.body), nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			body := rec.Body.Bytes()
+			if encoding := rec.Header().Get("Content-Encoding"); (encoding == "gzip") != tt.compressed {
+				t.Fatalf("Content-Encoding = %q, compression attendue : %v", encoding, tt.compressed)
+			}
+			if tt.compressed {
+				if len(body) >= len(tt.body) {
+					t.Errorf("réponse compressée de %d octets pour %d en clair", len(body), len(tt.body))
+				}
+				gz, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if body, err = io.ReadAll(gz); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if string(body) != tt.body {
+				t.Errorf("corps reçu de %d octets, attendu les %d octets envoyés", len(body), len(tt.body))
+			}
+		})
+	}
+}
+
+// Une requête préliminaire OPTIONS d'une origine permise reçoit les en-têtes CORS sans atteindre
+// le handler ; une origine inconnue n'en reçoit aucun
+func TestCORSPreflight(t *testing.T) {
+	reached := false
+	handler := corsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}), []string{"https://jeu.example"})
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/api/move", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := preflight("https://jeu.example")
+	if rec.Code != http.StatusNoContent || reached {
+		t.Fatalf("origine permise : statut %d, handler atteint : %v, attendu %d sans l'atteindre", rec.Code, reached, http.StatusNoContent)
+	}
+	header := rec.Header()
+	if got := header.Get("Access-Control-Allow-Origin"); got != "https://jeu.example" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	if got := header.Get("Access-Control-Allow-Methods"); !strings.Contains(got, http.MethodPost) {
+		t.Errorf("Access-Control-Allow-Methods = %q, POST attendu", got)
+	}
+	if got := header.Get("Access-Control-Allow-Headers"); !strings.Contains(got, "Content-Type") {
+		t.Errorf("Access-Control-Allow-Headers = %q, Content-Type attendu", got)
+	}
+
+	rec = preflight("https://ailleurs.example")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("origine inconnue : Access-Control-Allow-Origin = %q, attendu absent", got)
+	}
+}
+
+// Le seau d'un client se vide après burst requêtes et se remplit au débit accordé
+func TestRateLimiterAllow(t *testing.T) {
+	limiter := newRateLimiter(1, 2)
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.allow("client", now); !ok {
+			t.Fatalf("requête %d de la rafale refusée", i+1)
+		}
+	}
+	ok, wait := limiter.allow("client", now)
+	if ok || wait <= 0 || wait > time.Second {
+		t.Fatalf("requête au-delà de la rafale : acceptée %v, attente %v", ok, wait)
+	}
+	if ok, _ := limiter.allow("autre", now); !ok {
+		t.Error("un autre client partage le seau du premier")
+	}
+	if ok, _ := limiter.allow("client", now.Add(time.Second)); !ok {
+		t.Error("requête refusée après une seconde d'attente")
+	}
+}
+
+// Au-delà de la rafale, une route limitée répond 429 RATE_LIMITED avec un en-tête Retry-After
+func TestRateLimitedRoute(t *testing.T) {
+	s := newServer(nil)
+	s.limiter = newRateLimiter(0.01, 3)
+	srv := httptest.NewServer(s.setupServer(DEFAULT_STATIC_DIR, nil, ""))
+	defer srv.Close()
+	client := newTestClient(t)
+
+	// La session est créée par une lecture, non limitée : toutes les requêtes suivantes comptent sur son seau
+	resp, err := client.Get(srv.URL + "/api/game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	for i := 0; i < 3; i++ {
+		if status, response := postJSON(t, client, srv.URL+"/api/move", `{"col": 3}`); status == http.StatusTooManyRequests {
+			t.Fatalf("requête %d de la rafale limitée : %s", i+1, response.Message)
+		}
+	}
+	resp, err = client.Post(srv.URL+"/api/move", "application/json", strings.NewReader(`{"col": 3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var response GameResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests || response.ErrorCode != ERROR_RATE_LIMITED {
+		t.Errorf("quatrième requête : statut %d, code %q, attendu %d %s", resp.StatusCode, response.ErrorCode, http.StatusTooManyRequests, ERROR_RATE_LIMITED)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("en-tête Retry-After absent")
+	}
+}
+
+// Sur le WebSocket, chaque coup reçu consomme un jeton : au-delà de la rafale, le coup est refusé
+// par un message RATE_LIMITED et la connexion reste ouverte
+func TestRateLimitedWebSocket(t *testing.T) {
+	s := newServer(nil)
+	s.limiter = newRateLimiter(0.01, 3)
+	srv := httptest.NewServer(s.setupServer(DEFAULT_STATIC_DIR, nil, ""))
+	defer srv.Close()
+	client := newTestClient(t)
+
+	resp, err := client.Get(srv.URL + "/api/game")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// L'ouverture consomme le premier jeton, les deux premiers coups les suivants
+	dialer := websocket.Dialer{Jar: client.Jar}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var message GameResponse
+	if err := conn.ReadJSON(&message); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, col := range []int{0, 1, 2} {
+		if err := conn.WriteJSON(map[string]int{"col": col}); err != nil {
+			t.Fatal(err)
+		}
+		message = GameResponse{}
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatal(err)
+		}
+		if limited := message.ErrorCode == ERROR_RATE_LIMITED; limited != (i == 2) {
+			t.Errorf("coup %d : succès %v, code %q", i+1, message.Success, message.ErrorCode)
+		}
+	}
+	if moves := len(message.GameState.Moves); moves != 2 {
+		t.Errorf("%d coups joués, attendu 2", moves)
+	}
+}
+
+// Avec un jeton configuré, les requêtes qui modifient et l'ouverture des WebSockets sont refusées avec 401
+// sans le bon jeton (Bearer ou mot de passe Basic) ; les lectures restent publiques
+func TestAuthHandler(t *testing.T) {
+	const token = "secret-de-test"
+	srv := httptest.NewServer(newServer(nil).setupServer(DEFAULT_STATIC_DIR, nil, token))
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		auth   func(*http.Request)
+		status int
+	}{
+		{"POST sans jeton", http.MethodPost, "/api/new-game", func(*http.Request) {}, http.StatusUnauthorized},
+		{"POST avec un faux jeton", http.MethodPost, "/api/new-game", func(r *http.Request) { r.Header.Set("Authorization", "Bearer faux") }, http.StatusUnauthorized},
+		{"formulaire sans jeton", http.MethodPost, "/game/new", func(*http.Request) {}, http.StatusUnauthorized},
+		{"POST avec le jeton Bearer", http.MethodPost, "/api/new-game", func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }, http.StatusOK},
+		{"POST avec le jeton en Basic", http.MethodPost, "/api/new-game", func(r *http.Request) { r.SetBasicAuth("joueur", token) }, http.StatusOK},
+		{"GET sans jeton", http.MethodGet, "/api/game", func(*http.Request) {}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(`{"mode": "twoPlayer"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		tt.auth(req)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var response GameResponse
+		json.NewDecoder(resp.Body).Decode(&response)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s : statut %d, attendu %d", tt.name, resp.StatusCode, tt.status)
+			continue
+		}
+		if tt.status == http.StatusUnauthorized && strings.HasPrefix(tt.path, "/api/") && response.ErrorCode != ERROR_UNAUTHORIZED {
+			t.Errorf("%s : code %q, attendu %s", tt.name, response.ErrorCode, ERROR_UNAUTHORIZED)
+		}
+	}
+
+	// Les WebSockets acceptent des coups : leur ouverture, un GET, exige aussi le jeton
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http")
+	for _, path := range []string{"/ws", "/api/room/ABCD/ws"} {
+		_, resp, err := websocket.DefaultDialer.Dial(wsURL+path, nil)
+		if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("WebSocket %s sans jeton : %v, attendu %d", path, err, http.StatusUnauthorized)
+		}
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"/ws", http.Header{"Authorization": {"Bearer " + token}})
+	if err != nil {
+		t.Fatalf("WebSocket avec le jeton : %v", err)
+	}
+	conn.Close()
+}
+
+// ============================================================================
+// PERFORMANCES DE L'IA
+// ============================================================================
+
+// Positions de référence des mesures de recherche, en colonnes jouées depuis le plateau vide
+var benchmarkOpenings = [][]int{{}, {3, 3}, {3, 2, 4, 4, 2}, {0, 6, 1, 5}}
+
+// Plateau obtenu en jouant les colonnes en alternance, les Rouges d'abord ; retourne aussi le joueur au trait
+func benchmarkBoard(cols []int) (Board, int) {
+	board, player := newBoard(BOARD_ROWS, BOARD_COLS), PLAYER_1
+	for _, col := range cols {
+		board, _ = board.Place(col, player)
+		player = PLAYER_2 + PLAYER_1 - player
+	}
+	return board, player
+}
+
+// Minimax alpha-bêta de minimaxCached, sans table de transposition ni contexte, dont les coups sont
+// parcourus dans l'ordre donné par moves ; retourne le score et le nombre de positions explorées
+func countMinimaxNodes(board Board, depth, alpha, beta int, maximizing bool, moves func(Board) []int) (score, nodes int) {
+	nodes = 1
+	cols := moves(board)
+	switch {
+	case len(cols) == 0:
+		return 0, nodes
+	case depth == 0:
+		return evaluateBoard(board, WINNING_COUNT, PLAYER_2), nodes
+	}
+
+	player, score := PLAYER_1, math.MaxInt
+	if maximizing {
+		player, score = PLAYER_2, math.MinInt
+	}
+	for _, col := range cols {
+		child, row := board.Place(col, player)
+
+		var childScore int
+		if winner, _, _ := child.checkForWin(row, col, WINNING_COUNT, true); winner == player {
+			childScore = MINIMAX_WIN_SCORE + depth
+			if !maximizing {
+				childScore = -childScore
+			}
+		} else {
+			var n int
+			childScore, n = countMinimaxNodes(child, depth-1, alpha, beta, !maximizing, moves)
+			nodes += n
+		}
+
+		if maximizing {
+			score = max(score, childScore)
+			alpha = max(alpha, score)
+		} else {
+			score = min(score, childScore)
+			beta = min(beta, score)
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	return score, nodes
+}
+
+// Positions explorées par minimax à profondeur 7, coups triés du centre vers les bords (orderedMoves)
+// ou de gauche à droite (getValidMoves) ; le tri divise le nombre de positions par six environ
+func BenchmarkMinimaxOrdered(b *testing.B) {
+	for _, ordering := range []struct {
+		name  string
+		moves func(Board) []int
+	}{{"center-first", Board.orderedMoves}, {"left-to-right", Board.getValidMoves}} {
+		b.Run(ordering.name, func(b *testing.B) {
+			nodes := 0
+			for i := 0; i < b.N; i++ {
+				for _, opening := range benchmarkOpenings {
+					board, player := benchmarkBoard(opening)
+					_, n := countMinimaxNodes(board, 7, math.MinInt, math.MaxInt, player == PLAYER_2, ordering.moves)
+					nodes += n
+				}
+			}
+			b.ReportMetric(float64(nodes)/float64(b.N), "nodes/op")
+		})
+	}
+}
+
+// Marge tolérée au-delà du budget de temps : fin de la profondeur en cours, repli et ordonnancement
+const timedSearchMargin = 50 * time.Millisecond
+
+// Recherche de l'IA difficile par approfondissement itératif : chaque coup tient dans HARD_TIME_BUDGET
+// (200 ms), quelle que soit la position ; max-ms est la réponse la plus lente de la mesure
+func BenchmarkGetBestMoveTimed(b *testing.B) {
+	var slowest time.Duration
+	depths := 0
+	for i := 0; i < b.N; i++ {
+		for _, opening := range benchmarkOpenings {
+			board, player := benchmarkBoard(opening)
+			stats := &SearchStats{}
+			start := time.Now()
+			getBestMoveTimed(context.Background(), board, WINNING_COUNT, true, player, HARD_TIME_BUDGET, rand.New(rand.NewSource(1)), stats)
+			slowest = max(slowest, time.Since(start))
+			depths += stats.DepthReached
+		}
+	}
+	if slowest > HARD_TIME_BUDGET+timedSearchMargin {
+		b.Errorf("coup le plus lent en %v pour un budget de %v", slowest, HARD_TIME_BUDGET)
+	}
+	b.ReportMetric(float64(slowest.Microseconds())/1000, "max-ms")
+	b.ReportMetric(float64(depths)/float64(b.N*len(benchmarkOpenings)), "depth/move")
+}
+
+// Positions explorées par minimax à profondeur 8, avec la table de transposition (clé canonique, qui confond
+// une position et son miroir) ou sans
+func BenchmarkMinimaxTransposition(b *testing.B) {
+	for _, table := range []struct {
+		name   string
+		enable bool
+	}{{"cache", true}, {"no-cache", false}} {
+		b.Run(table.name, func(b *testing.B) {
+			nodes := 0
+			for i := 0; i < b.N; i++ {
+				for _, opening := range benchmarkOpenings {
+					board, player := benchmarkBoard(opening)
+					var cache map[string]int
+					if table.enable {
+						cache = make(map[string]int)
+					}
+					stats := &SearchStats{}
+					minimaxCached(context.Background(), board, WINNING_COUNT, true, 8, math.MinInt, math.MaxInt, player == PLAYER_2, cache, stats)
+					nodes += stats.NodesSearched
+				}
+			}
+			b.ReportMetric(float64(nodes)/float64(b.N), "nodes/op")
+		})
+	}
+}